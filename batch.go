@@ -0,0 +1,215 @@
+package tprogateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// DefaultBatchConcurrency is the number of operations ExecuteBatch sends at once by default
+const DefaultBatchConcurrency = 4
+
+// ErrBatchStopped is the error of batch items that weren't sent because another item failed (see BatchOptions.StopOnError)
+var ErrBatchStopped = errors.New("batch stopped after a failed item")
+
+type (
+	// BatchOptions configure ExecuteBatch, zero values mean defaults
+	BatchOptions struct {
+		// Concurrency limits the number of operations sent at once, DefaultBatchConcurrency if zero
+		Concurrency int
+		// RateLimit limits the number of operations started per second, no limit if zero.
+		// It applies to this batch only, use WithLimit to throttle all requests of the client.
+		RateLimit float64
+		// ItemTimeout limits the time of every operation (including retries), no limit if zero
+		ItemTimeout time.Duration
+		// StopOnError makes the batch skip items that weren't started yet after the first failed item.
+		// Otherwise failed items don't affect the other ones.
+		StopOnError bool
+		// Progress receives an event for every finished or skipped item, it's closed when ExecuteBatch returns.
+		// The channel must be drained, the batch waits for every event to be received.
+		Progress chan<- BatchProgress
+	}
+
+	// BatchResult is the outcome of a batch item
+	BatchResult struct {
+		// Index is the item's position in the batch
+		Index     int
+		Operation structures.OperationRequestInterface
+		// Response is the raw Gateway response, nil if no response was received
+		Response *structures.GatewayResponse
+		// Err follows Execute semantics: Gateway errors in response payloads are errors too.
+		// Items that weren't sent have ErrBatchStopped or an error wrapping *RequestAbortedError.
+		Err error
+	}
+
+	// BatchProgress reports a finished batch item
+	BatchProgress struct {
+		// Index is the finished item's position in the batch
+		Index int
+		// Err is the finished item's error
+		Err error
+		// Completed is the number of finished items, including failed ones
+		Completed int
+		// Failed is the number of finished items with an error
+		Failed int
+		// Total is the number of items in the batch
+		Total int
+	}
+)
+
+// ExecuteBatch sends operations with the gateway (usually *GatewayClient) concurrently and waits for all of them.
+// Results are returned in the order of operations, with per-item errors. The error is returned for invalid options only.
+// If the context is done, items that weren't started yet are skipped.
+func ExecuteBatch(ctx context.Context, gw Gateway, ops []structures.OperationRequestInterface, options BatchOptions) ([]BatchResult, error) {
+	if options.Progress != nil {
+		defer close(options.Progress)
+	}
+
+	if ctx == nil {
+		return nil, errors.New("batch: nil context")
+	}
+
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	b := &batch{gw: gw, options: options, results: make([]BatchResult, len(ops))}
+	for i, op := range ops {
+		b.results[i] = BatchResult{Index: i, Operation: op}
+	}
+	if options.RateLimit > 0 {
+		b.limiter = &tokenBucket{rate: options.RateLimit, burst: 1, tokens: 1}
+	}
+
+	workers := options.Concurrency
+	if workers == 0 {
+		workers = DefaultBatchConcurrency
+	}
+	if workers > len(ops) {
+		workers = len(ops)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				b.run(ctx, i)
+			}
+		}()
+	}
+
+	for i := range ops {
+		if err := b.stopCause(ctx, i); err != nil {
+			b.finish(i, nil, err)
+			continue
+		}
+
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			b.finish(i, nil, b.stopCause(ctx, i))
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return b.results, nil
+}
+
+func (o *BatchOptions) validate() error {
+	switch {
+	case o.Concurrency < 0:
+		return fmt.Errorf("batch: concurrency can't be negative, got %d", o.Concurrency)
+	case o.RateLimit < 0:
+		return fmt.Errorf("batch: rate limit can't be negative, got %g", o.RateLimit)
+	case o.ItemTimeout < 0:
+		return fmt.Errorf("batch: item timeout can't be negative, got %s", o.ItemTimeout)
+	}
+
+	return nil
+}
+
+// batch is the state of a running ExecuteBatch
+type batch struct {
+	gw      Gateway
+	options BatchOptions
+	limiter *tokenBucket
+	results []BatchResult
+
+	mu        sync.Mutex
+	completed int
+	failed    int
+	stopped   bool
+}
+
+// run sends the item's operation, unless the batch is stopped meanwhile
+func (b *batch) run(ctx context.Context, i int) {
+	if err := b.stopCause(ctx, i); err != nil {
+		b.finish(i, nil, err)
+		return
+	}
+
+	op := b.results[i].Operation
+	if b.limiter != nil {
+		if err := b.limiter.wait(ctx); err != nil {
+			b.finish(i, nil, newGatewayError(structures.ErrorCategoryTransport, op.GetOperationType(), 0, &RequestAbortedError{Err: err}))
+			return
+		}
+	}
+
+	itemCtx := ctx
+	if b.options.ItemTimeout > 0 {
+		var cancel context.CancelFunc
+		itemCtx, cancel = context.WithTimeout(ctx, b.options.ItemTimeout)
+		defer cancel()
+	}
+
+	gwResponse, err := b.gw.NewRequestWithContext(itemCtx, op)
+	if err == nil && gwResponse != nil {
+		err = responseError(op.GetOperationType(), gwResponse)
+	}
+
+	b.finish(i, gwResponse, err)
+}
+
+// stopCause returns the error of an item that must not be started, nil if it may be started
+func (b *batch) stopCause(ctx context.Context, i int) error {
+	if err := ctx.Err(); err != nil {
+		return newGatewayError(structures.ErrorCategoryTransport, b.results[i].Operation.GetOperationType(), 0, &RequestAbortedError{Err: err})
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopped {
+		return ErrBatchStopped
+	}
+
+	return nil
+}
+
+// finish saves the item's result and reports progress
+func (b *batch) finish(i int, gwResponse *structures.GatewayResponse, err error) {
+	b.results[i].Response = gwResponse
+	b.results[i].Err = err
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.completed++
+	if err != nil {
+		b.failed++
+		b.stopped = b.stopped || b.options.StopOnError
+	}
+
+	if b.options.Progress != nil {
+		b.options.Progress <- BatchProgress{Index: i, Err: err, Completed: b.completed, Failed: b.failed, Total: len(b.results)}
+	}
+}
@@ -0,0 +1,227 @@
+package tprogateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+// declinedAmount is the amount of batch test operations answered with a decline
+const declinedAmount = 1106
+
+// newBatchGateway starts a server answering SMS requests with their amount as gateway transaction ID,
+// declining the ones with declinedAmount. Requests are delayed by given duration multiplied by amount modulo 3.
+// The maximum number of concurrent requests is written to inFlightMax if it's set.
+func newBatchGateway(t *testing.T, delay time.Duration, inFlightMax *int) *httptest.Server {
+	var mu sync.Mutex
+	inFlight := 0
+
+	return newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		mu.Lock()
+		inFlight++
+		if inFlightMax != nil && inFlight > *inFlightMax {
+			*inFlightMax = inFlight
+		}
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+
+		var request struct {
+			Data struct {
+				Money structures.MoneyData `json:"money-data"`
+			} `json:"data"`
+		}
+		_ = json.Unmarshal(body, &request)
+		amount := request.Data.Money.Amount
+
+		select {
+		case <-time.After(delay * time.Duration(amount%3)):
+		case <-r.Context().Done():
+		}
+
+		if amount == declinedAmount {
+			return http.StatusPaymentRequired, `{"gw":{"status-code":8},"error":{"code":1106,"message":"Card expired"}}`
+		}
+
+		return http.StatusOK, `{"gw":{"gateway-transaction-id":"tx-` + strconv.Itoa(amount) + `","status-code":7}}`
+	})
+}
+
+func batchOperations(gateCli *GatewayClient, amounts ...int) []structures.OperationRequestInterface {
+	result := make([]structures.OperationRequestInterface, 0, len(amounts))
+	for _, amount := range amounts {
+		sms := gateCli.OperationBuilder().NewSms()
+		sms.Money.Amount = amount
+		result = append(result, sms)
+	}
+
+	return result
+}
+
+func TestExecuteBatch(t *testing.T) {
+	inFlightMax := 0
+	server := newBatchGateway(t, 5*time.Millisecond, &inFlightMax)
+	defer server.Close()
+
+	gateCli := newTestClient(t, server)
+	amounts := []int{1, 2, 3, declinedAmount, 5, 6, 7, 8, 9, 10}
+	ops := batchOperations(gateCli, amounts...)
+
+	progress := make(chan BatchProgress)
+	var events []BatchProgress
+	done := make(chan struct{})
+	go func() {
+		for event := range progress {
+			events = append(events, event)
+		}
+		close(done)
+	}()
+
+	results, err := ExecuteBatch(context.Background(), gateCli, ops, BatchOptions{Concurrency: 3, Progress: progress})
+	<-done
+
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, inFlightMax, 3)
+	if assert.Len(t, results, len(amounts)) {
+		for i, result := range results {
+			assert.Equal(t, i, result.Index)
+			assert.Equal(t, ops[i], result.Operation)
+			if assert.NotNil(t, result.Response) {
+				assert.Contains(t, string(result.Response.Payload), `"status-code"`)
+			}
+
+			if amounts[i] == declinedAmount {
+				assert.True(t, errors.Is(result.Err, structures.ErrHardDecline))
+			} else {
+				assert.NoError(t, result.Err)
+				assert.Contains(t, string(result.Response.Payload), `"tx-`+strconv.Itoa(amounts[i])+`"`)
+			}
+		}
+	}
+
+	if assert.Len(t, events, len(amounts)) {
+		last := events[len(events)-1]
+		assert.Equal(t, BatchProgress{Index: last.Index, Err: last.Err, Completed: 10, Failed: 1, Total: 10}, last)
+		for i, event := range events {
+			assert.Equal(t, i+1, event.Completed)
+		}
+	}
+}
+
+func TestExecuteBatchStopOnError(t *testing.T) {
+	server := newBatchGateway(t, 0, nil)
+	defer server.Close()
+
+	gateCli := newTestClient(t, server)
+	ops := batchOperations(gateCli, 1, declinedAmount, 3, 4)
+
+	results, err := ExecuteBatch(context.Background(), gateCli, ops, BatchOptions{Concurrency: 1, StopOnError: true})
+	assert.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.True(t, errors.Is(results[1].Err, structures.ErrHardDecline))
+	assert.Equal(t, ErrBatchStopped, results[2].Err)
+	assert.Equal(t, ErrBatchStopped, results[3].Err)
+	assert.Nil(t, results[3].Response)
+
+	results, err = ExecuteBatch(context.Background(), gateCli, ops, BatchOptions{Concurrency: 1})
+	assert.NoError(t, err)
+	assert.NoError(t, results[3].Err)
+}
+
+func TestExecuteBatchRateLimit(t *testing.T) {
+	server := newBatchGateway(t, 0, nil)
+	defer server.Close()
+
+	gateCli := newTestClient(t, server)
+	started := time.Now()
+	results, err := ExecuteBatch(context.Background(), gateCli, batchOperations(gateCli, 1, 2, 3, 4, 5), BatchOptions{RateLimit: 50})
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, int64(time.Since(started)), int64(80*time.Millisecond))
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+	}
+}
+
+func TestExecuteBatchItemTimeout(t *testing.T) {
+	server := newBatchGateway(t, time.Second, nil)
+	defer server.Close()
+
+	gateCli := newTestClient(t, server)
+	results, err := ExecuteBatch(context.Background(), gateCli, batchOperations(gateCli, 3, 4), BatchOptions{ItemTimeout: 100 * time.Millisecond})
+	assert.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+
+	var abortedErr *RequestAbortedError
+	if assert.True(t, errors.As(results[1].Err, &abortedErr)) {
+		assert.Equal(t, context.DeadlineExceeded, abortedErr.Err)
+	}
+}
+
+func TestExecuteBatchCanceledContext(t *testing.T) {
+	server := newBatchGateway(t, 0, nil)
+	defer server.Close()
+
+	gateCli := newTestClient(t, server)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := ExecuteBatch(ctx, gateCli, batchOperations(gateCli, 1, 2, 3), BatchOptions{})
+	assert.NoError(t, err)
+	for _, result := range results {
+		assert.True(t, errors.Is(result.Err, context.Canceled))
+		assert.True(t, errors.Is(result.Err, structures.ErrTransport))
+	}
+}
+
+func TestExecuteBatchValidation(t *testing.T) {
+	examples := []struct {
+		options       BatchOptions
+		expectedError string
+	}{
+		{BatchOptions{Concurrency: -1}, "batch: concurrency can't be negative, got -1"},
+		{BatchOptions{RateLimit: -0.5}, "batch: rate limit can't be negative, got -0.5"},
+		{BatchOptions{ItemTimeout: -time.Second}, "batch: item timeout can't be negative, got -1s"},
+	}
+
+	for _, testCase := range examples {
+		t.Run(testCase.expectedError, func(t *testing.T) {
+			progress := make(chan BatchProgress)
+			testCase.options.Progress = progress
+
+			results, err := ExecuteBatch(context.Background(), nil, nil, testCase.options)
+			assert.Nil(t, results)
+			assert.EqualError(t, err, testCase.expectedError)
+
+			// progress is closed on errors too
+			_, open := <-progress
+			assert.False(t, open)
+		})
+	}
+
+	progress := make(chan BatchProgress)
+	results, err := ExecuteBatch(nil, nil, nil, BatchOptions{Progress: progress})
+	assert.Nil(t, results)
+	assert.EqualError(t, err, "batch: nil context")
+	_, open := <-progress
+	assert.False(t, open)
+
+	progress = make(chan BatchProgress, 1)
+	results, err = ExecuteBatch(context.Background(), nil, nil, BatchOptions{Progress: progress})
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+	_, open = <-progress
+	assert.False(t, open)
+}
@@ -0,0 +1,104 @@
+package tprogateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/TransactPRO/gw3-go-client/store"
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// MetricDigestStoreErrors counts failures to save request digests, reported to Observer
+const MetricDigestStoreErrors = "gateway_digest_store_errors_total"
+
+// transactionOperations are operations creating a transaction, the digests of their requests are saved.
+// Operations made for an existing transaction (charge, cancel, refund, ...) must not overwrite its digest.
+var transactionOperations = map[structures.OperationType]bool{
+	structures.SMS:              true,
+	structures.DMSHold:          true,
+	structures.MOTOSMS:          true,
+	structures.MOTODMS:          true,
+	structures.CREDIT:           true,
+	structures.P2P:              true,
+	structures.B2P:              true,
+	structures.InitRecurrentSMS: true,
+	structures.RecurrentSMS:     true,
+	structures.InitRecurrentDMS: true,
+	structures.RecurrentDMS:     true,
+	structures.CreateToken:      true,
+}
+
+// DigestStoreErrorHandler receives failures to save request digests.
+// handlers.CallbackHandler with Digests rejects callbacks of a transaction which digest is missing,
+// so the record should be saved by other means (or the failure alerted on) for its callbacks to be accepted.
+type DigestStoreErrorHandler func(ctx context.Context, record store.DigestRecord, err error)
+
+// WithDigestStore sets the store that receives request digests of transactions created by NewRequest,
+// so callbacks can be verified against the original request later (see handlers.CallbackHandler).
+// Digests are saved for verified responses of operations creating a transaction (sms, hold-dms, credit, ...)
+// containing a gateway transaction ID, so charges, cancels and refunds keep the original digest.
+// Save failures don't fail the request, they are passed to the handler set by WithDigestStoreErrorHandler
+// (logged with the standard logger by default) and reported to Observer as MetricDigestStoreErrors.
+func WithDigestStore(digestStore store.DigestStore) Option {
+	return func(gc *GatewayClient) error {
+		if digestStore == nil {
+			return errors.New("digest store can't be nil")
+		}
+
+		gc.digestStore = digestStore
+		return nil
+	}
+}
+
+// WithDigestStoreErrorHandler sets the handler of failures to save request digests, instead of logging them
+func WithDigestStoreErrorHandler(handler DigestStoreErrorHandler) Option {
+	return func(gc *GatewayClient) error {
+		if handler == nil {
+			return errors.New("digest store error handler can't be nil")
+		}
+
+		gc.digestStoreErrors = handler
+		return nil
+	}
+}
+
+// saveDigest writes verified response's original request digest to the client's digest store
+func (gc *GatewayClient) saveDigest(ctx context.Context, opData structures.OperationRequestInterface, gwResponse *structures.GatewayResponse) {
+	if gc.digestStore == nil || gwResponse == nil || gwResponse.Digest == nil || !transactionOperations[opData.GetOperationType()] {
+		return
+	}
+
+	var parsed struct {
+		Gateway structures.Gateway `json:"gw"`
+	}
+	if err := json.Unmarshal(gwResponse.Payload, &parsed); err != nil || parsed.Gateway.GatewayTransactionID == "" {
+		return
+	}
+
+	record := store.DigestRecord{
+		GatewayTransactionID:  parsed.Gateway.GatewayTransactionID,
+		MerchantTransactionID: parsed.Gateway.MerchantTransactionID,
+		URI:                   gwResponse.Digest.URI,
+		Cnonce:                gwResponse.Digest.Cnonce,
+	}
+	if op, ok := opData.(RecoverableOperation); ok && op.GetMerchantTransactionID() != "" {
+		record.MerchantTransactionID = op.GetMerchantTransactionID()
+	}
+
+	err := gc.digestStore.Save(ctx, record)
+	if err == nil {
+		return
+	}
+
+	if gc.digestStoreErrors != nil {
+		gc.digestStoreErrors(ctx, record, err)
+	} else {
+		log.Printf("gateway: request digest for %s can't be saved, its callbacks can't be verified: %s", record.GatewayTransactionID, err)
+	}
+
+	if gc.observer != nil {
+		gc.observer.IncCounter(MetricDigestStoreErrors, Tags{TagOperation: string(opData.GetOperationType())})
+	}
+}
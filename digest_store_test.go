@@ -0,0 +1,133 @@
+package tprogateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/TransactPRO/gw3-go-client/store"
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingDigestStore struct {
+	store.DigestStore
+}
+
+func (failingDigestStore) Save(context.Context, store.DigestRecord) error {
+	return errors.New("database is down")
+}
+
+func TestWithDigestStoreSavesDigest(t *testing.T) {
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		return http.StatusOK, "{\"gw\":{\"gateway-transaction-id\":\"gw-1\",\"status-code\":7},\"error\":{}}"
+	})
+	defer server.Close()
+
+	digestStore := store.NewMemoryDigestStore()
+	gateCli := newTestClient(t, server, WithDigestStore(digestStore))
+
+	resp, err := gateCli.NewRequest(newRecoverableSMS())
+	assert.NoError(t, err)
+
+	record, err := digestStore.FindByGatewayTransactionID(context.Background(), "gw-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "order-1", record.MerchantTransactionID)
+	assert.Equal(t, "/v3.0/sms", record.URI)
+	assert.Equal(t, resp.Digest.Cnonce, record.Cnonce)
+
+	byMerchant, err := digestStore.FindByMerchantTransactionID(context.Background(), "order-1")
+	assert.NoError(t, err)
+	assert.Equal(t, record, byMerchant)
+}
+
+func TestWithDigestStoreKeepsParentDigest(t *testing.T) {
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		return http.StatusOK, "{\"gw\":{\"gateway-transaction-id\":\"gw-1\",\"status-code\":7},\"error\":{}}"
+	})
+	defer server.Close()
+
+	digestStore := store.NewMemoryDigestStore()
+	gateCli := newTestClient(t, server, WithDigestStore(digestStore))
+
+	hold := gateCli.OperationBuilder().NewHoldDMS()
+	resp, err := gateCli.NewRequest(hold)
+	assert.NoError(t, err)
+
+	charge := gateCli.OperationBuilder().NewChargeDMS()
+	charge.CommandData.GWTransactionID = "gw-1"
+	cancel := gateCli.OperationBuilder().NewCancel()
+	cancel.CommandData.GWTransactionID = "gw-1"
+	refund := gateCli.OperationBuilder().NewRefund()
+	refund.CommandData.GWTransactionID = "gw-1"
+
+	for _, op := range []structures.OperationRequestInterface{charge, cancel, refund} {
+		_, err = gateCli.NewRequest(op)
+		assert.NoError(t, err)
+	}
+
+	record, err := digestStore.FindByGatewayTransactionID(context.Background(), "gw-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "/v3.0/hold-dms", record.URI)
+	assert.Equal(t, resp.Digest.Cnonce, record.Cnonce)
+}
+
+func TestWithDigestStoreSkipsResponsesWithoutTransaction(t *testing.T) {
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		return http.StatusOK, "{\"transactions\":[]}"
+	})
+	defer server.Close()
+
+	digestStore := store.NewMemoryDigestStore()
+	gateCli := newTestClient(t, server, WithDigestStore(digestStore))
+
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewGetStatus())
+	assert.NoError(t, err)
+
+	_, err = digestStore.FindByMerchantTransactionID(context.Background(), "order-1")
+	assert.Equal(t, store.ErrNotFound, err)
+}
+
+func TestWithDigestStoreErrorsDontFailRequest(t *testing.T) {
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		return http.StatusOK, "{\"gw\":{\"gateway-transaction-id\":\"gw-1\",\"status-code\":7},\"error\":{}}"
+	})
+	defer server.Close()
+
+	var failures []string
+	observer := newTestObserver()
+	gateCli := newTestClient(t, server, WithDigestStore(failingDigestStore{}), WithObserver(observer),
+		WithDigestStoreErrorHandler(func(ctx context.Context, record store.DigestRecord, err error) {
+			failures = append(failures, record.GatewayTransactionID+": "+err.Error())
+		}))
+
+	_, err := gateCli.NewRequest(newRecoverableSMS())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"gw-1: database is down"}, failures)
+	assert.Equal(t, []Tags{{TagOperation: "sms"}}, observer.counters[MetricDigestStoreErrors])
+
+	_, err = NewGatewayClient(testObjectGUID, testSecretKey, WithDigestStore(nil))
+	assert.EqualError(t, err, "digest store can't be nil")
+	_, err = NewGatewayClient(testObjectGUID, testSecretKey, WithDigestStoreErrorHandler(nil))
+	assert.EqualError(t, err, "digest store error handler can't be nil")
+}
+
+func TestWithDigestStoreErrorsAreLoggedByDefault(t *testing.T) {
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		return http.StatusOK, "{\"gw\":{\"gateway-transaction-id\":\"gw-1\",\"status-code\":7},\"error\":{}}"
+	})
+	defer server.Close()
+
+	var logBuffer bytes.Buffer
+	log.SetOutput(&logBuffer)
+	defer log.SetOutput(os.Stderr)
+
+	gateCli := newTestClient(t, server, WithDigestStore(failingDigestStore{}))
+	_, err := gateCli.NewRequest(newRecoverableSMS())
+	assert.NoError(t, err)
+	assert.Contains(t, logBuffer.String(), "gateway: request digest for gw-1 can't be saved, its callbacks can't be verified: database is down")
+}
@@ -0,0 +1,53 @@
+package tprogateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// RequestAbortedError is returned when a request was not completed because
+// its context was canceled or its deadline was exceeded
+type RequestAbortedError struct {
+	Err error
+}
+
+func (e *RequestAbortedError) Error() string {
+	return fmt.Sprintf("request aborted: %s", e.Err)
+}
+
+// Unwrap returns the context's error, so errors.Is(err, context.Canceled) works as expected
+func (e *RequestAbortedError) Unwrap() error {
+	return e.Err
+}
+
+// abortedOr returns RequestAbortedError if given context is already done, or the original error otherwise
+func abortedOr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return &RequestAbortedError{Err: ctxErr}
+	}
+
+	return err
+}
+
+// newGatewayError wraps a client-side failure into *structures.GatewayError
+func newGatewayError(category structures.ErrorCategory, opType structures.OperationType, httpStatus int, err error) *structures.GatewayError {
+	return &structures.GatewayError{
+		HTTPStatus:    httpStatus,
+		OperationType: opType,
+		Category:      category,
+		Err:           err,
+	}
+}
+
+// withOperationType fills operation type of *structures.GatewayError if it's missing
+func withOperationType(err error, opType structures.OperationType) error {
+	var gwErr *structures.GatewayError
+	if errors.As(err, &gwErr) && gwErr.OperationType == "" {
+		gwErr.OperationType = opType
+	}
+
+	return err
+}
@@ -0,0 +1,84 @@
+package tprogateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// TypedOperation is an operation which response is parsed into T by its ParseResponse method.
+// Every operation assembly with a structured response implements it, e.g.
+// *transactions.SMSAssembly is TypedOperation[*structures.TransactionResponse].
+type TypedOperation[T any] interface {
+	structures.OperationRequestInterface
+	ParseResponse(response *structures.GatewayResponse) (T, error)
+}
+
+// Execute sends the operation request with the gateway (usually *GatewayClient) and parses the response:
+//
+//	result, gwResponse, err := tprogateway.Execute[*structures.TransactionResponse](ctx, gateCli, sms)
+//
+// Errors are *structures.GatewayError:
+//   - errors of NewRequestWithContext (transport failures, digest verification, ...) are returned as is;
+//   - a Gateway error in the response payload (like a decline) is returned along with the parsed result;
+//   - an unsuccessful HTTP status without a Gateway error is reported with ErrorCategoryGateway;
+//   - a payload that can't be parsed is reported with ErrorCategoryGateway too, it isn't an unknown outcome.
+//
+// The raw response is returned whenever it was received.
+func Execute[T any](ctx context.Context, gw Gateway, op TypedOperation[T]) (T, *structures.GatewayResponse, error) {
+	var result T
+	opType := op.GetOperationType()
+
+	gwResponse, err := gw.NewRequestWithContext(ctx, op)
+	if err != nil {
+		return result, gwResponse, withOperationType(err, opType)
+	}
+
+	if gwResponse == nil {
+		return result, nil, newGatewayError(structures.ErrorCategoryTransport, opType, 0, errors.New("no response received"))
+	}
+
+	parsed, parseErr := op.ParseResponse(gwResponse)
+	if parseErr == nil {
+		result = parsed
+	}
+
+	if err = responseError(opType, gwResponse); err != nil {
+		return result, gwResponse, err
+	}
+
+	if parseErr != nil {
+		return result, gwResponse, newGatewayError(structures.ErrorCategoryGateway, opType, gwResponse.StatusCode, parseErr)
+	}
+
+	return result, gwResponse, nil
+}
+
+// responseError returns the Gateway error of the response payload or an unexpected HTTP status as GatewayError
+func responseError(opType structures.OperationType, gwResponse *structures.GatewayResponse) error {
+	if gwErr := structures.NewGatewayError(opType, gwResponse.StatusCode, payloadError(gwResponse)); gwErr != nil {
+		return gwErr
+	}
+
+	if !gwResponse.Successful() {
+		return newGatewayError(structures.ErrorCategoryGateway, opType, gwResponse.StatusCode,
+			fmt.Errorf("unexpected HTTP status %d", gwResponse.StatusCode))
+	}
+
+	return nil
+}
+
+// payloadError returns the Gateway error of a JSON response payload, if any
+func payloadError(gwResponse *structures.GatewayResponse) structures.Error {
+	var parsed struct {
+		Error *structures.Error `json:"error"`
+	}
+	if err := json.Unmarshal(gwResponse.Payload, &parsed); err != nil || parsed.Error == nil {
+		return structures.Error{}
+	}
+
+	return *parsed.Error
+}
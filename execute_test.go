@@ -0,0 +1,132 @@
+package tprogateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/TransactPRO/gw3-go-client/operations/exploring"
+	"github.com/TransactPRO/gw3-go-client/operations/reporting"
+	"github.com/TransactPRO/gw3-go-client/operations/token"
+	"github.com/TransactPRO/gw3-go-client/operations/transactions"
+	"github.com/TransactPRO/gw3-go-client/operations/verify"
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+// Operation assemblies with structured responses are typed operations
+var (
+	_ TypedOperation[*structures.TransactionResponse]         = (*transactions.SMSAssembly)(nil)
+	_ TypedOperation[*structures.TransactionResponse]         = (*transactions.HoldDMSAssembly)(nil)
+	_ TypedOperation[*structures.TransactionResponse]         = (*transactions.ChargeDMSAssembly)(nil)
+	_ TypedOperation[*structures.TransactionResponse]         = (*transactions.CancelAssembly)(nil)
+	_ TypedOperation[*structures.TransactionResponse]         = (*transactions.MOTOAssembly)(nil)
+	_ TypedOperation[*structures.TransactionResponse]         = (*transactions.CreditAssembly)(nil)
+	_ TypedOperation[*structures.TransactionResponse]         = (*transactions.P2PAssembly)(nil)
+	_ TypedOperation[*structures.TransactionResponse]         = (*transactions.B2PAssembly)(nil)
+	_ TypedOperation[*structures.TransactionResponse]         = (*transactions.InitRecurrentSMSAssembly)(nil)
+	_ TypedOperation[*structures.TransactionResponse]         = (*transactions.InitRecurrentDMSAssembly)(nil)
+	_ TypedOperation[*structures.TransactionResponse]         = (*transactions.RecurrentAssembly)(nil)
+	_ TypedOperation[*structures.TransactionResponse]         = (*transactions.RefundAssembly)(nil)
+	_ TypedOperation[*structures.TransactionResponse]         = (*transactions.ReversalAssembly)(nil)
+	_ TypedOperation[*structures.TransactionResponse]         = (*token.CreateTokenAssembly)(nil)
+	_ TypedOperation[*structures.ExploringStatusResponse]     = (*exploring.ExploreStatusAssembly)(nil)
+	_ TypedOperation[*structures.ExploringResultResponse]     = (*exploring.ExploreResultAssembly)(nil)
+	_ TypedOperation[*structures.ExploringHistoryResponse]    = (*exploring.ExploreHistoryAssembly)(nil)
+	_ TypedOperation[*structures.ExploringRecurrentsResponse] = (*exploring.ExploreRecurrentsAssembly)(nil)
+	_ TypedOperation[*structures.ExploringRefundsResponse]    = (*exploring.ExploreRefundsAssembly)(nil)
+	_ TypedOperation[*structures.ExploringLimitsResponse]     = (*exploring.ExploreLimitsAssembly)(nil)
+	_ TypedOperation[*structures.EnrollmentResponse]          = (*verify.ThreeDEnrollmentAssembly)(nil)
+	_ TypedOperation[*structures.CsvReport]                   = (*reporting.ReportAssembly)(nil)
+)
+
+func TestExecute(t *testing.T) {
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		return http.StatusOK, `{"gw":{"gateway-transaction-id":"tx-1","status-code":7}}`
+	})
+	defer server.Close()
+
+	gateCli := newTestClient(t, server)
+	result, gwResponse, err := Execute[*structures.TransactionResponse](context.Background(), gateCli, gateCli.OperationBuilder().NewSms())
+	assert.NoError(t, err)
+	if assert.NotNil(t, result) {
+		assert.Equal(t, "tx-1", result.Gateway.GatewayTransactionID)
+		assert.Equal(t, structures.StatusSuccess, result.Gateway.StatusCode)
+	}
+	if assert.NotNil(t, gwResponse) {
+		assert.Equal(t, http.StatusOK, gwResponse.StatusCode)
+		assert.NotNil(t, gwResponse.Digest)
+	}
+}
+
+func TestExecuteReport(t *testing.T) {
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		return http.StatusOK, "gateway-transaction-id,status-code\ntx-1,7\n"
+	})
+	defer server.Close()
+
+	gateCli := newTestClient(t, server)
+	report, _, err := Execute[*structures.CsvReport](context.Background(), gateCli, gateCli.OperationBuilder().NewReport())
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"gateway-transaction-id", "status-code"}, report.Headers)
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	examples := []struct {
+		name          string
+		status        int
+		body          string
+		expectedError error
+		expectedText  string // prefix, JSON errors differ between Go versions
+		hasResult     bool
+	}{
+		{"decline", http.StatusPaymentRequired, `{"gw":{"gateway-transaction-id":"tx-1","status-code":8},"error":{"code":1106,"message":"Card expired"}}`,
+			structures.ErrHardDecline, "sms: hard decline: 1106 Card expired", true},
+		{"unexpected HTTP status", http.StatusInternalServerError, "Internal Server Error",
+			structures.ErrGateway, "sms: gateway: unexpected HTTP status 500", false},
+		{"malformed payload", http.StatusOK, "not JSON",
+			structures.ErrGateway, "sms: gateway: cannot unmarshal JSON response: ", false},
+	}
+
+	for _, testCase := range examples {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+				return testCase.status, testCase.body
+			})
+			defer server.Close()
+
+			gateCli := newTestClient(t, server)
+			result, gwResponse, err := Execute[*structures.TransactionResponse](context.Background(), gateCli, gateCli.OperationBuilder().NewSms())
+			assert.True(t, errors.Is(err, testCase.expectedError))
+			if assert.Error(t, err) {
+				assert.True(t, strings.HasPrefix(err.Error(), testCase.expectedText), err.Error())
+			}
+			assert.Equal(t, testCase.hasResult, result != nil)
+			if assert.NotNil(t, gwResponse) {
+				assert.Equal(t, testCase.status, gwResponse.StatusCode)
+			}
+
+			var gwErr *structures.GatewayError
+			if assert.True(t, errors.As(err, &gwErr)) {
+				assert.Equal(t, testCase.status, gwErr.HTTPStatus)
+				assert.Equal(t, structures.SMS, gwErr.OperationType)
+			}
+		})
+	}
+}
+
+func TestExecuteRequestFailure(t *testing.T) {
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		return http.StatusOK, "{}"
+	})
+	server.Close()
+
+	gateCli := newTestClient(t, server, WithRetryPolicy(nil))
+	result, gwResponse, err := Execute[*structures.ExploringLimitsResponse](context.Background(), gateCli, gateCli.OperationBuilder().NewGetLimits())
+	assert.Nil(t, result)
+	assert.Nil(t, gwResponse)
+	assert.True(t, errors.Is(err, structures.ErrTransport))
+}
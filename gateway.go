@@ -3,22 +3,27 @@ package tprogateway
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/TransactPRO/gw3-go-client/operations"
+	"github.com/TransactPRO/gw3-go-client/store"
 	"github.com/TransactPRO/gw3-go-client/structures"
 )
 
 // Default API settings
 const (
-	dAPIBaseURI = "https://api.sandbox.transactpro.io"
-	dAPIVersion = "3.0"
+	dAPIBaseURI           = "https://api.sandbox.transactpro.io"
+	dAPIProductionBaseURI = "https://api.transactpro.io"
+	dAPIVersion           = "3.0"
 )
 
 type (
@@ -42,6 +47,34 @@ type (
 		API        *confAPI
 		Auth       *authData
 		HTTPClient http.Client
+
+		environment   Environment
+		customBaseURL bool
+		userAgent     string
+		retryPolicy   *RetryPolicy
+		middlewares   []Middleware
+		observer      Observer
+		replayGuard   *structures.ReplayGuard
+
+		digestAlgorithm    structures.Algorithm
+		digestQOP          map[string]structures.QOP
+		acceptedAlgorithms []structures.Algorithm
+		digestStore        store.DigestStore
+		digestStoreErrors  DigestStoreErrorHandler
+		clock              func() time.Time
+		random             io.Reader
+		dryRun             bool
+		throttles          map[structures.OperationType]*throttle
+	}
+
+	// Gateway is the API of GatewayClient that applications depend on.
+	// Business logic may accept it instead of *GatewayClient, so it can be unit tested with gatewaymock.Gateway.
+	Gateway interface {
+		OperationBuilder() *operations.Builder
+		NewRequest(opData structures.OperationRequestInterface) (*structures.GatewayResponse, error)
+		NewRequestWithContext(ctx context.Context, opData structures.OperationRequestInterface) (*structures.GatewayResponse, error)
+		RecoverTransaction(ctx context.Context, op RecoverableOperation) (*RecoveryResult, error)
+		ResendOrRecover(ctx context.Context, op RecoverableOperation) (*RecoveryResult, error)
 	}
 
 	// GenericRequest describes general request data structure
@@ -52,8 +85,11 @@ type (
 	}
 )
 
-// NewGatewayClient creates new instance of prepared gateway client structure
-func NewGatewayClient(ObjectGUID, SecretKey string) (*GatewayClient, error) {
+var _ Gateway = (*GatewayClient)(nil)
+
+// NewGatewayClient creates new instance of prepared gateway client structure.
+// Without options the client is configured for the sandbox environment.
+func NewGatewayClient(ObjectGUID, SecretKey string, opts ...Option) (*GatewayClient, error) {
 	if ObjectGUID == "" {
 		return nil, errors.New("GUID can't be empty. It's required for merchant authorization")
 	}
@@ -62,20 +98,33 @@ func NewGatewayClient(ObjectGUID, SecretKey string) (*GatewayClient, error) {
 		return nil, errors.New("secret key can't be empty. It's required for merchant authorization")
 	}
 
-	return &GatewayClient{
-		API:  &confAPI{BaseURI: dAPIBaseURI, Version: dAPIVersion},
-		Auth: &authData{ObjectGUID: ObjectGUID, SecretKey: SecretKey},
-	}, nil
+	gc := &GatewayClient{
+		API:         &confAPI{BaseURI: dAPIBaseURI, Version: dAPIVersion},
+		Auth:        &authData{ObjectGUID: ObjectGUID, SecretKey: SecretKey},
+		retryPolicy: DefaultRetryPolicy(),
+		replayGuard: structures.NewReplayGuard(structures.DefaultMaxClockSkew, nil),
+
+		digestAlgorithm:    structures.AlgorithmSHA256,
+		acceptedAlgorithms: structures.SupportedAlgorithms(),
+	}
+
+	for _, opt := range opts {
+		if err := opt(gc); err != nil {
+			return nil, err
+		}
+	}
+
+	return gc, nil
 }
 
 // NewGatewayClientForSession creates new instance of prepared gateway client structure
 // Should be used when active session is available
-func NewGatewayClientForSession(ObjectGUID, SecretKey, SessionID string) (response *GatewayClient, err error) {
+func NewGatewayClientForSession(ObjectGUID, SecretKey, SessionID string, opts ...Option) (response *GatewayClient, err error) {
 	if SessionID == "" {
 		return nil, errors.New("SessionID can't be empty. Session authorization means non-empty session")
 	}
 
-	if response, err = NewGatewayClient(ObjectGUID, SecretKey); err == nil {
+	if response, err = NewGatewayClient(ObjectGUID, SecretKey, opts...); err == nil {
 		response.Auth.SessionID = SessionID
 	}
 
@@ -91,48 +140,136 @@ func (gc *GatewayClient) OperationBuilder() *operations.Builder {
 // GatewayResponse may be non-nil in case of error if a response payload was read
 // but some validation after failed (like digest verification)
 func (gc *GatewayClient) NewRequest(opData structures.OperationRequestInterface) (*structures.GatewayResponse, error) {
+	return gc.NewRequestWithContext(context.Background(), opData)
+}
+
+// NewRequestWithContext method, send HTTP request to Transact Pro API using given context.
+// All returned errors are *structures.GatewayError. If the context is canceled or its deadline
+// is exceeded before the response is read, the error wraps *RequestAbortedError with the context's error.
+// Gateway declines are not reported as errors here, see structures.NewGatewayError.
+// Failed attempts are repeated according to the client's retry policy.
+// In dry-run mode (see WithDryRun) nothing is sent and the error wraps *DryRunError with the prepared request.
+func (gc *GatewayClient) NewRequestWithContext(ctx context.Context, opData structures.OperationRequestInterface) (*structures.GatewayResponse, error) {
+	opType := opData.GetOperationType()
+
+	if ctx == nil {
+		return nil, newGatewayError(structures.ErrorCategoryValidation, opType, 0, errors.New("nil context"))
+	}
+
+	if gc.dryRun {
+		return nil, gc.dryRunError(ctx, opData)
+	}
+
+	if gc.observer != nil {
+		return gc.observe(ctx, opData)
+	}
+
+	return gc.execute(ctx, opData)
+}
+
+// execute sends the operation request, repeating it according to the retry policy
+func (gc *GatewayClient) execute(ctx context.Context, opData structures.OperationRequestInterface) (*structures.GatewayResponse, error) {
+	opType := opData.GetOperationType()
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, newGatewayError(structures.ErrorCategoryTransport, opType, 0, &RequestAbortedError{Err: ctxErr})
+	}
+
+	payload, requestURL, err := gc.payloadAndURL(opData)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		release, err := gc.acquire(ctx, opType)
+		if err != nil {
+			return nil, err
+		}
+
+		gwResponse, err := gc.send(ctx, opType, opData.GetHTTPMethod(), requestURL, payload)
+		release()
+		if !gc.retryPolicy.allowsRetry(attempt, opType, gwResponse, err) {
+			if err == nil {
+				gc.saveDigest(ctx, opData, gwResponse)
+			}
+			return gwResponse, err
+		}
+
+		if waitErr := sleepContext(ctx, gc.retryPolicy.backoff(attempt)); waitErr != nil {
+			return nil, newGatewayError(structures.ErrorCategoryTransport, opType, 0, &RequestAbortedError{Err: waitErr})
+		}
+	}
+}
+
+// payloadAndURL marshals the operation's request body and determines its URL
+func (gc *GatewayClient) payloadAndURL(opData structures.OperationRequestInterface) ([]byte, string, error) {
+	opType := opData.GetOperationType()
+
 	// Build whole payload structure with nested data bundles
 	rawReqData := &GenericRequest{}
 	rawReqData.Auth = gc.Auth
-	if opData.GetOperationType() == structures.Report {
+	if opType == structures.Report {
 		rawReqData.FilterData = opData
 	} else {
 		rawReqData.Data = opData
 	}
 
 	// Get prepared structure of json byte array
-	var bufPayload *bytes.Buffer
+	var payload []byte
 	if opData.GetHTTPMethod() != http.MethodGet {
-		var bufErr error
-		if bufPayload, bufErr = prepareJSONPayload(rawReqData); bufErr != nil {
-			return nil, bufErr
+		bufPayload, bufErr := prepareJSONPayload(rawReqData)
+		if bufErr != nil {
+			return nil, "", newGatewayError(structures.ErrorCategoryValidation, opType, 0, bufErr)
 		}
-	} else {
-		bufPayload = bytes.NewBuffer(nil)
+		payload = bufPayload.Bytes()
 	}
 
 	// Get combined URL path for request to API
-	requestURL, errURLPath := determineURL(gc, opData.GetOperationType())
+	requestURL, errURLPath := determineURL(gc, opType)
 	if errURLPath != nil {
-		return nil, errURLPath
+		return nil, "", newGatewayError(structures.ErrorCategoryValidation, opType, 0, errURLPath)
 	}
 
+	return payload, requestURL, nil
+}
+
+// send makes a single attempt to send HTTP request to Transact Pro API through the middleware chain.
+// Every attempt is signed with a fresh digest.
+func (gc *GatewayClient) send(ctx context.Context, opType structures.OperationType, method, requestURL string, payload []byte) (*structures.GatewayResponse, error) {
 	// Build correct HTTP request
-	newReq, reqDigest, reqErr := buildHTTPRequest(rawReqData.Auth, opData.GetHTTPMethod(), requestURL, bufPayload)
+	newReq, reqDigest, reqErr := buildHTTPRequest(ctx, gc, method, requestURL, bytes.NewBuffer(payload))
 	if reqErr != nil {
-		return nil, reqErr
+		return nil, withOperationType(reqErr, opType)
 	}
 
+	handler := Handler(gc.roundTrip)
+	for i := len(gc.middlewares) - 1; i >= 0; i-- {
+		handler = gc.middlewares[i](handler)
+	}
+
+	return handler(&Exchange{
+		OperationType: opType,
+		Payload:       payload,
+		Request:       newReq,
+		Digest:        reqDigest,
+	})
+}
+
+// roundTrip sends signed HTTP request object, reads the response and verifies its digest
+func (gc *GatewayClient) roundTrip(exchange *Exchange) (*structures.GatewayResponse, error) {
+	ctx := exchange.Request.Context()
+	opType := exchange.OperationType
+
 	// Send HTTP request object
-	resp, respErr := gc.HTTPClient.Do(newReq)
+	resp, respErr := gc.HTTPClient.Do(exchange.Request)
 	if respErr != nil {
-		return nil, respErr
+		return nil, newGatewayError(structures.ErrorCategoryTransport, opType, 0, abortedOr(ctx, respErr))
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	content, payloadErr := ioutil.ReadAll(resp.Body)
 	if payloadErr != nil {
-		return nil, payloadErr
+		return nil, newGatewayError(structures.ErrorCategoryTransport, opType, resp.StatusCode, abortedOr(ctx, payloadErr))
 	}
 
 	gwResponse := structures.NewGatewayResponse(resp, content)
@@ -140,15 +277,16 @@ func (gc *GatewayClient) NewRequest(opData structures.OperationRequestInterface)
 		var digestErr error
 		gwResponse.Digest, digestErr = structures.NewResponseDigest(resp.Header.Get("Authorization"))
 		if digestErr != nil {
-			return gwResponse, digestErr
+			return gwResponse, newGatewayError(structures.ErrorCategoryAuth, opType, resp.StatusCode, digestErr)
 		}
 
-		gwResponse.Digest.OriginalURI = reqDigest.URI
-		gwResponse.Digest.OriginalCnonce = reqDigest.Cnonce
+		gwResponse.Digest.OriginalURI = exchange.Digest.URI
+		gwResponse.Digest.OriginalCnonce = exchange.Digest.Cnonce
 		gwResponse.Digest.Body = gwResponse.Payload
-		digestErr = gwResponse.Digest.Verify(rawReqData.Auth.ObjectGUID, rawReqData.Auth.SecretKey)
+		gwResponse.Digest.AllowedAlgorithms = gc.acceptedAlgorithms
+		digestErr = gwResponse.Digest.VerifyFresh(gc.Auth.ObjectGUID, gc.Auth.SecretKey, gc.replayGuard)
 		if digestErr != nil {
-			return gwResponse, digestErr
+			return gwResponse, newGatewayError(structures.ErrorCategoryAuth, opType, resp.StatusCode, digestErr)
 		}
 	}
 
@@ -200,30 +338,52 @@ func determineURL(gc *GatewayClient, opType structures.OperationType) (string, e
 	return completeURL, nil
 }
 
+// qopFor returns configured digest QOP for given HTTP method
+func (gc *GatewayClient) qopFor(method string) structures.QOP {
+	if qop, ok := gc.digestQOP[method]; ok {
+		return qop
+	}
+
+	if qop, ok := gc.digestQOP[""]; ok {
+		return qop
+	}
+
+	return structures.QopAuthInt
+}
+
 // buildHTTPRequest, accepts prepared body for HTTP
-// Builds NewRequest from http package
-func buildHTTPRequest(auth *authData, method, requestURL string, payload *bytes.Buffer) (*http.Request, *structures.RequestDigest, error) {
+// Builds NewRequestWithContext from http package
+func buildHTTPRequest(ctx context.Context, gc *GatewayClient, method, requestURL string, payload *bytes.Buffer) (*http.Request, *structures.RequestDigest, error) {
 	var err error
 
 	var parsedURL *url.URL
 	if parsedURL, err = url.Parse(requestURL); err != nil {
-		return nil, nil, fmt.Errorf("incorrect URL: %s", err)
+		return nil, nil, newGatewayError(structures.ErrorCategoryValidation, "", 0, fmt.Errorf("incorrect URL: %s", err))
 	}
 
 	var requestDigest *structures.RequestDigest
-	if requestDigest, err = structures.NewRequestDigest(auth.ObjectGUID, auth.SecretKey, parsedURL.Path, payload.Bytes()); err != nil {
-		return nil, nil, err
+	if requestDigest, err = structures.NewRequestDigest(
+		gc.Auth.ObjectGUID,
+		gc.Auth.SecretKey,
+		parsedURL.Path,
+		payload.Bytes(),
+		structures.WithAlgorithm(gc.digestAlgorithm),
+		structures.WithQOP(gc.qopFor(method)),
+		structures.WithClock(gc.clock),
+		structures.WithRandom(gc.random),
+	); err != nil {
+		return nil, nil, newGatewayError(structures.ErrorCategoryAuth, "", 0, err)
 	}
 
 	var digest string
 	if digest, err = requestDigest.CreateHeader(); err != nil {
-		return nil, nil, err
+		return nil, nil, newGatewayError(structures.ErrorCategoryAuth, "", 0, err)
 	}
 
 	// Build whole HTTP request with payload data
 	var newReq *http.Request
-	if newReq, err = http.NewRequest(method, requestURL, payload); err != nil {
-		return nil, nil, err
+	if newReq, err = http.NewRequestWithContext(ctx, method, requestURL, payload); err != nil {
+		return nil, nil, newGatewayError(structures.ErrorCategoryValidation, "", 0, err)
 	}
 
 	// Set default headers for new request
@@ -231,6 +391,9 @@ func buildHTTPRequest(auth *authData, method, requestURL string, payload *bytes.
 	if method != http.MethodGet {
 		newReq.Header.Set("Content-type", "application/json")
 	}
+	if gc.userAgent != "" {
+		newReq.Header.Set("User-Agent", gc.userAgent)
+	}
 
 	return newReq, requestDigest, nil
 }
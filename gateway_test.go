@@ -1,8 +1,14 @@
 package tprogateway
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/TransactPRO/gw3-go-client/structures"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -34,3 +40,91 @@ func TestNewGatewayClientForSessionIncorrectData(t *testing.T) {
 	_, err3 := NewGatewayClientForSession("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey", "")
 	assert.EqualError(t, err3, "SessionID can't be empty. Session authorization means non-empty session")
 }
+
+func TestNewRequestWithContextCanceledBeforeStart(t *testing.T) {
+	gateCli, _ := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	resp, err := gateCli.NewRequestWithContext(ctx, gateCli.OperationBuilder().NewSms())
+	assert.Nil(t, resp)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	var abortedErr *RequestAbortedError
+	assert.True(t, errors.As(err, &abortedErr))
+	assert.True(t, errors.Is(err, structures.ErrTransport))
+	assert.EqualError(t, err, "sms: transport: request aborted: context canceled")
+}
+
+func TestNewRequestWithContextDeadlineExceeded(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	gateCli, _ := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey")
+	gateCli.API.BaseURI = server.URL
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	resp, err := gateCli.NewRequestWithContext(ctx, gateCli.OperationBuilder().NewSms())
+	assert.Nil(t, resp)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	var abortedErr *RequestAbortedError
+	assert.True(t, errors.As(err, &abortedErr))
+}
+
+func TestNewRequestDelegatesWithBackgroundContext(t *testing.T) {
+	var called bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		assert.NotEmpty(t, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	gateCli, _ := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey")
+	gateCli.API.BaseURI = server.URL
+
+	resp, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.NoError(t, err)
+	assert.True(t, called)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestNewRequestDigestFailureIsAuthError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	gateCli, _ := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey", WithBaseURL(server.URL))
+
+	resp, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.NotNil(t, resp)
+	assert.True(t, errors.Is(err, structures.ErrAuth))
+	assert.False(t, errors.Is(err, structures.ErrTransport))
+
+	var gwErr *structures.GatewayError
+	assert.True(t, errors.As(err, &gwErr))
+	assert.Equal(t, http.StatusOK, gwErr.HTTPStatus)
+	assert.Equal(t, structures.SMS, gwErr.OperationType)
+	assert.EqualError(t, err, "sms: auth: authorization header is missing")
+}
+
+func TestNewRequestConfigurationIsValidationError(t *testing.T) {
+	gateCli, _ := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey")
+	gateCli.API.Version = ""
+
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.True(t, errors.Is(err, structures.ErrValidation))
+	assert.EqualError(t, err, "sms: validation: gateway client's Version is empty in, API settings")
+}
@@ -0,0 +1,286 @@
+// Package gatewaymock provides a programmable in-memory implementation of tprogateway.Gateway
+// for unit tests of code depending on the Gateway client. No HTTP requests are made:
+// every call is recorded and answered with canned responses.
+//
+// Use gatewaytest package instead to test the client itself against a fake Gateway server.
+package gatewaymock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	tprogateway "github.com/TransactPRO/gw3-go-client"
+	"github.com/TransactPRO/gw3-go-client/operations"
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// AnyOperation programs responses to operations that have no responses of their own
+const AnyOperation structures.OperationType = ""
+
+// Names of recorded Gateway methods
+const (
+	MethodNewRequest         = "NewRequest"
+	MethodRecoverTransaction = "RecoverTransaction"
+	MethodResendOrRecover    = "ResendOrRecover"
+)
+
+// ErrUnexpectedCall is returned for operations without programmed responses
+var ErrUnexpectedCall = errors.New("gatewaymock: no response programmed")
+
+type (
+	// Call is a recorded Gateway method call
+	Call struct {
+		// Method is one of Method* constants, NewRequestWithContext calls are recorded as MethodNewRequest
+		Method        string
+		OperationType structures.OperationType
+		Operation     structures.OperationRequestInterface
+		// Payload is the operation's JSON, as the client would send it in request data
+		Payload []byte
+	}
+
+	// Handler answers an operation request
+	Handler func(ctx context.Context, op structures.OperationRequestInterface) (*structures.GatewayResponse, error)
+
+	// RecoveryHandler answers a recovery request
+	RecoveryHandler func(ctx context.Context, op tprogateway.RecoverableOperation) (*tprogateway.RecoveryResult, error)
+)
+
+// Gateway is a programmable tprogateway.Gateway, safe for concurrent use.
+// Operations are answered with responses programmed with RespondOnce, Respond and HandleFunc, in that order.
+type Gateway struct {
+	// RecoverTransactionFunc answers RecoverTransaction calls, the transaction is reported as not found if nil
+	RecoverTransactionFunc RecoveryHandler
+	// ResendOrRecoverFunc answers ResendOrRecover calls. If nil, the operation is sent again
+	// unless RecoverTransactionFunc finds it, like GatewayClient does.
+	ResendOrRecoverFunc RecoveryHandler
+
+	mu       sync.Mutex
+	calls    []Call
+	once     map[structures.OperationType][]Handler
+	handlers map[structures.OperationType]Handler
+}
+
+var _ tprogateway.Gateway = (*Gateway)(nil)
+
+// New creates Gateway without programmed responses
+func New() *Gateway {
+	return &Gateway{
+		once:     make(map[structures.OperationType][]Handler),
+		handlers: make(map[structures.OperationType]Handler),
+	}
+}
+
+// NewResponse creates canned response with given HTTP status code and payload.
+// The payload is marshaled to JSON unless it's a string or []byte.
+func NewResponse(statusCode int, payload interface{}) *structures.GatewayResponse {
+	var body []byte
+	switch value := payload.(type) {
+	case []byte:
+		body = value
+	case string:
+		body = []byte(value)
+	default:
+		var err error
+		if body, err = json.Marshal(value); err != nil {
+			panic(fmt.Sprintf("gatewaymock: cannot marshal response payload: %s", err))
+		}
+	}
+
+	return &structures.GatewayResponse{
+		Response: &http.Response{
+			Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+			StatusCode:    statusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"application/json"}},
+			Body:          ioutil.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+		},
+		Payload: body,
+	}
+}
+
+// Respond answers every request of given operation type with the response and error
+func (g *Gateway) Respond(opType structures.OperationType, response *structures.GatewayResponse, err error) {
+	g.HandleFunc(opType, canned(response, err))
+}
+
+// RespondOnce answers the next request of given operation type with the response and error.
+// Responses programmed with RespondOnce are used in order, before the ones programmed with Respond or HandleFunc.
+func (g *Gateway) RespondOnce(opType structures.OperationType, response *structures.GatewayResponse, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.once[opType] = append(g.once[opType], canned(response, err))
+}
+
+// HandleFunc answers every request of given operation type with the handler
+func (g *Gateway) HandleFunc(opType structures.OperationType, handler Handler) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.handlers[opType] = handler
+}
+
+// Calls returns recorded calls in order
+func (g *Gateway) Calls() []Call {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return append([]Call(nil), g.calls...)
+}
+
+// CallsOf returns recorded calls of given operation type in order
+func (g *Gateway) CallsOf(opType structures.OperationType) []Call {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var result []Call
+	for _, call := range g.calls {
+		if call.OperationType == opType {
+			result = append(result, call)
+		}
+	}
+
+	return result
+}
+
+// Reset forgets recorded calls and programmed responses, recovery handlers are kept
+func (g *Gateway) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.calls = nil
+	g.once = make(map[structures.OperationType][]Handler)
+	g.handlers = make(map[structures.OperationType]Handler)
+}
+
+// OperationBuilder returns builder for operations, like GatewayClient does
+func (g *Gateway) OperationBuilder() *operations.Builder {
+	return &operations.Builder{}
+}
+
+// NewRequest records the call and answers it with the programmed response
+func (g *Gateway) NewRequest(opData structures.OperationRequestInterface) (*structures.GatewayResponse, error) {
+	return g.NewRequestWithContext(context.Background(), opData)
+}
+
+// NewRequestWithContext records the call and answers it with the programmed response
+func (g *Gateway) NewRequestWithContext(ctx context.Context, opData structures.OperationRequestInterface) (*structures.GatewayResponse, error) {
+	g.record(MethodNewRequest, opData)
+	return g.respond(ctx, opData)
+}
+
+// RecoverTransaction records the call and answers it with RecoverTransactionFunc
+func (g *Gateway) RecoverTransaction(ctx context.Context, op tprogateway.RecoverableOperation) (*tprogateway.RecoveryResult, error) {
+	g.record(MethodRecoverTransaction, op)
+	return g.recoverTransaction(ctx, op)
+}
+
+// ResendOrRecover records the call and answers it with ResendOrRecoverFunc.
+// Without it, the operation is answered with the programmed response unless RecoverTransactionFunc finds it.
+func (g *Gateway) ResendOrRecover(ctx context.Context, op tprogateway.RecoverableOperation) (*tprogateway.RecoveryResult, error) {
+	g.record(MethodResendOrRecover, op)
+
+	if g.ResendOrRecoverFunc != nil {
+		return g.ResendOrRecoverFunc(ctx, op)
+	}
+
+	recovered, err := g.recoverTransaction(ctx, op)
+	if err != nil || !recovered.SafeToResend() {
+		return recovered, err
+	}
+
+	response, err := g.respond(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := new(structures.TransactionResponse)
+	if err = response.ParseJSON(parsed); err != nil {
+		return nil, err
+	}
+
+	return &tprogateway.RecoveryResult{
+		Found:                true,
+		Resent:               true,
+		GatewayTransactionID: parsed.Gateway.GatewayTransactionID,
+		Status:               parsed.Gateway.StatusCode,
+		Result:               parsed,
+	}, nil
+}
+
+func (g *Gateway) recoverTransaction(ctx context.Context, op tprogateway.RecoverableOperation) (*tprogateway.RecoveryResult, error) {
+	if g.RecoverTransactionFunc != nil {
+		return g.RecoverTransactionFunc(ctx, op)
+	}
+
+	if op.GetMerchantTransactionID() == "" {
+		return nil, tprogateway.ErrMissingMerchantTransactionID
+	}
+
+	return &tprogateway.RecoveryResult{}, nil
+}
+
+func (g *Gateway) record(method string, op structures.OperationRequestInterface) {
+	call := Call{Method: method, OperationType: op.GetOperationType(), Operation: op}
+	if payload, err := json.Marshal(op); err == nil {
+		call.Payload = payload
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.calls = append(g.calls, call)
+}
+
+// respond finds the handler for the operation and calls it without holding the lock
+func (g *Gateway) respond(ctx context.Context, op structures.OperationRequestInterface) (*structures.GatewayResponse, error) {
+	opType := op.GetOperationType()
+
+	g.mu.Lock()
+	var handler Handler
+	for _, key := range []structures.OperationType{opType, AnyOperation} {
+		if queue := g.once[key]; len(queue) > 0 {
+			handler, g.once[key] = queue[0], queue[1:]
+		} else {
+			handler = g.handlers[key]
+		}
+		if handler != nil {
+			break
+		}
+	}
+	g.mu.Unlock()
+
+	if handler == nil {
+		return nil, fmt.Errorf("%w for %s", ErrUnexpectedCall, opType)
+	}
+
+	return handler(ctx, op)
+}
+
+// canned returns handler answering with copies of the response, so every caller may read its body
+func canned(response *structures.GatewayResponse, err error) Handler {
+	return func(ctx context.Context, op structures.OperationRequestInterface) (*structures.GatewayResponse, error) {
+		if response == nil {
+			return nil, err
+		}
+
+		result := *response
+		if response.Response != nil {
+			httpResponse := *response.Response
+			httpResponse.Header = response.Header.Clone()
+			httpResponse.Body = ioutil.NopCloser(bytes.NewReader(response.Payload))
+			result.Response = &httpResponse
+		}
+
+		return &result, err
+	}
+}
@@ -0,0 +1,166 @@
+package gatewaymock
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	tprogateway "github.com/TransactPRO/gw3-go-client"
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+// chargeCustomer is business logic under test, depending only on tprogateway.Gateway
+func chargeCustomer(gw tprogateway.Gateway, amount int) (string, error) {
+	sms := gw.OperationBuilder().NewSms()
+	sms.PaymentMethod.Pan = "4111111111111111"
+	sms.Money.Amount = amount
+	sms.Money.Currency = "EUR"
+
+	response, err := gw.NewRequest(sms)
+	if err != nil {
+		return "", err
+	}
+
+	parsed, err := sms.ParseResponse(response)
+	if err != nil {
+		return "", err
+	}
+
+	return parsed.Gateway.GatewayTransactionID, nil
+}
+
+func smsResponse(gatewayTransactionID string) *structures.GatewayResponse {
+	payload := structures.TransactionResponse{}
+	payload.Gateway.GatewayTransactionID = gatewayTransactionID
+	payload.Gateway.StatusCode = structures.StatusSuccess
+
+	return NewResponse(http.StatusOK, payload)
+}
+
+func TestGatewayRecordsCalls(t *testing.T) {
+	gw := New()
+	gw.Respond(structures.SMS, smsResponse("tx-1"), nil)
+
+	id, err := chargeCustomer(gw, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, "tx-1", id)
+
+	calls := gw.Calls()
+	if assert.Len(t, calls, 1) {
+		assert.Equal(t, MethodNewRequest, calls[0].Method)
+		assert.Equal(t, structures.SMS, calls[0].OperationType)
+		assert.Contains(t, string(calls[0].Payload), `"amount":100`)
+		assert.Contains(t, string(calls[0].Payload), `"pan":"4111111111111111"`)
+	}
+	assert.Len(t, gw.CallsOf(structures.SMS), 1)
+	assert.Empty(t, gw.CallsOf(structures.DMSHold))
+}
+
+func TestGatewayResponsesOrder(t *testing.T) {
+	gw := New()
+	failure := errors.New("connection reset")
+	gw.Respond(structures.SMS, smsResponse("tx-permanent"), nil)
+	gw.RespondOnce(structures.SMS, nil, failure)
+	gw.RespondOnce(structures.SMS, smsResponse("tx-once"), nil)
+
+	_, err := chargeCustomer(gw, 100)
+	assert.Equal(t, failure, err)
+
+	for _, expected := range []string{"tx-once", "tx-permanent", "tx-permanent"} {
+		id, err := chargeCustomer(gw, 100)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, id)
+	}
+}
+
+func TestGatewayCannedResponseBodyIsReadable(t *testing.T) {
+	gw := New()
+	gw.Respond(AnyOperation, NewResponse(http.StatusOK, `{"ok":true}`), nil)
+
+	for i := 0; i < 2; i++ {
+		response, err := gw.NewRequest(gw.OperationBuilder().NewGetLimits())
+		if assert.NoError(t, err) {
+			body, _ := ioutil.ReadAll(response.Body)
+			assert.Equal(t, `{"ok":true}`, string(body))
+		}
+	}
+}
+
+func TestGatewayHandleFunc(t *testing.T) {
+	gw := New()
+	gw.HandleFunc(structures.SMS, func(ctx context.Context, op structures.OperationRequestInterface) (*structures.GatewayResponse, error) {
+		sms := op.(interface{ GetMerchantTransactionID() string })
+		return smsResponse("for-" + sms.GetMerchantTransactionID()), nil
+	})
+
+	sms := gw.OperationBuilder().NewSms()
+	sms.GeneralData.OrderData.MerchantTransactionID = "order-1"
+	response, err := gw.NewRequest(sms)
+	assert.NoError(t, err)
+
+	parsed, err := sms.ParseResponse(response)
+	assert.NoError(t, err)
+	assert.Equal(t, "for-order-1", parsed.Gateway.GatewayTransactionID)
+}
+
+func TestGatewayUnexpectedCall(t *testing.T) {
+	gw := New()
+
+	_, err := gw.NewRequest(gw.OperationBuilder().NewSms())
+	assert.True(t, errors.Is(err, ErrUnexpectedCall))
+	assert.EqualError(t, err, "gatewaymock: no response programmed for sms")
+
+	gw.Respond(structures.SMS, smsResponse("tx-1"), nil)
+	gw.Reset()
+	_, err = gw.NewRequest(gw.OperationBuilder().NewSms())
+	assert.True(t, errors.Is(err, ErrUnexpectedCall))
+	assert.Len(t, gw.Calls(), 1)
+}
+
+func TestGatewayRecovery(t *testing.T) {
+	gw := New()
+	gw.Respond(structures.SMS, smsResponse("tx-resent"), nil)
+
+	sms := gw.OperationBuilder().NewSms()
+	_, err := gw.RecoverTransaction(context.Background(), sms)
+	assert.Equal(t, tprogateway.ErrMissingMerchantTransactionID, err)
+
+	sms.GeneralData.OrderData.MerchantTransactionID = "order-1"
+	recovered, err := gw.RecoverTransaction(context.Background(), sms)
+	assert.NoError(t, err)
+	assert.True(t, recovered.SafeToResend())
+
+	resent, err := gw.ResendOrRecover(context.Background(), sms)
+	assert.NoError(t, err)
+	assert.True(t, resent.Resent)
+	assert.Equal(t, "tx-resent", resent.GatewayTransactionID)
+
+	gw.RecoverTransactionFunc = func(ctx context.Context, op tprogateway.RecoverableOperation) (*tprogateway.RecoveryResult, error) {
+		return &tprogateway.RecoveryResult{Found: true, GatewayTransactionID: "tx-existing"}, nil
+	}
+	recovered, err = gw.ResendOrRecover(context.Background(), sms)
+	assert.NoError(t, err)
+	assert.False(t, recovered.Resent)
+	assert.Equal(t, "tx-existing", recovered.GatewayTransactionID)
+
+	var methods []string
+	for _, call := range gw.Calls() {
+		methods = append(methods, call.Method)
+	}
+	assert.Equal(t, []string{MethodRecoverTransaction, MethodRecoverTransaction, MethodResendOrRecover, MethodResendOrRecover}, methods)
+}
+
+func TestGatewayWithExecute(t *testing.T) {
+	gw := New()
+	gw.Respond(structures.SMS, smsResponse("tx-1"), nil)
+
+	result, _, err := tprogateway.Execute[*structures.TransactionResponse](context.Background(), gw, gw.OperationBuilder().NewSms())
+	assert.NoError(t, err)
+	assert.Equal(t, "tx-1", result.Gateway.GatewayTransactionID)
+
+	_, _, err = tprogateway.Execute[*structures.TransactionResponse](context.Background(), gw, gw.OperationBuilder().NewRefund())
+	assert.True(t, errors.Is(err, ErrUnexpectedCall))
+}
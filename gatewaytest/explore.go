@@ -0,0 +1,193 @@
+package gatewaytest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// Limit counter types reported by Server
+const (
+	CounterSuccessAmount = "TR_SUCCESS_AMOUNT"
+	CounterSuccessCount  = "TR_SUCCESS_COUNT"
+)
+
+// reportHeaders are the columns of the CSV report
+var reportHeaders = []string{
+	"gateway-transaction-id", "merchant-transaction-id", "parent-gateway-transaction-id", "operation",
+	"status-code", "status-text", "amount", "currency", "card-mask", "date-created", "date-finished",
+}
+
+// explore answers transaction exploring requests with one item per requested transaction,
+// unknown transactions are reported as items with an error
+func (s *Server) explore(opType structures.OperationType, data *requestData) (int, interface{}) {
+	ids := append([]string(nil), data.CommandData.GatewayTransactionIDs...)
+	for _, merchantID := range data.CommandData.MerchantTransactionIDs {
+		if gatewayID, ok := s.byMerchant[merchantID]; ok {
+			ids = append(ids, gatewayID)
+		} else {
+			ids = append(ids, merchantID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return errorResult(http.StatusBadRequest, structures.EecInputValidationFailed, "transaction IDs are required")
+	}
+
+	result := exploringResponse{Transactions: make([]interface{}, 0, len(ids))}
+	for _, id := range ids {
+		item := exploringItem{GatewayTransactionID: id}
+		tx, ok := s.transactions[id]
+		if !ok {
+			item.Error = &structures.Error{Code: structures.EecWrongGwUniqID, Message: "transaction not found"}
+			result.Transactions = append(result.Transactions, item)
+			continue
+		}
+
+		switch opType {
+		case structures.ExploringStatus:
+			result.Transactions = append(result.Transactions, statusItem{exploringItem: item, Status: []structures.TransactionStatus{{
+				GatewayTransactionID: tx.GatewayTransactionID,
+				StatusCode:           tx.Status,
+				StatusText:           StatusText(tx.Status),
+				StatusCodeGeneral:    tx.Status,
+				StatusTextGeneral:    StatusText(tx.Status),
+				CardMask:             tx.CardMask,
+			}}})
+		case structures.ExploringResult:
+			result.Transactions = append(result.Transactions, resultItem{
+				exploringItem: item,
+				DateCreated:   formatTime(tx.CreatedAt),
+				DateFinished:  formatTime(tx.FinishedAt),
+				ResultData:    s.transactionResponse(tx),
+			})
+		case structures.ExploringHistory:
+			history := make([]historyEvent, 0, len(tx.History))
+			for _, change := range tx.History {
+				history = append(history, historyEvent{
+					DateUpdated:   formatTime(change.Time),
+					StatusCodeNew: change.New,
+					StatusCodeOld: change.Old,
+					StatusTextNew: StatusText(change.New),
+					StatusTextOld: StatusText(change.Old),
+				})
+			}
+			result.Transactions = append(result.Transactions, historyItem{exploringItem: item, History: history})
+		case structures.ExploringRecurrents:
+			result.Transactions = append(result.Transactions, recurrentsItem{
+				exploringItem: item,
+				Recurrents:    s.children(tx, structures.RecurrentSMS, structures.RecurrentDMS),
+			})
+		case structures.ExploringRefunds:
+			result.Transactions = append(result.Transactions, refundsItem{
+				exploringItem: item,
+				Refunds:       s.children(tx, structures.Refund),
+			})
+		}
+	}
+
+	return http.StatusOK, result
+}
+
+// children returns transactions of given types made for the parent transaction, in creation order
+func (s *Server) children(parent *Transaction, opTypes ...structures.OperationType) []transactionInfo {
+	result := make([]transactionInfo, 0)
+	for _, id := range s.order {
+		tx := s.transactions[id]
+		if tx.ParentGatewayTransactionID != parent.GatewayTransactionID {
+			continue
+		}
+
+		for _, opType := range opTypes {
+			if tx.OperationType == opType {
+				result = append(result, tx.info(s.ObjectGUID))
+			}
+		}
+	}
+
+	return result
+}
+
+// limits reports the object's counters of successful transactions per currency
+func (s *Server) limits() (int, interface{}) {
+	amounts := make(map[string]int)
+	counts := make(map[string]int)
+	for _, tx := range s.transactions {
+		if tx.Status == structures.StatusSuccess && tx.Currency != "" {
+			amounts[tx.Currency] += tx.Amount
+			counts[tx.Currency]++
+		}
+	}
+
+	currencies := make([]string, 0, len(amounts))
+	for currency := range amounts {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	result := structures.ExploringLimitsResponse{Type: "account", Title: s.ObjectGUID, Limits: []structures.Limit{}}
+	for _, currency := range currencies {
+		result.Limits = append(result.Limits,
+			structures.Limit{CounterType: CounterSuccessAmount, Currency: currency, Value: number(amounts[currency])},
+			structures.Limit{CounterType: CounterSuccessCount, Currency: currency, Value: number(counts[currency])},
+		)
+	}
+
+	return http.StatusOK, result
+}
+
+// report returns all transactions matching the filters as CSV, in creation order
+func (s *Server) report(filter *reportData) (int, []byte) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	_ = writer.Write(reportHeaders)
+
+	for _, id := range s.order {
+		tx := s.transactions[id]
+		if !inRange(tx.CreatedAt.Unix(), filter.DateCreatedFrom, filter.DateCreatedTo) ||
+			!inRange(tx.FinishedAt.Unix(), filter.DateFinishedFrom, filter.DateFinishedTo) {
+			continue
+		}
+
+		_ = writer.Write([]string{
+			tx.GatewayTransactionID, tx.MerchantTransactionID, tx.ParentGatewayTransactionID, string(tx.OperationType),
+			strconv.Itoa(int(tx.Status)), StatusText(tx.Status), strconv.Itoa(tx.Amount), tx.Currency, tx.CardMask,
+			formatTime(tx.CreatedAt), formatTime(tx.FinishedAt),
+		})
+	}
+	writer.Flush()
+
+	return http.StatusOK, buffer.Bytes()
+}
+
+// verifyEnrollment reports every card as not enrolled to 3-D Secure
+func (s *Server) verifyEnrollment(data *requestData) (int, interface{}) {
+	if data.Pan == "" {
+		return errorResult(http.StatusBadRequest, structures.EecInputValidationFailed, "pan is required")
+	}
+
+	return http.StatusOK, enrollmentResponse{Enrollment: "n"}
+}
+
+// verifyCard completes card verification of an existing transaction
+func (s *Server) verifyCard(data *requestData) (int, interface{}) {
+	tx, status, failure := s.parent(data.GatewayTransactionID)
+	if tx == nil {
+		return status, failure
+	}
+
+	return http.StatusOK, s.transactionResponse(tx)
+}
+
+func inRange(value int64, from, to *int64) bool {
+	return (from == nil || value >= *from) && (to == nil || value <= *to)
+}
+
+func number(value int) json.Number {
+	return json.Number(strconv.Itoa(value))
+}
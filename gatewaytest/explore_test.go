@@ -0,0 +1,197 @@
+package gatewaytest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExploreStatus(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	_, paid := perform(t, client, newSMS(client, 100))
+
+	status := client.OperationBuilder().NewGetStatus()
+	status.CommandData.GWTransactionIDs = []string{"unknown"}
+	status.CommandData.MerchantTransactionIDs = []string{"order-1"}
+	response, err := client.NewRequest(status)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	result, err := status.ParseResponse(response)
+	if !assert.NoError(t, err) || !assert.Len(t, result.Transactions, 2) {
+		return
+	}
+
+	if assert.NotNil(t, result.Transactions[0].Error) {
+		assert.Equal(t, structures.EecWrongGwUniqID, result.Transactions[0].Error.Code)
+	}
+
+	assert.Equal(t, paid.Gateway.GatewayTransactionID, result.Transactions[1].GatewayTransactionID)
+	if assert.Len(t, result.Transactions[1].Status, 1) {
+		assert.Equal(t, structures.StatusSuccess, result.Transactions[1].Status[0].StatusCode)
+		assert.Equal(t, structures.CardFamilyUnknown, result.Transactions[1].Status[0].CardFamily)
+		assert.Equal(t, "411111***1111", result.Transactions[1].Status[0].CardMask)
+	}
+}
+
+func TestExploreResultAndHistory(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	_, paid := perform(t, client, newSMS(client, 100))
+	paymentID := paid.Gateway.GatewayTransactionID
+
+	reversal := client.OperationBuilder().NewReversal()
+	reversal.CommandData.GWTransactionID = paymentID
+	perform(t, client, reversal)
+
+	resultOp := client.OperationBuilder().NewGetResult()
+	resultOp.CommandData.GWTransactionIDs = []string{paymentID}
+	response, err := client.NewRequest(resultOp)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	results, err := resultOp.ParseResponse(response)
+	if assert.NoError(t, err) && assert.Len(t, results.Transactions, 1) {
+		assert.Equal(t, structures.StatusReversed, results.Transactions[0].ResultData.Gateway.StatusCode)
+	}
+
+	historyOp := client.OperationBuilder().NewGetHistory()
+	historyOp.CommandData.GWTransactionIDs = []string{paymentID}
+	response, err = client.NewRequest(historyOp)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	history, err := historyOp.ParseResponse(response)
+	if assert.NoError(t, err) && assert.Len(t, history.Transactions, 1) && assert.Len(t, history.Transactions[0].History, 2) {
+		assert.Equal(t, structures.StatusInit, history.Transactions[0].History[0].StatusCodeOld)
+		assert.Equal(t, structures.StatusSuccess, history.Transactions[0].History[0].StatusCodeNew)
+		assert.Equal(t, structures.StatusReversed, history.Transactions[0].History[1].StatusCodeNew)
+		assert.Equal(t, "REVERSED", history.Transactions[0].History[1].StatusTextNew)
+	}
+}
+
+func TestExploreRefundsAndRecurrents(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	_, paid := perform(t, client, newSMS(client, 100))
+	paymentID := paid.Gateway.GatewayTransactionID
+
+	refund := client.OperationBuilder().NewRefund()
+	refund.CommandData.GWTransactionID = paymentID
+	refund.Money.Amount = 30
+	_, refunded := perform(t, client, refund)
+
+	refundsOp := client.OperationBuilder().NewGetRefunds()
+	refundsOp.CommandData.GWTransactionIDs = []string{paymentID}
+	response, err := client.NewRequest(refundsOp)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	refunds, err := refundsOp.ParseResponse(response)
+	if assert.NoError(t, err) && assert.Len(t, refunds.Transactions, 1) && assert.Len(t, refunds.Transactions[0].Refunds, 1) {
+		item := refunds.Transactions[0].Refunds[0]
+		assert.Equal(t, refunded.Gateway.GatewayTransactionID, item.GatewayTransactionID)
+		assert.Equal(t, "30", item.Amount.String())
+		assert.Equal(t, structures.StatusRefundSuccess, item.StatusCode)
+		assert.Equal(t, testObjectGUID, item.AccountGUID)
+	}
+
+	recurrentsOp := client.OperationBuilder().NewGetRecurrents()
+	recurrentsOp.CommandData.GWTransactionIDs = []string{paymentID}
+	response, err = client.NewRequest(recurrentsOp)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	recurrents, err := recurrentsOp.ParseResponse(response)
+	if assert.NoError(t, err) && assert.Len(t, recurrents.Transactions, 1) {
+		assert.Empty(t, recurrents.Transactions[0].Subsequent)
+	}
+}
+
+func TestExploreLimits(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	perform(t, client, newSMS(client, 100))
+	perform(t, client, newSMS(client, 250))
+
+	limitsOp := client.OperationBuilder().NewGetLimits()
+	response, err := client.NewRequest(limitsOp)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	limits, err := limitsOp.ParseResponse(response)
+	if assert.NoError(t, err) && assert.Len(t, limits.Limits, 2) {
+		assert.Equal(t, structures.Limit{CounterType: CounterSuccessAmount, Currency: "EUR", Value: "350"}, limits.Limits[0])
+		assert.Equal(t, structures.Limit{CounterType: CounterSuccessCount, Currency: "EUR", Value: "2"}, limits.Limits[1])
+	}
+}
+
+func TestReport(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	_, paid := perform(t, client, newSMS(client, 100))
+
+	reportOp := client.OperationBuilder().NewReport()
+	response, err := client.NewRequest(reportOp)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "text/csv", response.Header.Get("Content-Type"))
+
+	report, err := reportOp.ParseResponse(response)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var rows []map[string]string
+	assert.NoError(t, report.Iterate(func(row map[string]string) bool {
+		rows = append(rows, row)
+		return true
+	}))
+
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, paid.Gateway.GatewayTransactionID, rows[0]["gateway-transaction-id"])
+		assert.Equal(t, "sms", rows[0]["operation"])
+		assert.Equal(t, "7", rows[0]["status-code"])
+		assert.Equal(t, "100", rows[0]["amount"])
+	}
+}
+
+func TestVerify(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	enrollmentOp := client.OperationBuilder().NewVerify3dEnrollment()
+	enrollmentOp.Pan = testPAN
+	enrollmentOp.Currency = "EUR"
+	response, err := client.NewRequest(enrollmentOp)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	enrollment, err := enrollmentOp.ParseResponse(response)
+	if assert.NoError(t, err) {
+		assert.Equal(t, structures.EnrollmentNo, enrollment.Enrollment)
+	}
+
+	verifyOp := client.OperationBuilder().NewVerifyCard()
+	verifyOp.GWTransactionID = "unknown"
+	response, err = client.NewRequest(verifyOp)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	}
+}
@@ -0,0 +1,277 @@
+package gatewaytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/TransactPRO/gw3-go-client/redact"
+)
+
+// RecorderMode tells whether Recorder sends requests or replays recorded responses
+type RecorderMode int
+
+// Recorder modes
+const (
+	// ModeRecord sends requests with the underlying transport and records the exchanges
+	ModeRecord RecorderMode = iota
+	// ModeReplay answers requests with recorded responses without any network access
+	ModeReplay
+)
+
+type (
+	// Cassette is a sequence of recorded exchanges, stored as a JSON file
+	Cassette struct {
+		Interactions []Interaction `json:"interactions"`
+	}
+
+	// Interaction is one recorded request and its response
+	Interaction struct {
+		Request  RecordedRequest  `json:"request"`
+		Response RecordedResponse `json:"response"`
+	}
+
+	// RecordedRequest is a request with cardholder data and secrets redacted
+	RecordedRequest struct {
+		Method string      `json:"method"`
+		URL    string      `json:"url"`
+		Header http.Header `json:"header,omitempty"`
+		Body   string      `json:"body,omitempty"`
+	}
+
+	// RecordedResponse is a response with cardholder data and secrets redacted
+	RecordedResponse struct {
+		StatusCode int         `json:"status-code"`
+		Header     http.Header `json:"header,omitempty"`
+		Body       string      `json:"body,omitempty"`
+	}
+)
+
+// LoadCassette reads a cassette file
+func LoadCassette(path string) (*Cassette, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load cassette: %w", err)
+	}
+
+	result := new(Cassette)
+	if err = json.Unmarshal(raw, result); err != nil {
+		return nil, fmt.Errorf("cannot load cassette %s: %w", path, err)
+	}
+
+	return result, nil
+}
+
+// Save writes the cassette to a file, creating missing directories
+func (c *Cassette) Save(path string) error {
+	raw, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot save cassette: %w", err)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+		err = ioutil.WriteFile(path, raw, 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot save cassette: %w", err)
+	}
+
+	return nil
+}
+
+// Recorder is an http.RoundTripper recording Gateway exchanges to a cassette and replaying them later.
+// Use it as GatewayClient transport (see tprogateway.WithTransport).
+//
+// Recorded payloads and headers are redacted with the redact package, so the Gateway's response digests
+// don't match recorded responses anymore. On replay, responses are signed again for the request being sent,
+// using the client's credentials, so the client verifies them as usual.
+//
+// Payloads that are not JSON (like CSV reports) can't be redacted, they are replaced with a short description
+// unless KeepNonJSONBodies is set.
+type Recorder struct {
+	// Transport sends requests in ModeRecord, http.DefaultTransport is used if nil
+	Transport http.RoundTripper
+	// KeepNonJSONBodies makes non-JSON payloads recorded as is, they may contain cardholder data
+	KeepNonJSONBodies bool
+
+	mode       RecorderMode
+	path       string
+	objectGUID string
+	secretKey  string
+
+	mu       sync.Mutex
+	cassette *Cassette
+	replayed []bool
+}
+
+// NewRecorder creates Recorder for given cassette file. The cassette is loaded in ModeReplay,
+// in ModeRecord it's written by Stop. Object GUID and secret key must be the ones the client uses,
+// they are needed to sign replayed responses.
+func NewRecorder(path string, mode RecorderMode, objectGUID, secretKey string) (*Recorder, error) {
+	result := &Recorder{
+		mode:       mode,
+		path:       path,
+		objectGUID: objectGUID,
+		secretKey:  secretKey,
+		cassette:   new(Cassette),
+	}
+
+	switch mode {
+	case ModeRecord:
+	case ModeReplay:
+		cassette, err := LoadCassette(path)
+		if err != nil {
+			return nil, err
+		}
+		result.cassette = cassette
+		result.replayed = make([]bool, len(cassette.Interactions))
+	default:
+		return nil, fmt.Errorf("unknown recorder mode %d", mode)
+	}
+
+	return result, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.mode == ModeReplay {
+		return r.replay(req)
+	}
+
+	return r.record(req, body)
+}
+
+// Stop writes recorded interactions to the cassette file, it does nothing in ModeReplay
+func (r *Recorder) Stop() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.cassette.Save(r.path)
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	outgoing := req.Clone(req.Context())
+	outgoing.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	resp, err := transport.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: redact.Header(req.Header),
+			Body:   r.redactBody(body),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     redact.Header(resp.Header),
+			Body:       r.redactBody(responseBody),
+		},
+	})
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// replay answers with the first recorded response for the same method and URL path that wasn't replayed yet
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	var recorded *RecordedResponse
+	for i := range r.cassette.Interactions {
+		interaction := &r.cassette.Interactions[i]
+		if r.replayed[i] || interaction.Request.Method != req.Method || recordedPath(interaction.Request.URL) != req.URL.Path {
+			continue
+		}
+
+		r.replayed[i] = true
+		recorded = &interaction.Response
+		break
+	}
+	r.mu.Unlock()
+
+	if recorded == nil {
+		return nil, fmt.Errorf("no recorded interaction left for %s %s", req.Method, req.URL.Path)
+	}
+
+	header := make(http.Header, len(recorded.Header))
+	for key, values := range recorded.Header {
+		header[key] = append([]string(nil), values...)
+	}
+	header.Del("Authorization")
+
+	body := []byte(recorded.Body)
+	if authorization := req.Header.Get("Authorization"); authorization != "" {
+		signature, err := signResponse(r.objectGUID, r.secretKey, authorization, body)
+		if err != nil {
+			return nil, fmt.Errorf("cannot sign replayed response: %w", err)
+		}
+		header.Set("Authorization", signature)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// redactBody masks sensitive fields of JSON payloads, other payloads (like CSV reports) are kept as is
+// only if KeepNonJSONBodies is set
+func (r *Recorder) redactBody(body []byte) string {
+	if r.KeepNonJSONBodies && !json.Valid(body) {
+		return string(body)
+	}
+
+	return string(redact.JSON(body))
+}
+
+func recordedPath(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return parsed.Path
+}
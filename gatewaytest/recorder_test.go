@@ -0,0 +1,172 @@
+package gatewaytest
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tprogateway "github.com/TransactPRO/gw3-go-client"
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordSMS records an SMS and its status exploration made against a fresh Server, returns cassette path and server URL
+func recordSMS(t *testing.T, dir string) (string, string) {
+	server := NewServer(testObjectGUID, testSecretKey)
+	defer server.Close()
+
+	path := filepath.Join(dir, "cassettes", "sms.json")
+	recorder, err := NewRecorder(path, ModeRecord, testObjectGUID, testSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := server.NewClient(tprogateway.WithTransport(recorder))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, paid := perform(t, client, newSMS(client, 100))
+
+	status := client.OperationBuilder().NewGetStatus()
+	status.CommandData.GWTransactionIDs = []string{paid.Gateway.GatewayTransactionID}
+	if _, err = client.NewRequest(status); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	return path, server.URL
+}
+
+func TestRecorderReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gatewaytest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path, baseURL := recordSMS(t, dir)
+
+	raw, err := ioutil.ReadFile(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotContains(t, string(raw), testPAN)
+	assert.NotContains(t, string(raw), testSecretKey)
+	assert.Contains(t, string(raw), "411111***1111")
+
+	cassette, err := LoadCassette(path)
+	if assert.NoError(t, err) {
+		assert.Len(t, cassette.Interactions, 2)
+	}
+
+	// the server is closed, responses are served from the cassette
+	recorder, err := NewRecorder(path, ModeReplay, testObjectGUID, testSecretKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	client, err := tprogateway.NewGatewayClient(testObjectGUID, testSecretKey,
+		tprogateway.WithBaseURL(baseURL), tprogateway.WithTransport(recorder), tprogateway.WithDigestAlgorithm(structures.AlgorithmSHA512))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	httpStatus, paid := perform(t, client, newSMS(client, 100))
+	assert.Equal(t, http.StatusOK, httpStatus)
+	assert.Equal(t, structures.StatusSuccess, paid.Gateway.StatusCode)
+
+	status := client.OperationBuilder().NewGetStatus()
+	status.CommandData.GWTransactionIDs = []string{paid.Gateway.GatewayTransactionID}
+	response, err := client.NewRequest(status)
+	if assert.NoError(t, err) {
+		assert.Equal(t, structures.AlgorithmSHA512, response.Digest.Algorithm)
+	}
+
+	// every interaction is replayed once
+	_, err = client.NewRequest(status)
+	assert.True(t, errors.Is(err, structures.ErrTransport), "unexpected error: %v", err)
+	assert.Contains(t, err.Error(), "no recorded interaction left for POST /v3.0/status")
+}
+
+func TestRecorderReplayWrongSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gatewaytest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path, baseURL := recordSMS(t, dir)
+
+	recorder, err := NewRecorder(path, ModeReplay, testObjectGUID, "wrong")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	client, err := tprogateway.NewGatewayClient(testObjectGUID, testSecretKey,
+		tprogateway.WithBaseURL(baseURL), tprogateway.WithTransport(recorder))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = client.NewRequest(newSMS(client, 100))
+	assert.True(t, errors.Is(err, structures.ErrAuth), "unexpected error: %v", err)
+}
+
+type csvTransport struct{}
+
+func (csvTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/csv"}},
+		Body:       ioutil.NopCloser(strings.NewReader("card-mask\n" + testPAN)),
+		Request:    req,
+	}, nil
+}
+
+func TestRecorderNonJSONBodies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gatewaytest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	record := func(keep bool) string {
+		path := filepath.Join(dir, "report.json")
+		recorder, err := NewRecorder(path, ModeRecord, testObjectGUID, testSecretKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorder.Transport = csvTransport{}
+		recorder.KeepNonJSONBodies = keep
+
+		_, err = recorder.RoundTrip(httptest.NewRequest(http.MethodPost, "http://gateway.local/v3.0/report", strings.NewReader("{}")))
+		assert.NoError(t, err)
+		assert.NoError(t, recorder.Stop())
+
+		cassette, err := LoadCassette(path)
+		if !assert.NoError(t, err) || !assert.Len(t, cassette.Interactions, 1) {
+			return ""
+		}
+		return cassette.Interactions[0].Response.Body
+	}
+
+	assert.Equal(t, "[26 bytes of non-JSON payload redacted]", record(false))
+	assert.Equal(t, "card-mask\n"+testPAN, record(true))
+}
+
+func TestNewRecorderErrors(t *testing.T) {
+	_, err := NewRecorder(filepath.Join(os.TempDir(), "gatewaytest-missing.json"), ModeReplay, testObjectGUID, testSecretKey)
+	assert.Error(t, err)
+
+	_, err = NewRecorder("cassette.json", RecorderMode(42), testObjectGUID, testSecretKey)
+	assert.EqualError(t, err, "unknown recorder mode 42")
+}
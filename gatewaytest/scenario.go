@@ -0,0 +1,253 @@
+package gatewaytest
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// Magic card numbers that trigger the outcomes of DefaultRules
+const (
+	PANSoftDecline     = "4000000000001303"
+	PAN3DSecure        = "4000000000003063"
+	PANCardExpired     = "4000000000001106"
+	PANLimitsExceeded  = "4000000000001012"
+	PANAcquirerTimeout = "4000000000001007"
+	PANDeclined        = "4000000000001301"
+)
+
+// Magic amounts (in minor units) that trigger the outcomes of DefaultRules, they are equal to the error codes returned
+const (
+	AmountSoftDecline     = int(structures.EecAcquirerSoftDecline)
+	AmountCardExpired     = int(structures.EecCardExpired)
+	AmountLimitsExceeded  = int(structures.EecAccountCountersExceeded)
+	AmountAcquirerTimeout = int(structures.EecTimeoutAcquirer)
+	AmountDeclined        = int(structures.EecDeclinedByAcquirer)
+)
+
+// RedirectURL is the cardholder redirect URL returned by DefaultRules for soft declines and 3-D Secure
+const RedirectURL = "https://gatewaytest.invalid/3ds"
+
+type (
+	// Operation describes a request matched against scenario rules
+	Operation struct {
+		Type                  structures.OperationType
+		PAN                   string
+		Amount                int
+		Currency              string
+		MerchantTransactionID string
+		// GatewayTransactionID references the transaction the operation is made for, like a charged hold
+		GatewayTransactionID string
+	}
+
+	// Outcome determines the response to an operation matched by a rule.
+	// Transaction operations still create or update the transaction, with the outcome's status.
+	Outcome struct {
+		// HTTPStatus is the response status code, 200 if zero and no error code is set, 402 otherwise
+		HTTPStatus int
+		// Status is the transaction's resulting status, the transaction's status is kept if zero
+		Status structures.Status
+		// ErrorCode is returned in the response's error structure if set
+		ErrorCode    structures.ErrorCode
+		ErrorMessage string
+		// AcquirerDetails replace the approved acquirer details if set
+		AcquirerDetails *structures.AcquirerDetails
+		// RedirectURL is returned as the cardholder redirect URL if set
+		RedirectURL string
+		// Delay postpones the response, e.g. to make the client time out.
+		// The response is dropped if the request is canceled meanwhile.
+		Delay time.Duration
+
+		// failure replaces Status with the operation's own failure status, see failureStatuses
+		failure bool
+	}
+
+	// Rule matches operations and determines their outcome. All set conditions must match.
+	Rule struct {
+		// OperationTypes limits the rule to given operations, any operation matches if empty
+		OperationTypes []structures.OperationType
+		// PAN matches operations with given card number if set
+		PAN string
+		// Amount matches operations with given amount in minor units if set
+		Amount int
+		// MerchantTransactionID matches operations with given merchant transaction ID if set
+		MerchantTransactionID string
+		// Match is an additional custom condition if set
+		Match func(op Operation) bool
+		// Times limits how many operations the rule applies to, no limit if zero
+		Times int
+
+		Outcome Outcome
+	}
+)
+
+// DefaultRules returns the rules Server applies after the rules added with AddRule:
+// magic card numbers (PANSoftDecline, PAN3DSecure, ...) and amounts (AmountSoftDecline, ...) trigger corresponding outcomes.
+// Declines fail the transaction with the operation's own failure status, e.g. StatusDmsHoldFailed for a hold
+// or StatusRefundFailed for a refund.
+func DefaultRules() []Rule {
+	softDecline := Outcome{
+		Status:      structures.StatusSmsFailed,
+		failure:     true,
+		ErrorCode:   structures.EecAcquirerSoftDecline,
+		RedirectURL: RedirectURL,
+		AcquirerDetails: &structures.AcquirerDetails{
+			TerminalID: "gatewaytest", ResultCode: "1A", StatusText: "Soft decline", StatusDescription: "Additional customer authentication required",
+		},
+	}
+	cardExpired := decline(structures.StatusSmsFailed, structures.EecCardExpired, "54", "Expired card")
+	limitsExceeded := decline(structures.StatusDeclinedByBusinessRulesAction, structures.EecAccountCountersExceeded, "61", "Exceeds withdrawal limit")
+	acquirerTimeout := decline(structures.StatusAcquirerNotReachable, structures.EecTimeoutAcquirer, "91", "Issuer or switch inoperative")
+	declined := decline(structures.StatusSmsFailed, structures.EecDeclinedByAcquirer, "05", "Do not honour")
+
+	return []Rule{
+		{PAN: PANSoftDecline, Outcome: softDecline},
+		{PAN: PAN3DSecure, Outcome: Outcome{Status: structures.StatusMpiURLGenerated, RedirectURL: RedirectURL}},
+		{PAN: PANCardExpired, Outcome: cardExpired},
+		{PAN: PANLimitsExceeded, Outcome: limitsExceeded},
+		{PAN: PANAcquirerTimeout, Outcome: acquirerTimeout},
+		{PAN: PANDeclined, Outcome: declined},
+		{Amount: AmountSoftDecline, Outcome: softDecline},
+		{Amount: AmountCardExpired, Outcome: cardExpired},
+		{Amount: AmountLimitsExceeded, Outcome: limitsExceeded},
+		{Amount: AmountAcquirerTimeout, Outcome: acquirerTimeout},
+		{Amount: AmountDeclined, Outcome: declined},
+	}
+}
+
+func decline(status structures.Status, code structures.ErrorCode, resultCode, statusText string) Outcome {
+	return Outcome{
+		Status:    status,
+		failure:   status == structures.StatusSmsFailed,
+		ErrorCode: code,
+		AcquirerDetails: &structures.AcquirerDetails{
+			TerminalID: "gatewaytest", ResultCode: resultCode, StatusText: statusText,
+		},
+	}
+}
+
+// AddRule adds a rule, checked before the rules added earlier and DefaultRules
+func (s *Server) AddRule(rule Rule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rules = append([]*Rule{&rule}, s.rules...)
+}
+
+// ClearRules removes the rules added with AddRule, DefaultRules are kept
+func (s *Server) ClearRules() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rules = nil
+}
+
+func (r *Rule) matches(op *Operation) bool {
+	if len(r.OperationTypes) > 0 {
+		found := false
+		for _, opType := range r.OperationTypes {
+			found = found || opType == op.Type
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return (r.PAN == "" || r.PAN == op.PAN) &&
+		(r.Amount == 0 || r.Amount == op.Amount) &&
+		(r.MerchantTransactionID == "" || r.MerchantTransactionID == op.MerchantTransactionID) &&
+		(r.Match == nil || r.Match(*op))
+}
+
+// match finds the outcome for the operation, the server's lock must be held
+func (s *Server) match(opType structures.OperationType, data *requestData) (*Outcome, bool) {
+	op := Operation{
+		Type:                  opType,
+		PAN:                   data.PaymentMethod.Pan,
+		Amount:                data.Money.Amount,
+		Currency:              data.Money.Currency,
+		MerchantTransactionID: data.GeneralData.OrderData.MerchantTransactionID,
+		GatewayTransactionID:  data.CommandData.GatewayTransactionID,
+	}
+	if opType == structures.Verify3dEnrollment {
+		op.PAN, op.Currency = data.Pan, data.Currency
+	}
+	if opType == structures.VerifyCard {
+		op.GatewayTransactionID = data.GatewayTransactionID
+	}
+
+	for i, rule := range s.rules {
+		if rule.matches(&op) {
+			if rule.Times > 0 {
+				if rule.Times--; rule.Times == 0 {
+					s.rules = append(s.rules[:i:i], s.rules[i+1:]...)
+				}
+			}
+			return &rule.Outcome, true
+		}
+	}
+
+	for i := range s.defaultRules {
+		if s.defaultRules[i].matches(&op) {
+			return &s.defaultRules[i].Outcome, true
+		}
+	}
+
+	return nil, false
+}
+
+// simulate answers the operation with given outcome, the server's lock must be held
+func (s *Server) simulate(opType structures.OperationType, data *requestData, outcome *Outcome) (int, interface{}) {
+	httpStatus := outcome.HTTPStatus
+	if httpStatus == 0 {
+		httpStatus = http.StatusOK
+		if outcome.ErrorCode != 0 {
+			httpStatus = http.StatusPaymentRequired
+		}
+	}
+
+	var tx *Transaction
+	if _, ok := paymentStatuses[opType]; ok {
+		tx = s.create(opType, data)
+		tx.ParentGatewayTransactionID = data.CommandData.GatewayTransactionID
+	} else if parent, ok := s.transactions[data.CommandData.GatewayTransactionID]; ok {
+		tx = parent
+		if opType == structures.Refund {
+			tx = s.create(opType, data)
+			tx.ParentGatewayTransactionID = parent.GatewayTransactionID
+			tx.CardMask = parent.CardMask
+		}
+	}
+
+	gwError := structures.Error{Code: outcome.ErrorCode, Message: outcome.ErrorMessage}
+	if gwError.Code != 0 && gwError.Message == "" {
+		gwError.Message = "simulated error"
+	}
+
+	if tx == nil {
+		return httpStatus, errorResponse{Error: gwError}
+	}
+
+	status := outcome.Status
+	if failed, ok := failureStatuses[opType]; ok && outcome.failure {
+		status = failed
+	}
+	if status != 0 {
+		s.setStatus(tx, status)
+	}
+
+	result := s.transactionResponse(tx)
+	result.Error = gwError
+	if outcome.AcquirerDetails != nil {
+		result.AcquirerDetails = *outcome.AcquirerDetails
+	}
+	if outcome.RedirectURL != "" {
+		if redirectURL, err := url.Parse(outcome.RedirectURL); err == nil {
+			result.Gateway.RedirectURL = (*structures.URL)(redirectURL)
+		}
+	}
+
+	return httpStatus, result
+}
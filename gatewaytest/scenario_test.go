@@ -0,0 +1,203 @@
+package gatewaytest
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	tprogateway "github.com/TransactPRO/gw3-go-client"
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCardSMS(client *tprogateway.GatewayClient, pan string, amount int) structures.OperationRequestInterface {
+	sms := client.OperationBuilder().NewSms()
+	sms.PaymentMethod.Pan = pan
+	sms.Money = structures.MoneyData{Amount: amount, Currency: "EUR"}
+	return sms
+}
+
+func TestDefaultRules(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	cases := map[string]struct {
+		op         structures.OperationRequestInterface
+		httpStatus int
+		status     structures.Status
+		category   structures.ErrorCategory
+		redirect   bool
+	}{
+		"soft decline":     {newCardSMS(client, PANSoftDecline, 100), http.StatusPaymentRequired, structures.StatusSmsFailed, structures.ErrorCategorySoftDecline, true},
+		"3-D Secure":       {newCardSMS(client, PAN3DSecure, 100), http.StatusOK, structures.StatusMpiURLGenerated, structures.ErrorCategoryUnknown, true},
+		"card expired":     {newCardSMS(client, PANCardExpired, 100), http.StatusPaymentRequired, structures.StatusSmsFailed, structures.ErrorCategoryHardDecline, false},
+		"limits exceeded":  {newCardSMS(client, PANLimitsExceeded, 100), http.StatusPaymentRequired, structures.StatusDeclinedByBusinessRulesAction, structures.ErrorCategoryLimitsExceeded, false},
+		"acquirer timeout": {newCardSMS(client, PANAcquirerTimeout, 100), http.StatusPaymentRequired, structures.StatusAcquirerNotReachable, structures.ErrorCategoryAcquirerTimeout, false},
+		"declined":         {newCardSMS(client, PANDeclined, 100), http.StatusPaymentRequired, structures.StatusSmsFailed, structures.ErrorCategoryHardDecline, false},
+		"expired amount":   {newCardSMS(client, testPAN, AmountCardExpired), http.StatusPaymentRequired, structures.StatusSmsFailed, structures.ErrorCategoryHardDecline, false},
+		"timeout amount":   {newCardSMS(client, testPAN, AmountAcquirerTimeout), http.StatusPaymentRequired, structures.StatusAcquirerNotReachable, structures.ErrorCategoryAcquirerTimeout, false},
+	}
+
+	for name, expected := range cases {
+		t.Run(name, func(t *testing.T) {
+			httpStatus, result := perform(t, client, expected.op)
+			assert.Equal(t, expected.httpStatus, httpStatus)
+			assert.Equal(t, expected.status, result.Gateway.StatusCode)
+			assert.Equal(t, expected.category, result.Error.Code.Category())
+
+			if expected.redirect {
+				if assert.NotNil(t, result.Gateway.RedirectURL) {
+					redirectURL := result.Gateway.RedirectURL
+					assert.Equal(t, "gatewaytest.invalid", redirectURL.Host)
+				}
+			} else {
+				assert.Nil(t, result.Gateway.RedirectURL)
+			}
+
+			tx, ok := server.Transaction(result.Gateway.GatewayTransactionID)
+			assert.True(t, ok)
+			assert.Equal(t, expected.status, tx.Status)
+		})
+	}
+}
+
+func TestDefaultRulesOperationStatus(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	builder := client.OperationBuilder()
+	hold := builder.NewHoldDMS()
+	hold.PaymentMethod.Pan = PANDeclined
+	hold.Money = structures.MoneyData{Amount: 100, Currency: "EUR"}
+	httpStatus, result := perform(t, client, hold)
+	assert.Equal(t, http.StatusPaymentRequired, httpStatus)
+	assert.Equal(t, structures.StatusDmsHoldFailed, result.Gateway.StatusCode)
+
+	hold.PaymentMethod.Pan = testPAN
+	hold.Money.Amount = 2 * AmountDeclined
+	_, held := perform(t, client, hold)
+
+	charge := builder.NewChargeDMS()
+	charge.CommandData.GWTransactionID = held.Gateway.GatewayTransactionID
+	charge.Money.Amount = AmountDeclined
+	httpStatus, result = perform(t, client, charge)
+	assert.Equal(t, http.StatusPaymentRequired, httpStatus)
+	assert.Equal(t, structures.StatusDmsChargeFailed, result.Gateway.StatusCode)
+	assert.Equal(t, structures.EecDeclinedByAcquirer, result.Error.Code)
+
+	tx, _ := server.Transaction(held.Gateway.GatewayTransactionID)
+	assert.Equal(t, structures.StatusDmsChargeFailed, tx.Status)
+
+	_, paid := perform(t, client, newSMS(client, 2*AmountDeclined))
+	refund := builder.NewRefund()
+	refund.CommandData.GWTransactionID = paid.Gateway.GatewayTransactionID
+	refund.Money.Amount = AmountDeclined
+	httpStatus, result = perform(t, client, refund)
+	assert.Equal(t, http.StatusPaymentRequired, httpStatus)
+	assert.Equal(t, structures.StatusRefundFailed, result.Gateway.StatusCode)
+	assert.NotEqual(t, paid.Gateway.GatewayTransactionID, result.Gateway.GatewayTransactionID)
+
+	tx, _ = server.Transaction(paid.Gateway.GatewayTransactionID)
+	assert.Equal(t, structures.StatusSuccess, tx.Status)
+}
+
+func TestRuleOutcome(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	details := structures.AcquirerDetails{ResultCode: "51", StatusText: "Insufficient funds"}
+	server.AddRule(Rule{
+		OperationTypes: []structures.OperationType{structures.Refund},
+		Times:          1,
+		Outcome: Outcome{
+			Status:          structures.StatusRefundFailed,
+			ErrorCode:       structures.EecDeclinedByAcquirer,
+			ErrorMessage:    "refund declined",
+			AcquirerDetails: &details,
+		},
+	})
+
+	_, paid := perform(t, client, newSMS(client, 100))
+	refund := client.OperationBuilder().NewRefund()
+	refund.CommandData.GWTransactionID = paid.Gateway.GatewayTransactionID
+
+	httpStatus, result := perform(t, client, refund)
+	assert.Equal(t, http.StatusPaymentRequired, httpStatus)
+	assert.Equal(t, structures.StatusRefundFailed, result.Gateway.StatusCode)
+	assert.Equal(t, structures.Error{Code: structures.EecDeclinedByAcquirer, Message: "refund declined"}, result.Error)
+	assert.Equal(t, details, result.AcquirerDetails)
+	if assert.NotNil(t, result.Gateway.ParentGatewayTransactionID) {
+		assert.Equal(t, paid.Gateway.GatewayTransactionID, *result.Gateway.ParentGatewayTransactionID)
+	}
+
+	// the rule is applied once
+	httpStatus, result = perform(t, client, refund)
+	assert.Equal(t, http.StatusOK, httpStatus)
+	assert.Equal(t, structures.StatusRefundSuccess, result.Gateway.StatusCode)
+}
+
+func TestRuleConditions(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	declined := Outcome{Status: structures.StatusSmsFailed, ErrorCode: structures.EecDeclinedByAcquirer}
+	server.AddRule(Rule{MerchantTransactionID: "order-1", Amount: 500, Outcome: declined})
+	server.AddRule(Rule{Match: func(op Operation) bool { return op.Currency == "USD" }, Outcome: declined})
+
+	cases := []struct {
+		merchantTransactionID string
+		amount                int
+		currency              string
+		declined              bool
+	}{
+		{"order-1", 500, "EUR", true},
+		{"order-1", 400, "EUR", false},
+		{"order-2", 500, "EUR", false},
+		{"order-2", 100, "USD", true},
+	}
+
+	for _, testCase := range cases {
+		sms := client.OperationBuilder().NewSms()
+		sms.PaymentMethod.Pan = testPAN
+		sms.Money = structures.MoneyData{Amount: testCase.amount, Currency: testCase.currency}
+		sms.GeneralData.OrderData.MerchantTransactionID = testCase.merchantTransactionID
+
+		_, result := perform(t, client, sms)
+		assert.Equal(t, testCase.declined, result.Error.Code != 0, "%+v", testCase)
+	}
+
+	server.ClearRules()
+	_, result := perform(t, client, newCardSMS(client, testPAN, 500))
+	assert.Zero(t, result.Error.Code)
+
+	// default rules are kept
+	_, result = perform(t, client, newCardSMS(client, PANDeclined, 500))
+	assert.Equal(t, structures.EecDeclinedByAcquirer, result.Error.Code)
+}
+
+func TestRuleServerFailure(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	server.AddRule(Rule{
+		OperationTypes: []structures.OperationType{structures.ExploringLimits},
+		Outcome:        Outcome{HTTPStatus: http.StatusServiceUnavailable, ErrorCode: structures.EecGeneralError},
+	})
+
+	response, err := client.NewRequest(client.OperationBuilder().NewGetLimits())
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+	}
+}
+
+func TestRuleDelay(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	assert.NoError(t, tprogateway.WithTimeout(50*time.Millisecond)(client))
+	server.AddRule(Rule{PAN: testPAN, Outcome: Outcome{Delay: time.Second}})
+
+	_, err := client.NewRequest(newSMS(client, 100))
+	assert.True(t, errors.Is(err, structures.ErrTransport), "unexpected error: %v", err)
+}
@@ -0,0 +1,281 @@
+// Package gatewaytest provides an in-process fake of Transact Pro Gateway API v3 for tests,
+// the way net/http/httptest does for HTTP servers in general.
+//
+// Server authenticates requests and signs responses with digests like the Gateway does,
+// so GatewayClient talks to it with no special configuration except the base URL.
+package gatewaytest
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"time"
+
+	tprogateway "github.com/TransactPRO/gw3-go-client"
+	"github.com/TransactPRO/gw3-go-client/handlers"
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// routePattern extracts operation type from versioned API paths like /v3.0/sms
+var routePattern = regexp.MustCompile(`^/v[0-9.]+/(.+)$`)
+
+// Server is a fake Gateway started on a local address. Transactions are kept in memory,
+// so exploring operations reflect the operations performed before.
+// Declines, redirects and failures are simulated by scenario rules, see AddRule and DefaultRules.
+type Server struct {
+	*httptest.Server
+
+	ObjectGUID string
+	SecretKey  string
+	// Now returns the current time used to check request nonces and to timestamp transactions, time.Now is used if nil.
+	// Set it before sending requests, e.g. to the clock of a client created WithClock. Responses are signed
+	// with the current time like the Gateway does, so they pass the client's default replay guard.
+	Now func() time.Time
+
+	mu           sync.Mutex
+	seq          int
+	transactions map[string]*Transaction
+	byMerchant   map[string]string
+	order        []string
+	rules        []*Rule
+	defaultRules []Rule
+}
+
+// NewServer starts Server accepting requests signed with given credentials.
+// The caller should call Close when finished, to shut it down.
+func NewServer(objectGUID, secretKey string) *Server {
+	s := &Server{
+		ObjectGUID:   objectGUID,
+		SecretKey:    secretKey,
+		transactions: make(map[string]*Transaction),
+		byMerchant:   make(map[string]string),
+		defaultRules: DefaultRules(),
+	}
+
+	authenticator := handlers.NewDigestAuthenticator(s.lookup)
+	authenticator.ReplayGuard.Now = s.now
+	s.Server = httptest.NewServer(authenticator.Wrap(http.HandlerFunc(s.serve)))
+
+	return s
+}
+
+// now returns the current time of the server's clock
+func (s *Server) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// NewClient creates GatewayClient with the server's credentials and base URL, given options are applied after
+func (s *Server) NewClient(opts ...tprogateway.Option) (*tprogateway.GatewayClient, error) {
+	return tprogateway.NewGatewayClient(s.ObjectGUID, s.SecretKey, append([]tprogateway.Option{tprogateway.WithBaseURL(s.URL)}, opts...)...)
+}
+
+// Transaction returns a copy of the transaction's current state
+func (s *Server) Transaction(gatewayTransactionID string) (Transaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, ok := s.transactions[gatewayTransactionID]
+	if !ok {
+		return Transaction{}, false
+	}
+
+	return tx.copy(), true
+}
+
+// Transactions returns copies of all transactions in creation order
+func (s *Server) Transactions() []Transaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]Transaction, 0, len(s.order))
+	for _, id := range s.order {
+		result = append(result, s.transactions[id].copy())
+	}
+
+	return result
+}
+
+// Reset forgets all transactions, rules are kept
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq = 0
+	s.transactions = make(map[string]*Transaction)
+	s.byMerchant = make(map[string]string)
+	s.order = nil
+}
+
+func (s *Server) lookup(ctx context.Context, objectGUID string) (string, error) {
+	if objectGUID != s.ObjectGUID {
+		return "", handlers.ErrUnknownCredentials
+	}
+
+	return s.SecretKey, nil
+}
+
+// serve handles authenticated requests
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	opType, ok := route(r.URL.Path)
+	if !ok {
+		s.writeJSON(w, r, http.StatusNotFound, errorResponse{Error: structures.Error{Message: "route not found"}})
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		s.writeJSON(w, r, http.StatusMethodNotAllowed, errorResponse{Error: structures.Error{Message: "method not allowed"}})
+		return
+	}
+
+	var req request
+	body, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &req)
+	}
+	if err != nil {
+		status, failure := errorResult(http.StatusBadRequest, structures.EecInputValidationFailed, "malformed request: %s", err)
+		s.writeJSON(w, r, status, failure)
+		return
+	}
+
+	s.mu.Lock()
+	outcome, simulated := s.match(opType, &req.Data)
+	var status int
+	var payload interface{}
+	var delay time.Duration
+	switch {
+	case simulated:
+		status, payload = s.simulate(opType, &req.Data, outcome)
+		delay = outcome.Delay
+	case opType == structures.Report:
+		var report []byte
+		status, report = s.report(&req.FilterData)
+		s.mu.Unlock()
+		s.write(w, r, status, "text/csv", report)
+		return
+	default:
+		status, payload = s.handle(opType, &req.Data)
+	}
+	s.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	s.writeJSON(w, r, status, payload)
+}
+
+// handle performs the operation, the server's lock must be held
+func (s *Server) handle(opType structures.OperationType, data *requestData) (int, interface{}) {
+	switch opType {
+	case structures.DMSCharge:
+		return s.charge(data)
+	case structures.CANCEL:
+		return s.cancel(data)
+	case structures.Refund:
+		return s.refund(data)
+	case structures.Reversal:
+		return s.reversal(data)
+	case structures.ExploringStatus, structures.ExploringResult, structures.ExploringHistory,
+		structures.ExploringRecurrents, structures.ExploringRefunds:
+		return s.explore(opType, data)
+	case structures.ExploringLimits:
+		return s.limits()
+	case structures.Verify3dEnrollment:
+		return s.verifyEnrollment(data)
+	case structures.VerifyCard:
+		return s.verifyCard(data)
+	default:
+		return s.payment(opType, data)
+	}
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, status int, payload interface{}) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.write(w, r, status, "application/json", body)
+}
+
+// write sends the response signed for the request it answers
+func (s *Server) write(w http.ResponseWriter, r *http.Request, status int, contentType string, body []byte) {
+	signature, err := s.sign(r, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Authorization", signature)
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// sign creates response digest with the same algorithm and QOP as the request's one
+func (s *Server) sign(r *http.Request, body []byte) (string, error) {
+	return signResponse(s.ObjectGUID, s.SecretKey, r.Header.Get("Authorization"), body)
+}
+
+// signResponse creates response Authorization header value for given request Authorization header,
+// with the same algorithm and QOP as the request's digest
+func signResponse(objectGUID, secretKey, requestAuthorization string, body []byte) (string, error) {
+	requestDigest, err := structures.NewInboundRequestDigest(requestAuthorization, structures.DigestParseLenient)
+	if err != nil {
+		return "", err
+	}
+
+	responseDigest, err := structures.NewResponseDigestForRequest(objectGUID, requestDigest.URI, requestDigest.Cnonce, body,
+		structures.WithAlgorithm(requestDigest.Algorithm), structures.WithQOP(requestDigest.QOP))
+	if err != nil {
+		return "", err
+	}
+
+	return responseDigest.CreateHeader(secretKey)
+}
+
+// routes lists operation types served by Server, except the report which has no version in its path
+var routes = func() map[structures.OperationType]bool {
+	result := map[structures.OperationType]bool{
+		structures.DMSCharge: true, structures.CANCEL: true, structures.Refund: true, structures.Reversal: true,
+		structures.ExploringStatus: true, structures.ExploringResult: true, structures.ExploringHistory: true,
+		structures.ExploringRecurrents: true, structures.ExploringRefunds: true, structures.ExploringLimits: true,
+		structures.Verify3dEnrollment: true, structures.VerifyCard: true,
+	}
+	for opType := range paymentStatuses {
+		result[opType] = true
+	}
+
+	return result
+}()
+
+// route returns operation type for request path
+func route(path string) (structures.OperationType, bool) {
+	if path == "/"+string(structures.Report) {
+		return structures.Report, true
+	}
+
+	match := routePattern.FindStringSubmatch(path)
+	if match == nil {
+		return "", false
+	}
+
+	opType := structures.OperationType(match[1])
+	return opType, routes[opType]
+}
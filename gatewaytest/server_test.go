@@ -0,0 +1,126 @@
+package gatewaytest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	tprogateway "github.com/TransactPRO/gw3-go-client"
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testObjectGUID = "3383e58e-9cde-4ffa-85cf-81cd25b2423e"
+	testSecretKey  = "SecKey"
+)
+
+// newTestServer starts Server and creates a client for it
+func newTestServer(t *testing.T) (*Server, *tprogateway.GatewayClient) {
+	server := NewServer(testObjectGUID, testSecretKey)
+
+	client, err := server.NewClient()
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+
+	return server, client
+}
+
+func TestServerSignsResponses(t *testing.T) {
+	for _, algorithm := range structures.SupportedAlgorithms() {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			server, client := newTestServer(t)
+			defer server.Close()
+			assert.NoError(t, tprogateway.WithDigestAlgorithm(algorithm)(client))
+
+			response, err := client.NewRequest(client.OperationBuilder().NewGetLimits())
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			assert.Equal(t, algorithm, response.Digest.Algorithm)
+		})
+	}
+}
+
+func TestServerRejectsUnknownCredentials(t *testing.T) {
+	server, _ := newTestServer(t)
+	defer server.Close()
+
+	client, err := tprogateway.NewGatewayClient(testObjectGUID, "wrong", tprogateway.WithBaseURL(server.URL))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	response, err := client.NewRequest(client.OperationBuilder().NewGetLimits())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+}
+
+func TestServerClock(t *testing.T) {
+	server, _ := newTestServer(t)
+	defer server.Close()
+
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	client, err := server.NewClient(tprogateway.WithClock(func() time.Time { return now }))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// nonces of a fixed clock are stale for the wall clock
+	response, err := client.NewRequest(client.OperationBuilder().NewGetLimits())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	server.Now = func() time.Time { return now }
+	httpStatus, result := perform(t, client, newSMS(client, 100))
+	assert.Equal(t, http.StatusOK, httpStatus)
+
+	tx, _ := server.Transaction(result.Gateway.GatewayTransactionID)
+	assert.Equal(t, now, tx.CreatedAt)
+	assert.Equal(t, now, tx.FinishedAt)
+}
+
+func TestServerReset(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	sms := client.OperationBuilder().NewSms()
+	sms.PaymentMethod.Pan = "4111111111111111"
+	sms.Money = structures.MoneyData{Amount: 100, Currency: "EUR"}
+	_, err := client.NewRequest(sms)
+	assert.NoError(t, err)
+	assert.Len(t, server.Transactions(), 1)
+
+	server.Reset()
+	assert.Empty(t, server.Transactions())
+}
+
+func TestRoute(t *testing.T) {
+	cases := map[string]struct {
+		opType structures.OperationType
+		ok     bool
+	}{
+		"/v3.0/sms":                  {structures.SMS, true},
+		"/v3.0/recurrent/dms/init":   {structures.InitRecurrentDMS, true},
+		"/v3.0/verify/3d-enrollment": {structures.Verify3dEnrollment, true},
+		"/v3.0/token/create":         {structures.CreateToken, true},
+		"/report":                    {structures.Report, true},
+		"/v3.0/report":               {"", false},
+		"/v3.0/unknown":              {"", false},
+		"/sms":                       {"", false},
+	}
+
+	for path, expected := range cases {
+		t.Run(path, func(t *testing.T) {
+			opType, ok := route(path)
+			assert.Equal(t, expected.ok, ok)
+			if expected.ok {
+				assert.Equal(t, expected.opType, opType)
+			}
+		})
+	}
+}
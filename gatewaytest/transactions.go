@@ -0,0 +1,354 @@
+package gatewaytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/redact"
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+type (
+	// Transaction is the state of a transaction kept by Server
+	Transaction struct {
+		GatewayTransactionID       string
+		MerchantTransactionID      string
+		ParentGatewayTransactionID string
+		OperationType              structures.OperationType
+		Status                     structures.Status
+		// Amount is the held or charged amount in minor units
+		Amount int
+		// RefundedAmount is the sum of successful refunds
+		RefundedAmount int
+		Currency       string
+		CardMask       string
+		CreatedAt      time.Time
+		FinishedAt     time.Time
+		History        []StatusChange
+	}
+
+	// StatusChange is a transaction status transition
+	StatusChange struct {
+		Old  structures.Status
+		New  structures.Status
+		Time time.Time
+	}
+)
+
+// paymentStatuses are the statuses of successfully created transactions by operation type
+var paymentStatuses = map[structures.OperationType]structures.Status{
+	structures.SMS:              structures.StatusSuccess,
+	structures.MOTOSMS:          structures.StatusSuccess,
+	structures.InitRecurrentSMS: structures.StatusSuccess,
+	structures.RecurrentSMS:     structures.StatusSuccess,
+	structures.CREDIT:           structures.StatusSuccess,
+	structures.P2P:              structures.StatusSuccess,
+	structures.B2P:              structures.StatusSuccess,
+	structures.DMSHold:          structures.StatusDmsHoldOK,
+	structures.MOTODMS:          structures.StatusDmsHoldOK,
+	structures.InitRecurrentDMS: structures.StatusDmsHoldOK,
+	structures.RecurrentDMS:     structures.StatusDmsHoldOK,
+	structures.CreateToken:      structures.StatusTokenCreated,
+}
+
+// failureStatuses are the statuses of transactions declined by DefaultRules by operation type, StatusSmsFailed otherwise
+var failureStatuses = map[structures.OperationType]structures.Status{
+	structures.DMSHold:          structures.StatusDmsHoldFailed,
+	structures.MOTODMS:          structures.StatusDmsHoldFailed,
+	structures.InitRecurrentDMS: structures.StatusDmsHoldFailed,
+	structures.RecurrentDMS:     structures.StatusDmsHoldFailed,
+	structures.DMSCharge:        structures.StatusDmsChargeFailed,
+	structures.CANCEL:           structures.StatusDmsCancelFailed,
+	structures.Refund:           structures.StatusRefundFailed,
+	structures.Reversal:         structures.StatusReversalFailed,
+	structures.CREDIT:           structures.StatusCreditFailed,
+	structures.P2P:              structures.StatusP2PFailed,
+	structures.B2P:              structures.StatusB2PFailed,
+	structures.CreateToken:      structures.StatusTokenCreateFailed,
+}
+
+// recurrentParents maps subsequent recurring operations to the operations that initialize them
+var recurrentParents = map[structures.OperationType]structures.OperationType{
+	structures.RecurrentSMS: structures.InitRecurrentSMS,
+	structures.RecurrentDMS: structures.InitRecurrentDMS,
+}
+
+var status2text = map[structures.Status]string{
+	structures.StatusInit:                          "INIT",
+	structures.StatusSent2Bank:                     "SENT2BANK",
+	structures.StatusDmsHoldOK:                     "DMS HOLD OK",
+	structures.StatusDmsHoldFailed:                 "DMS HOLD FAILED",
+	structures.StatusSmsFailed:                     "SMS FAILED",
+	structures.StatusDmsChargeFailed:               "DMS CHARGE FAILED",
+	structures.StatusSuccess:                       "SUCCESS",
+	structures.StatusExpired:                       "EXPIRED",
+	structures.StatusHoldExpired:                   "HOLD EXPIRED",
+	structures.StatusRefundFailed:                  "REFUND FAILED",
+	structures.StatusRefundPending:                 "REFUND PENDING",
+	structures.StatusRefundSuccess:                 "REFUND SUCCESS",
+	structures.StatusCardholderOnSite:              "CARDHOLDER ON SITE",
+	structures.StatusDmsCanceled:                   "DMS CANCELED",
+	structures.StatusDmsCancelFailed:               "DMS CANCEL FAILED",
+	structures.StatusReversed:                      "REVERSED",
+	structures.StatusInputValidationFailed:         "INPUT VALIDATION FAILED",
+	structures.StatusBusinessRulesValidationFailed: "BUSINESS RULES VALIDATION FAILED",
+	structures.StatusTerminalGroupSelectFailed:     "TERMINAL GROUP SELECT FAILED",
+	structures.StatusTerminalSelectFailed:          "TERMINAL SELECT FAILED",
+	structures.StatusInitParamsInvalid:             "INIT PARAMS INVALID",
+	structures.StatusDeclinedByBusinessRulesAction: "DECLINED BY BUSINESS RULES ACTION",
+	structures.StatusCallbackURLGenerated:          "CALLBACK URL GENERATED",
+	structures.StatusWaitingCardFormFill:           "WAITING CARD FORM FILL",
+	structures.StatusMpiURLGenerated:               "MPI URL GENERATED",
+	structures.StatusWaitingMpi:                    "WAITING MPI",
+	structures.StatusMpiFailed:                     "MPI FAILED",
+	structures.StatusMpiNotReachable:               "MPI NOT REACHABLE",
+	structures.StatusCardFormURLSent:               "CARD FORM URL SENT",
+	structures.StatusMpiAuthError:                  "MPI AUTH ERROR",
+	structures.StatusAcquirerNotReachable:          "ACQUIRER NOT REACHABLE",
+	structures.StatusReversalFailed:                "REVERSAL FAILED",
+	structures.StatusCreditFailed:                  "CREDIT FAILED",
+	structures.StatusP2PFailed:                     "P2P FAILED",
+	structures.StatusB2PFailed:                     "B2P FAILED",
+	structures.StatusTokenCreated:                  "TOKEN CREATED",
+	structures.StatusTokenCreateFailed:             "TOKEN CREATE FAILED",
+}
+
+// StatusText returns the text the Gateway uses for given status
+func StatusText(status structures.Status) string {
+	if result, ok := status2text[status]; ok {
+		return result
+	}
+
+	return "UNKNOWN"
+}
+
+// errorResult answers with Gateway error payload
+func errorResult(httpStatus int, code structures.ErrorCode, format string, args ...interface{}) (int, interface{}) {
+	return httpStatus, errorResponse{Error: structures.Error{Code: code, Message: fmt.Sprintf(format, args...)}}
+}
+
+// payment creates a new transaction for payment, credit, recurring and tokenization operations
+func (s *Server) payment(opType structures.OperationType, data *requestData) (int, interface{}) {
+	var parent *Transaction
+	if parentType, ok := recurrentParents[opType]; ok {
+		var status int
+		var failure interface{}
+		if parent, status, failure = s.parent(data.CommandData.GatewayTransactionID); parent == nil {
+			return status, failure
+		}
+
+		if parent.OperationType != parentType || parent.Status != paymentStatuses[parentType] {
+			return errorResult(http.StatusBadRequest, structures.EecTransactionTypeInvalid,
+				"transaction %s is not an initial recurring transaction", parent.GatewayTransactionID)
+		}
+	} else if data.PaymentMethod.Pan == "" && data.CommandData.PaymentMethodDataToken == "" {
+		return errorResult(http.StatusBadRequest, structures.EecInputValidationFailed, "payment method data is required")
+	}
+
+	if opType != structures.CreateToken && (data.Money.Amount <= 0 || data.Money.Currency == "") {
+		return errorResult(http.StatusBadRequest, structures.EecInputValidationFailed, "amount and currency are required")
+	}
+
+	tx := s.create(opType, data)
+	if parent != nil {
+		tx.ParentGatewayTransactionID = parent.GatewayTransactionID
+		tx.CardMask = parent.CardMask
+	}
+	s.setStatus(tx, paymentStatuses[opType])
+
+	return http.StatusOK, s.transactionResponse(tx)
+}
+
+// charge completes a held DMS transaction, charging the whole held amount if no amount is given
+func (s *Server) charge(data *requestData) (int, interface{}) {
+	tx, status, failure := s.parent(data.CommandData.GatewayTransactionID)
+	if tx == nil {
+		return status, failure
+	}
+
+	if tx.Status != structures.StatusDmsHoldOK {
+		return stateInvalid(tx)
+	}
+
+	if data.Money.Amount > tx.Amount {
+		return errorResult(http.StatusBadRequest, structures.EecInputValidationFailed,
+			"amount %d exceeds held amount %d", data.Money.Amount, tx.Amount)
+	}
+
+	if data.Money.Amount > 0 {
+		tx.Amount = data.Money.Amount
+	}
+	s.setStatus(tx, structures.StatusSuccess)
+
+	return http.StatusOK, s.transactionResponse(tx)
+}
+
+// cancel releases a held DMS transaction
+func (s *Server) cancel(data *requestData) (int, interface{}) {
+	tx, status, failure := s.parent(data.CommandData.GatewayTransactionID)
+	if tx == nil {
+		return status, failure
+	}
+
+	if tx.Status != structures.StatusDmsHoldOK {
+		return stateInvalid(tx)
+	}
+	s.setStatus(tx, structures.StatusDmsCanceled)
+
+	return http.StatusOK, s.transactionResponse(tx)
+}
+
+// refund creates a refund transaction for a successful one, refunding the remaining amount if no amount is given.
+// The refunded transaction gets StatusRefundSuccess once it's refunded completely.
+func (s *Server) refund(data *requestData) (int, interface{}) {
+	parent, status, failure := s.parent(data.CommandData.GatewayTransactionID)
+	if parent == nil {
+		return status, failure
+	}
+
+	if parent.Status != structures.StatusSuccess {
+		return stateInvalid(parent)
+	}
+
+	remaining := parent.Amount - parent.RefundedAmount
+	amount := data.Money.Amount
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount < 0 || amount > remaining {
+		return errorResult(http.StatusBadRequest, structures.EecInputValidationFailed,
+			"amount %d exceeds refundable amount %d", amount, remaining)
+	}
+
+	tx := s.create(structures.Refund, data)
+	tx.ParentGatewayTransactionID = parent.GatewayTransactionID
+	tx.Amount = amount
+	tx.Currency = parent.Currency
+	tx.CardMask = parent.CardMask
+	s.setStatus(tx, structures.StatusRefundSuccess)
+
+	parent.RefundedAmount += amount
+	if parent.RefundedAmount == parent.Amount {
+		s.setStatus(parent, structures.StatusRefundSuccess)
+	}
+
+	return http.StatusOK, s.transactionResponse(tx)
+}
+
+// reversal reverses a successful or held transaction that wasn't refunded
+func (s *Server) reversal(data *requestData) (int, interface{}) {
+	tx, status, failure := s.parent(data.CommandData.GatewayTransactionID)
+	if tx == nil {
+		return status, failure
+	}
+
+	if (tx.Status != structures.StatusSuccess && tx.Status != structures.StatusDmsHoldOK) || tx.RefundedAmount > 0 {
+		return stateInvalid(tx)
+	}
+	s.setStatus(tx, structures.StatusReversed)
+
+	return http.StatusOK, s.transactionResponse(tx)
+}
+
+// parent finds the transaction referenced by an operation, failure response is returned if it's not found
+func (s *Server) parent(gatewayTransactionID string) (*Transaction, int, interface{}) {
+	if gatewayTransactionID == "" {
+		status, failure := errorResult(http.StatusBadRequest, structures.EecNoParentTransactionProvided, "gateway transaction ID is required")
+		return nil, status, failure
+	}
+
+	tx, ok := s.transactions[gatewayTransactionID]
+	if !ok {
+		status, failure := errorResult(http.StatusBadRequest, structures.EecWrongGwUniqID, "transaction %s not found", gatewayTransactionID)
+		return nil, status, failure
+	}
+
+	return tx, 0, nil
+}
+
+func stateInvalid(tx *Transaction) (int, interface{}) {
+	return errorResult(http.StatusBadRequest, structures.EecTransactionStateInvalid,
+		"transaction %s has status %s", tx.GatewayTransactionID, StatusText(tx.Status))
+}
+
+// create registers a new transaction in StatusInit
+func (s *Server) create(opType structures.OperationType, data *requestData) *Transaction {
+	s.seq++
+	tx := &Transaction{
+		GatewayTransactionID:  fmt.Sprintf("00000000-0000-4000-8000-%012d", s.seq),
+		MerchantTransactionID: data.GeneralData.OrderData.MerchantTransactionID,
+		OperationType:         opType,
+		Status:                structures.StatusInit,
+		Amount:                data.Money.Amount,
+		Currency:              data.Money.Currency,
+		CreatedAt:             s.now(),
+	}
+	if data.PaymentMethod.Pan != "" {
+		tx.CardMask = redact.PAN(data.PaymentMethod.Pan)
+	}
+
+	s.transactions[tx.GatewayTransactionID] = tx
+	s.order = append(s.order, tx.GatewayTransactionID)
+	if tx.MerchantTransactionID != "" {
+		s.byMerchant[tx.MerchantTransactionID] = tx.GatewayTransactionID
+	}
+
+	return tx
+}
+
+// setStatus moves the transaction to given status, recording the change in its history
+func (s *Server) setStatus(tx *Transaction, status structures.Status) {
+	now := s.now()
+	tx.History = append(tx.History, StatusChange{Old: tx.Status, New: status, Time: now})
+	tx.Status = status
+	tx.FinishedAt = now
+}
+
+func (s *Server) transactionResponse(tx *Transaction) structures.TransactionResponse {
+	result := structures.TransactionResponse{
+		Gateway: structures.Gateway{
+			GatewayTransactionID:  tx.GatewayTransactionID,
+			MerchantTransactionID: tx.MerchantTransactionID,
+			StatusCode:            tx.Status,
+			StatusText:            StatusText(tx.Status),
+		},
+		AcquirerDetails: structures.AcquirerDetails{
+			TerminalID:    "gatewaytest",
+			TransactionID: "acq-" + tx.GatewayTransactionID,
+			ResultCode:    "000",
+			StatusText:    "Approved",
+		},
+	}
+
+	if tx.ParentGatewayTransactionID != "" {
+		parentID := tx.ParentGatewayTransactionID
+		result.Gateway.ParentGatewayTransactionID = &parentID
+	}
+
+	return result
+}
+
+func (tx *Transaction) info(accountGUID string) transactionInfo {
+	return transactionInfo{
+		AccountGUID:           accountGUID,
+		Amount:                json.Number(strconv.Itoa(tx.Amount)),
+		Currency:              tx.Currency,
+		DateFinished:          formatTime(tx.FinishedAt),
+		GatewayTransactionID:  tx.GatewayTransactionID,
+		MerchantTransactionID: tx.MerchantTransactionID,
+		StatusCode:            tx.Status,
+		StatusCodeGeneral:     tx.Status,
+		StatusText:            StatusText(tx.Status),
+		StatusTextGeneral:     StatusText(tx.Status),
+	}
+}
+
+// copy returns a deep copy of the transaction, safe to use without the server's lock
+func (tx *Transaction) copy() Transaction {
+	result := *tx
+	result.History = append([]StatusChange(nil), tx.History...)
+	return result
+}
@@ -0,0 +1,248 @@
+package gatewaytest
+
+import (
+	"net/http"
+	"testing"
+
+	tprogateway "github.com/TransactPRO/gw3-go-client"
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPAN = "4111111111111111"
+
+// perform sends the operation and parses transaction response
+func perform(t *testing.T, client *tprogateway.GatewayClient, op structures.OperationRequestInterface) (int, *structures.TransactionResponse) {
+	response, err := client.NewRequest(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := new(structures.TransactionResponse)
+	if err = response.ParseJSON(result); err != nil {
+		t.Fatal(err)
+	}
+
+	return response.StatusCode, result
+}
+
+func newSMS(client *tprogateway.GatewayClient, amount int) structures.OperationRequestInterface {
+	sms := client.OperationBuilder().NewSms()
+	sms.PaymentMethod.Pan = testPAN
+	sms.Money = structures.MoneyData{Amount: amount, Currency: "EUR"}
+	sms.GeneralData.OrderData.MerchantTransactionID = "order-1"
+	return sms
+}
+
+func TestPaymentOperations(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	builder := client.OperationBuilder()
+	money := structures.MoneyData{Amount: 100, Currency: "EUR"}
+	card := structures.PaymentMethodData{Pan: testPAN}
+
+	moto := builder.NewMOTOSMS()
+	moto.PaymentMethod, moto.Money = card, money
+	credit := builder.NewCredit()
+	credit.PaymentMethod, credit.Money = card, money
+	p2p := builder.NewP2P()
+	p2p.PaymentMethod, p2p.Money = card, money
+	b2p := builder.NewB2P()
+	b2p.PaymentMethod, b2p.Money = card, money
+	hold := builder.NewHoldDMS()
+	hold.PaymentMethod, hold.Money = card, money
+	motoDMS := builder.NewMOTODMS()
+	motoDMS.PaymentMethod, motoDMS.Money = card, money
+	token := builder.NewCreateToken()
+	token.PaymentMethod = card
+
+	cases := map[structures.OperationType]struct {
+		op     structures.OperationRequestInterface
+		status structures.Status
+	}{
+		structures.SMS:         {newSMS(client, 100), structures.StatusSuccess},
+		structures.MOTOSMS:     {moto, structures.StatusSuccess},
+		structures.CREDIT:      {credit, structures.StatusSuccess},
+		structures.P2P:         {p2p, structures.StatusSuccess},
+		structures.B2P:         {b2p, structures.StatusSuccess},
+		structures.DMSHold:     {hold, structures.StatusDmsHoldOK},
+		structures.MOTODMS:     {motoDMS, structures.StatusDmsHoldOK},
+		structures.CreateToken: {token, structures.StatusTokenCreated},
+	}
+
+	for opType, expected := range cases {
+		t.Run(string(opType), func(t *testing.T) {
+			httpStatus, result := perform(t, client, expected.op)
+			assert.Equal(t, http.StatusOK, httpStatus)
+			assert.Equal(t, expected.status, result.Gateway.StatusCode)
+			assert.Equal(t, StatusText(expected.status), result.Gateway.StatusText)
+
+			tx, ok := server.Transaction(result.Gateway.GatewayTransactionID)
+			assert.True(t, ok)
+			assert.Equal(t, opType, tx.OperationType)
+			assert.Equal(t, "411111***1111", tx.CardMask)
+		})
+	}
+}
+
+func TestPaymentValidation(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	sms := client.OperationBuilder().NewSms()
+	sms.Money = structures.MoneyData{Amount: 100, Currency: "EUR"}
+	httpStatus, result := perform(t, client, sms)
+	assert.Equal(t, http.StatusBadRequest, httpStatus)
+	assert.Equal(t, structures.EecInputValidationFailed, result.Error.Code)
+
+	httpStatus, result = perform(t, client, newSMS(client, 0))
+	assert.Equal(t, http.StatusBadRequest, httpStatus)
+	assert.Equal(t, structures.EecInputValidationFailed, result.Error.Code)
+
+	assert.Empty(t, server.Transactions())
+}
+
+func TestDMSFlow(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	builder := client.OperationBuilder()
+	hold := builder.NewHoldDMS()
+	hold.PaymentMethod.Pan = testPAN
+	hold.Money = structures.MoneyData{Amount: 500, Currency: "EUR"}
+	_, held := perform(t, client, hold)
+
+	charge := builder.NewChargeDMS()
+	charge.CommandData.GWTransactionID = held.Gateway.GatewayTransactionID
+	charge.Money.Amount = 600
+	httpStatus, result := perform(t, client, charge)
+	assert.Equal(t, http.StatusBadRequest, httpStatus)
+	assert.Equal(t, structures.EecInputValidationFailed, result.Error.Code)
+
+	charge.Money.Amount = 300
+	httpStatus, result = perform(t, client, charge)
+	assert.Equal(t, http.StatusOK, httpStatus)
+	assert.Equal(t, structures.StatusSuccess, result.Gateway.StatusCode)
+	assert.Equal(t, held.Gateway.GatewayTransactionID, result.Gateway.GatewayTransactionID)
+
+	tx, _ := server.Transaction(held.Gateway.GatewayTransactionID)
+	assert.Equal(t, 300, tx.Amount)
+
+	cancel := builder.NewCancel()
+	cancel.CommandData.GWTransactionID = held.Gateway.GatewayTransactionID
+	httpStatus, result = perform(t, client, cancel)
+	assert.Equal(t, http.StatusBadRequest, httpStatus)
+	assert.Equal(t, structures.EecTransactionStateInvalid, result.Error.Code)
+}
+
+func TestCancel(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	hold := client.OperationBuilder().NewHoldDMS()
+	hold.PaymentMethod.Pan = testPAN
+	hold.Money = structures.MoneyData{Amount: 500, Currency: "EUR"}
+	_, held := perform(t, client, hold)
+
+	cancel := client.OperationBuilder().NewCancel()
+	cancel.CommandData.GWTransactionID = held.Gateway.GatewayTransactionID
+	_, result := perform(t, client, cancel)
+	assert.Equal(t, structures.StatusDmsCanceled, result.Gateway.StatusCode)
+
+	cancel.CommandData.GWTransactionID = "unknown"
+	httpStatus, result := perform(t, client, cancel)
+	assert.Equal(t, http.StatusBadRequest, httpStatus)
+	assert.Equal(t, structures.EecWrongGwUniqID, result.Error.Code)
+}
+
+func TestRefund(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	_, paid := perform(t, client, newSMS(client, 1000))
+	paymentID := paid.Gateway.GatewayTransactionID
+
+	refund := client.OperationBuilder().NewRefund()
+	refund.CommandData.GWTransactionID = paymentID
+	refund.Money.Amount = 400
+	_, result := perform(t, client, refund)
+	assert.Equal(t, structures.StatusRefundSuccess, result.Gateway.StatusCode)
+	if assert.NotNil(t, result.Gateway.ParentGatewayTransactionID) {
+		assert.Equal(t, paymentID, *result.Gateway.ParentGatewayTransactionID)
+	}
+
+	tx, _ := server.Transaction(paymentID)
+	assert.Equal(t, structures.StatusSuccess, tx.Status)
+	assert.Equal(t, 400, tx.RefundedAmount)
+
+	refund.Money.Amount = 700
+	httpStatus, result := perform(t, client, refund)
+	assert.Equal(t, http.StatusBadRequest, httpStatus)
+	assert.Equal(t, structures.EecInputValidationFailed, result.Error.Code)
+
+	refund.Money.Amount = 0
+	_, result = perform(t, client, refund)
+	refundTx, _ := server.Transaction(result.Gateway.GatewayTransactionID)
+	assert.Equal(t, 600, refundTx.Amount)
+
+	tx, _ = server.Transaction(paymentID)
+	assert.Equal(t, structures.StatusRefundSuccess, tx.Status)
+
+	reversal := client.OperationBuilder().NewReversal()
+	reversal.CommandData.GWTransactionID = paymentID
+	httpStatus, result = perform(t, client, reversal)
+	assert.Equal(t, http.StatusBadRequest, httpStatus)
+	assert.Equal(t, structures.EecTransactionStateInvalid, result.Error.Code)
+}
+
+func TestReversal(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	_, paid := perform(t, client, newSMS(client, 1000))
+
+	reversal := client.OperationBuilder().NewReversal()
+	reversal.CommandData.GWTransactionID = paid.Gateway.GatewayTransactionID
+	_, result := perform(t, client, reversal)
+	assert.Equal(t, structures.StatusReversed, result.Gateway.StatusCode)
+
+	tx, _ := server.Transaction(paid.Gateway.GatewayTransactionID)
+	if assert.Len(t, tx.History, 2) {
+		assert.Equal(t, StatusChange{Old: structures.StatusSuccess, New: structures.StatusReversed, Time: tx.History[1].Time}, tx.History[1])
+	}
+}
+
+func TestRecurrents(t *testing.T) {
+	server, client := newTestServer(t)
+	defer server.Close()
+
+	builder := client.OperationBuilder()
+	init := builder.NewInitRecurrentSMS()
+	init.PaymentMethod.Pan = testPAN
+	init.Money = structures.MoneyData{Amount: 100, Currency: "EUR"}
+	_, initial := perform(t, client, init)
+	initialID := initial.Gateway.GatewayTransactionID
+
+	recurrent := builder.NewRecurrentSMS()
+	recurrent.CommandData.GWTransactionID = initialID
+	recurrent.Money = structures.MoneyData{Amount: 100, Currency: "EUR"}
+	_, result := perform(t, client, recurrent)
+	assert.Equal(t, structures.StatusSuccess, result.Gateway.StatusCode)
+
+	tx, _ := server.Transaction(result.Gateway.GatewayTransactionID)
+	assert.Equal(t, initialID, tx.ParentGatewayTransactionID)
+	assert.Equal(t, "411111***1111", tx.CardMask)
+
+	recurrentDMS := builder.NewRecurrentDMS()
+	recurrentDMS.CommandData.GWTransactionID = initialID
+	recurrentDMS.Money = structures.MoneyData{Amount: 100, Currency: "EUR"}
+	httpStatus, result := perform(t, client, recurrentDMS)
+	assert.Equal(t, http.StatusBadRequest, httpStatus)
+	assert.Equal(t, structures.EecTransactionTypeInvalid, result.Error.Code)
+
+	recurrentDMS.CommandData.GWTransactionID = ""
+	httpStatus, result = perform(t, client, recurrentDMS)
+	assert.Equal(t, http.StatusBadRequest, httpStatus)
+	assert.Equal(t, structures.EecNoParentTransactionProvided, result.Error.Code)
+}
@@ -0,0 +1,120 @@
+package gatewaytest
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// timeLayout is the format of dates in exploring responses
+const timeLayout = "2006-01-02 15:04:05"
+
+type (
+	// request is a union of all operation request payloads
+	request struct {
+		Data       requestData `json:"data"`
+		FilterData reportData  `json:"filter-data"`
+	}
+
+	requestData struct {
+		CommandData struct {
+			GatewayTransactionID    string   `json:"gateway-transaction-id"`
+			GatewayTransactionIDs   []string `json:"gateway-transaction-ids"`
+			MerchantTransactionIDs  []string `json:"merchant-transaction-ids"`
+			PaymentMethodDataSource uint     `json:"payment-method-data-source"`
+			PaymentMethodDataToken  string   `json:"payment-method-data-token"`
+		} `json:"command-data"`
+		GeneralData   structures.GeneralData       `json:"general-data"`
+		PaymentMethod structures.PaymentMethodData `json:"payment-method-data"`
+		Money         structures.MoneyData         `json:"money-data"`
+
+		// verify requests have their fields on the top level
+		GatewayTransactionID string `json:"gateway-transaction-id"`
+		Pan                  string `json:"pan"`
+		Currency             string `json:"currency"`
+	}
+
+	// reportData holds report filters as Unix timestamps, the way structures.Time is marshaled
+	reportData struct {
+		DateCreatedFrom  *int64 `json:"dt-created-from"`
+		DateCreatedTo    *int64 `json:"dt-created-to"`
+		DateFinishedFrom *int64 `json:"dt-finished-from"`
+		DateFinishedTo   *int64 `json:"dt-finished-to"`
+	}
+
+	errorResponse struct {
+		Error structures.Error `json:"error"`
+	}
+
+	enrollmentResponse struct {
+		Enrollment string `json:"enrollment"`
+	}
+
+	// exploring responses are declared here, since structures.Time can't be marshaled the way it's unmarshaled
+
+	exploringResponse struct {
+		Transactions []interface{} `json:"transactions"`
+	}
+
+	exploringItem struct {
+		Error                *structures.Error `json:"error,omitempty"`
+		GatewayTransactionID string            `json:"gateway-transaction-id,omitempty"`
+	}
+
+	statusItem struct {
+		exploringItem
+		Status []structures.TransactionStatus `json:"status"`
+	}
+
+	resultItem struct {
+		exploringItem
+		DateCreated  string                         `json:"date-created,omitempty"`
+		DateFinished string                         `json:"date-finished,omitempty"`
+		ResultData   structures.TransactionResponse `json:"result-data"`
+	}
+
+	historyItem struct {
+		exploringItem
+		History []historyEvent `json:"history"`
+	}
+
+	historyEvent struct {
+		DateUpdated   string            `json:"date-updated"`
+		StatusCodeNew structures.Status `json:"status-code-new"`
+		StatusCodeOld structures.Status `json:"status-code-old"`
+		StatusTextNew string            `json:"status-text-new"`
+		StatusTextOld string            `json:"status-text-old"`
+	}
+
+	recurrentsItem struct {
+		exploringItem
+		Recurrents []transactionInfo `json:"recurrents"`
+	}
+
+	refundsItem struct {
+		exploringItem
+		Refunds []transactionInfo `json:"refunds"`
+	}
+
+	transactionInfo struct {
+		AccountGUID           string            `json:"account-guid,omitempty"`
+		Amount                json.Number       `json:"amount"`
+		Currency              string            `json:"currency,omitempty"`
+		DateFinished          string            `json:"date-finished,omitempty"`
+		GatewayTransactionID  string            `json:"gateway-transaction-id"`
+		MerchantTransactionID string            `json:"merchant-transaction-id,omitempty"`
+		StatusCode            structures.Status `json:"status-code"`
+		StatusCodeGeneral     structures.Status `json:"status-code-general"`
+		StatusText            string            `json:"status-text"`
+		StatusTextGeneral     string            `json:"status-text-general"`
+	}
+)
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(timeLayout)
+}
@@ -0,0 +1,176 @@
+// Package handlers provides HTTP handlers for the receiving side of Transact Pro Gateway digest authentication:
+// authentication of inbound requests signed like the Gateway client does and Gateway callbacks processing.
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// DefaultMaxBodySize limits the size of request body read for digest verification
+const DefaultMaxBodySize = 1 << 20
+
+// ErrUnknownCredentials should be returned by CredentialLookup if object GUID is unknown
+var ErrUnknownCredentials = errors.New("unknown credentials")
+
+// CredentialLookup returns the secret key for given object GUID
+type CredentialLookup func(ctx context.Context, objectGUID string) (secret string, err error)
+
+type contextKey struct{}
+
+// DigestAuthenticator is an HTTP middleware that authenticates inbound requests signed with
+// Gateway digest scheme (like structures.RequestDigest.CreateHeader produces).
+// Unauthenticated requests are answered with 401 status and never reach the wrapped handler.
+type DigestAuthenticator struct {
+	// Lookup resolves object GUID to its secret key
+	Lookup CredentialLookup
+	// ParseMode sets how strictly Authorization header is parsed
+	ParseMode structures.DigestParseMode
+	// ReplayGuard rejects stale and replayed requests, the check is skipped if nil
+	ReplayGuard *structures.ReplayGuard
+	// AllowedAlgorithms limits accepted digest algorithms, any supported algorithm is accepted if empty
+	AllowedAlgorithms []structures.Algorithm
+	// RequiredQOP rejects requests signed with another QOP if set.
+	// If not set, requests with body or a method other than GET must be signed with structures.QopAuthInt,
+	// since structures.QopAuth doesn't cover the body.
+	RequiredQOP structures.QOP
+	// MaxBodySize limits the size of request body, larger requests are answered with 413 status
+	MaxBodySize int64
+	// ErrorLog receives the reasons of rejected requests, nothing is logged if nil
+	ErrorLog *log.Logger
+}
+
+// NewDigestAuthenticator creates DigestAuthenticator with given credential lookup, strict header parsing,
+// default replay guard and DefaultMaxBodySize
+func NewDigestAuthenticator(lookup CredentialLookup) *DigestAuthenticator {
+	return &DigestAuthenticator{
+		Lookup:      lookup,
+		ParseMode:   structures.DigestParseStrict,
+		ReplayGuard: structures.NewReplayGuard(structures.DefaultMaxClockSkew, nil),
+		MaxBodySize: DefaultMaxBodySize,
+	}
+}
+
+// ObjectGUIDFromContext returns object GUID of the request authenticated by DigestAuthenticator
+func ObjectGUIDFromContext(ctx context.Context) (string, bool) {
+	objectGUID, ok := ctx.Value(contextKey{}).(string)
+	return objectGUID, ok
+}
+
+// Wrap returns a handler that authenticates requests before passing them to next handler.
+// The request body is restored, so next handler can read it again.
+func (a *DigestAuthenticator) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, status, err := readBody(r, a.MaxBodySize)
+		if err != nil {
+			a.reject(w, r, status, err)
+			return
+		}
+
+		var objectGUID string
+		if objectGUID, err = a.Authenticate(r, body); err != nil {
+			a.reject(w, r, http.StatusUnauthorized, err)
+			return
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, objectGUID)))
+	})
+}
+
+// Authenticate verifies request Authorization header against given body and returns authenticated object GUID
+func (a *DigestAuthenticator) Authenticate(r *http.Request, body []byte) (string, error) {
+	if a.Lookup == nil {
+		return "", errors.New("credential lookup is not configured")
+	}
+
+	requestDigest, err := structures.NewInboundRequestDigest(r.Header.Get("Authorization"), a.ParseMode)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := a.Lookup(r.Context(), requestDigest.Username)
+	if err != nil {
+		return "", fmt.Errorf("credential lookup for %s: %w", requestDigest.Username, err)
+	}
+
+	requestDigest.ActualURI = r.URL.Path
+	requestDigest.Body = body
+	requestDigest.AllowedAlgorithms = a.AllowedAlgorithms
+	requestDigest.RequiredQOP = a.RequiredQOP
+	if requestDigest.RequiredQOP == structures.QopUnknown && (len(body) > 0 || r.Method != http.MethodGet) {
+		requestDigest.RequiredQOP = structures.QopAuthInt
+	}
+	if err = requestDigest.VerifyFresh(requestDigest.Username, secret, a.ReplayGuard); err != nil {
+		return "", err
+	}
+
+	return requestDigest.Username, nil
+}
+
+func (a *DigestAuthenticator) reject(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if a.ErrorLog != nil {
+		a.ErrorLog.Printf("%s %s rejected: %s", r.Method, r.URL.Path, err)
+	}
+
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", structures.DigestScheme)
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// readBody reads request body up to maxSize bytes, HTTP status to answer with is returned on failure
+func readBody(r *http.Request, maxSize int64) ([]byte, int, error) {
+	if r.Body == nil {
+		return nil, 0, nil
+	}
+
+	var reader io.Reader = r.Body
+	if maxSize > 0 {
+		reader = &limitedReader{r: r.Body, n: maxSize}
+	}
+
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		if errors.Is(err, errBodyTooLarge) {
+			return nil, http.StatusRequestEntityTooLarge, err
+		}
+		return nil, http.StatusBadRequest, fmt.Errorf("cannot read request body: %w", err)
+	}
+
+	return body, 0, nil
+}
+
+var errBodyTooLarge = errors.New("request body is too large")
+
+// limitedReader fails instead of truncating when the limit is exceeded
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, errBodyTooLarge
+	}
+
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errBodyTooLarge
+	}
+
+	return n, err
+}
@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/TransactPRO/gw3-go-client/store"
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// Callback form fields posted by the Gateway
+const (
+	CallbackJSONField = "json"
+	CallbackSignField = "sign"
+)
+
+// CallbackAck is written as a response body to acknowledge successfully processed callback
+const CallbackAck = "OK"
+
+// CallbackKind groups callback statuses for dispatching
+type CallbackKind int
+
+// Callback kinds
+const (
+	CallbackOther CallbackKind = iota
+	CallbackSuccess
+	CallbackDeclined
+	CallbackRefund
+	CallbackError
+)
+
+var callbackKind2string = map[CallbackKind]string{
+	CallbackOther:    "other",
+	CallbackSuccess:  "success",
+	CallbackDeclined: "declined",
+	CallbackRefund:   "refund",
+	CallbackError:    "error",
+}
+
+func (o CallbackKind) String() string {
+	if result, ok := callbackKind2string[o]; ok {
+		return result
+	}
+
+	return "unknown"
+}
+
+var status2callbackKind = map[structures.Status]CallbackKind{
+	structures.StatusSuccess:      CallbackSuccess,
+	structures.StatusDmsHoldOK:    CallbackSuccess,
+	structures.StatusTokenCreated: CallbackSuccess,
+
+	structures.StatusRefundPending: CallbackRefund,
+	structures.StatusRefundSuccess: CallbackRefund,
+	structures.StatusRefundFailed:  CallbackRefund,
+	structures.StatusReversed:      CallbackRefund,
+	structures.StatusDmsCanceled:   CallbackRefund,
+
+	structures.StatusDmsHoldFailed:                 CallbackDeclined,
+	structures.StatusSmsFailed:                     CallbackDeclined,
+	structures.StatusDmsChargeFailed:               CallbackDeclined,
+	structures.StatusExpired:                       CallbackDeclined,
+	structures.StatusHoldExpired:                   CallbackDeclined,
+	structures.StatusDmsCancelFailed:               CallbackDeclined,
+	structures.StatusInputValidationFailed:         CallbackDeclined,
+	structures.StatusBusinessRulesValidationFailed: CallbackDeclined,
+	structures.StatusTerminalGroupSelectFailed:     CallbackDeclined,
+	structures.StatusTerminalSelectFailed:          CallbackDeclined,
+	structures.StatusInitParamsInvalid:             CallbackDeclined,
+	structures.StatusDeclinedByBusinessRulesAction: CallbackDeclined,
+	structures.StatusMpiFailed:                     CallbackDeclined,
+	structures.StatusMpiNotReachable:               CallbackDeclined,
+	structures.StatusMpiAuthError:                  CallbackDeclined,
+	structures.StatusAcquirerNotReachable:          CallbackDeclined,
+	structures.StatusReversalFailed:                CallbackDeclined,
+	structures.StatusCreditFailed:                  CallbackDeclined,
+	structures.StatusP2PFailed:                     CallbackDeclined,
+	structures.StatusB2PFailed:                     CallbackDeclined,
+	structures.StatusTokenCreateFailed:             CallbackDeclined,
+}
+
+// ClassifyCallback returns callback kind by its transaction status (refund statuses include reversal and DMS cancellation).
+// Callbacks with a Gateway error are CallbackError unless the result data has a final status,
+// callbacks with intermediate statuses (like StatusCardholderOnSite) are CallbackOther.
+func ClassifyCallback(result *structures.CallbackResult) CallbackKind {
+	if result.Error.Code != 0 {
+		return CallbackError
+	}
+
+	if kind, ok := status2callbackKind[result.ResultData.Gateway.StatusCode]; ok {
+		return kind
+	}
+
+	if result.ResultData.Error.Code != 0 {
+		return CallbackError
+	}
+
+	return CallbackOther
+}
+
+type (
+	// Callback is a verified Gateway callback
+	Callback struct {
+		ObjectGUID string
+		Kind       CallbackKind
+		Result     structures.CallbackResult
+		Digest     *structures.ResponseDigest
+		// Payload is the raw JSON payload the signature was verified for
+		Payload []byte
+		// Disposition is set by CallbackHandler with Dedup store, functions may ignore store.CallbackOutOfOrder callbacks
+		Disposition store.CallbackDisposition
+	}
+
+	// CallbackFunc processes verified callback.
+	// Returned error makes the handler answer with 500 status, so the Gateway delivers the callback again.
+	CallbackFunc func(ctx context.Context, callback *Callback) error
+)
+
+// CallbackHandler is an http.Handler for Gateway callbacks.
+// It verifies the callback signature, decodes the payload and dispatches it to a callback function by its kind.
+// Unsigned, tampered and malformed callbacks are rejected and never reach callback functions.
+type CallbackHandler struct {
+	// Lookup resolves object GUID from the signature to its secret key
+	Lookup CredentialLookup
+	// ParseMode sets how strictly the signature is parsed
+	ParseMode structures.DigestParseMode
+	// ReplayGuard rejects stale and replayed callbacks, the check is skipped if nil.
+	// Snonces of callbacks which processing failed are forgotten, so their redelivery is processed again.
+	// With Dedup store only the snonce timestamp is checked, replays are acknowledged as duplicates by the store.
+	ReplayGuard *structures.ReplayGuard
+	// AllowedAlgorithms limits accepted digest algorithms, any supported algorithm is accepted if empty
+	AllowedAlgorithms []structures.Algorithm
+	// Digests provides original request's URI and cnonce to verify callbacks against.
+	// If set, callbacks for transactions without a stored digest are rejected
+	// (a callback arriving before the digest is saved will be accepted on redelivery,
+	// a transaction which digest failed to be saved by the client gets its callbacks rejected until it's saved).
+	Digests store.DigestStore
+	// Dedup makes every status transition of a transaction processed once if set:
+	// a callback is claimed before calling the callback function and committed after it succeeded,
+	// duplicates of processed callbacks are acknowledged without calling callback functions,
+	// redeliveries of a callback being processed are answered with 409 status, so the Gateway delivers them again,
+	// callbacks which processing failed are forgotten, so their redelivery is processed again
+	Dedup store.CallbackDedupStore
+	// MaxBodySize limits the size of request body, larger requests are answered with 413 status
+	MaxBodySize int64
+	// ErrorLog receives the reasons of rejected and failed callbacks, nothing is logged if nil
+	ErrorLog *log.Logger
+
+	OnSuccess  CallbackFunc
+	OnDeclined CallbackFunc
+	OnRefund   CallbackFunc
+	OnError    CallbackFunc
+	// OnOther receives callbacks with intermediate statuses
+	OnOther CallbackFunc
+}
+
+// NewCallbackHandler creates CallbackHandler with given credential lookup, lenient signature parsing,
+// an in-memory replay guard with DefaultMaxClockSkew, DefaultMaxBodySize and the standard logger as ErrorLog.
+// Callback functions should be set on the result.
+func NewCallbackHandler(lookup CredentialLookup) *CallbackHandler {
+	return &CallbackHandler{
+		Lookup:      lookup,
+		ParseMode:   structures.DigestParseLenient,
+		ReplayGuard: structures.NewReplayGuard(structures.DefaultMaxClockSkew, nil),
+		MaxBodySize: DefaultMaxBodySize,
+		ErrorLog:    log.Default(),
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.reject(w, r, http.StatusMethodNotAllowed, fmt.Errorf("unexpected method %s", r.Method))
+		return
+	}
+
+	body, status, err := readBody(r, h.MaxBodySize)
+	if err != nil {
+		h.reject(w, r, status, err)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		h.reject(w, r, http.StatusBadRequest, fmt.Errorf("cannot parse form: %w", err))
+		return
+	}
+
+	// snonces are checked for replays below, once it's known whether the dedup store handles duplicates
+	callback, err := h.verify(r.Context(), []byte(form.Get(CallbackJSONField)), form.Get(CallbackSignField), h.freshnessGuard())
+	if err != nil {
+		status = http.StatusUnauthorized
+		if errors.Is(err, errMalformedCallback) {
+			status = http.StatusBadRequest
+		}
+		h.reject(w, r, status, err)
+		return
+	}
+
+	gatewayTransactionID := callback.Result.ResultData.Gateway.GatewayTransactionID
+	dedupKey := store.CallbackKey{
+		GatewayTransactionID: gatewayTransactionID,
+		Status:               callback.Result.ResultData.Gateway.StatusCode,
+		Snonce:               callback.Digest.Snonce,
+	}
+	deduplicated := h.Dedup != nil && gatewayTransactionID != ""
+	if deduplicated {
+		if callback.Disposition, err = h.Dedup.Claim(r.Context(), dedupKey); err != nil {
+			h.logf("callback %s for %s failed: %s", callback.Kind, gatewayTransactionID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		if callback.Disposition == store.CallbackInProgress {
+			h.logf("callback %s for %s is being processed by another delivery", callback.Kind, gatewayTransactionID)
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+			return
+		}
+	} else if h.ReplayGuard != nil {
+		if err = h.ReplayGuard.Check(callback.Digest.Timestamp, callback.Digest.Snonce); err != nil {
+			h.reject(w, r, http.StatusUnauthorized, err)
+			return
+		}
+	}
+
+	if fn := h.callbackFunc(callback.Kind); fn != nil && callback.Disposition != store.CallbackDuplicate {
+		if err = fn(r.Context(), callback); err != nil {
+			h.logf("callback %s for %s failed: %s", callback.Kind, gatewayTransactionID, err)
+			if deduplicated {
+				if forgetErr := h.Dedup.Forget(r.Context(), dedupKey); forgetErr != nil {
+					h.logf("callback %s for %s can't be forgotten: %s", callback.Kind, gatewayTransactionID, forgetErr)
+				}
+			} else {
+				h.forgetSnonce(callback)
+			}
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// the callback is processed, so it's acknowledged even if the claim can't be committed
+	if deduplicated && callback.Disposition != store.CallbackDuplicate {
+		if err = h.Dedup.Commit(r.Context(), dedupKey); err != nil {
+			h.logf("callback %s for %s can't be committed: %s", callback.Kind, gatewayTransactionID, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(CallbackAck))
+}
+
+var errMalformedCallback = errors.New("malformed callback")
+
+// Verify verifies callback payload signature and decodes the payload.
+// The snonce is remembered by ReplayGuard, use ReplayGuard.Forget if the callback can't be processed.
+func (h *CallbackHandler) Verify(ctx context.Context, payload []byte, sign string) (*Callback, error) {
+	return h.verify(ctx, payload, sign, h.ReplayGuard)
+}
+
+// freshnessGuard returns ReplayGuard without its nonce store, so only the snonce timestamp is checked
+func (h *CallbackHandler) freshnessGuard() *structures.ReplayGuard {
+	if h.ReplayGuard == nil {
+		return nil
+	}
+
+	return &structures.ReplayGuard{MaxClockSkew: h.ReplayGuard.MaxClockSkew, Now: h.ReplayGuard.Now}
+}
+
+func (h *CallbackHandler) verify(ctx context.Context, payload []byte, sign string, guard *structures.ReplayGuard) (*Callback, error) {
+	if h.Lookup == nil {
+		return nil, errors.New("credential lookup is not configured")
+	}
+
+	if len(sign) == 0 {
+		return nil, errors.New("callback is not signed")
+	}
+
+	digest, err := structures.NewResponseDigestWithMode(sign, h.ParseMode)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := h.Lookup(ctx, digest.Username)
+	if err != nil {
+		return nil, fmt.Errorf("credential lookup for %s: %w", digest.Username, err)
+	}
+
+	callback := &Callback{ObjectGUID: digest.Username, Digest: digest, Payload: payload}
+	if err = json.Unmarshal(payload, &callback.Result); err != nil {
+		return nil, fmt.Errorf("%w: cannot decode payload: %s", errMalformedCallback, err)
+	}
+
+	if err = h.setOriginalDigest(ctx, digest, callback.Result.ResultData.Gateway.GatewayTransactionID); err != nil {
+		return nil, err
+	}
+
+	digest.Body = payload
+	digest.AllowedAlgorithms = h.AllowedAlgorithms
+	if err = digest.VerifyFresh(digest.Username, secret, guard); err != nil {
+		return nil, err
+	}
+	callback.Kind = ClassifyCallback(&callback.Result)
+
+	return callback, nil
+}
+
+// setOriginalDigest sets original request's URI and cnonce from the digest store, if configured
+func (h *CallbackHandler) setOriginalDigest(ctx context.Context, digest *structures.ResponseDigest, gatewayTransactionID string) error {
+	if h.Digests == nil || gatewayTransactionID == "" {
+		return nil
+	}
+
+	record, err := h.Digests.FindByGatewayTransactionID(ctx, gatewayTransactionID)
+	if err != nil {
+		return fmt.Errorf("original request digest for %s: %w", gatewayTransactionID, err)
+	}
+
+	digest.OriginalURI = record.URI
+	digest.OriginalCnonce = record.Cnonce
+	return nil
+}
+
+// forgetSnonce removes the snonce of a failed callback from the replay guard, so its redelivery isn't rejected
+func (h *CallbackHandler) forgetSnonce(callback *Callback) {
+	if h.ReplayGuard == nil {
+		return
+	}
+
+	if err := h.ReplayGuard.Forget(callback.Digest.Snonce); err != nil {
+		h.logf("callback %s for %s snonce can't be forgotten: %s", callback.Kind,
+			callback.Result.ResultData.Gateway.GatewayTransactionID, err)
+	}
+}
+
+func (h *CallbackHandler) callbackFunc(kind CallbackKind) CallbackFunc {
+	switch kind {
+	case CallbackSuccess:
+		return h.OnSuccess
+	case CallbackDeclined:
+		return h.OnDeclined
+	case CallbackRefund:
+		return h.OnRefund
+	case CallbackError:
+		return h.OnError
+	default:
+		return h.OnOther
+	}
+}
+
+func (h *CallbackHandler) reject(w http.ResponseWriter, r *http.Request, status int, err error) {
+	h.logf("callback from %s rejected: %s", r.RemoteAddr, err)
+	http.Error(w, http.StatusText(status), status)
+}
+
+func (h *CallbackHandler) logf(format string, args ...interface{}) {
+	if h.ErrorLog != nil {
+		h.ErrorLog.Printf(format, args...)
+	}
+}
@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/store"
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+func signCallback(t *testing.T, secret, payload string) string {
+	responseDigest, err := structures.NewResponseDigestForRequest(testObjectGUID, "/v3.0/sms", []byte("1:cnonce"), []byte(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign, err := responseDigest.CreateHeader(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sign
+}
+
+func newCallbackRequest(payload, sign string) *http.Request {
+	form := url.Values{}
+	form.Set(CallbackJSONField, payload)
+	if sign != "" {
+		form.Set(CallbackSignField, sign)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func TestClassifyCallback(t *testing.T) {
+	examples := []struct {
+		payload  string
+		expected CallbackKind
+	}{
+		{`{"result-data":{"gw":{"status-code":7}}}`, CallbackSuccess},
+		{`{"result-data":{"gw":{"status-code":3}}}`, CallbackSuccess},
+		{`{"result-data":{"gw":{"status-code":13}}}`, CallbackRefund},
+		{`{"result-data":{"gw":{"status-code":15}}}`, CallbackRefund},
+		{`{"result-data":{"gw":{"status-code":16}}}`, CallbackDeclined},
+		{`{"result-data":{"gw":{"status-code":5},"error":{"code":1102}}}`, CallbackDeclined},
+		{`{"result-data":{"gw":{"status-code":14}}}`, CallbackOther},
+		{`{"error":{"code":1000,"message":"General error"}}`, CallbackError},
+		{`{"result-data":{"gw":{"status-code":2},"error":{"code":1007}}}`, CallbackError},
+	}
+
+	for _, testCase := range examples {
+		t.Run(testCase.payload, func(t *testing.T) {
+			var result structures.CallbackResult
+			assert.NoError(t, jsonUnmarshal(testCase.payload, &result))
+			assert.Equal(t, testCase.expected, ClassifyCallback(&result))
+		})
+	}
+}
+
+func TestCallbackHandlerDispatch(t *testing.T) {
+	var dispatched []string
+	record := func(name string) CallbackFunc {
+		return func(ctx context.Context, callback *Callback) error {
+			dispatched = append(dispatched, name+":"+callback.Result.ResultData.Gateway.GatewayTransactionID)
+			return nil
+		}
+	}
+
+	handler := NewCallbackHandler(testLookup)
+	handler.OnSuccess = record("success")
+	handler.OnDeclined = record("declined")
+	handler.OnRefund = record("refund")
+	handler.OnError = record("error")
+
+	payloads := []string{
+		`{"result-data":{"gw":{"gateway-transaction-id":"a","status-code":7}}}`,
+		`{"result-data":{"gw":{"gateway-transaction-id":"b","status-code":5}}}`,
+		`{"result-data":{"gw":{"gateway-transaction-id":"c","status-code":13}}}`,
+		`{"error":{"code":1000}}`,
+		`{"result-data":{"gw":{"gateway-transaction-id":"d","status-code":14}}}`,
+	}
+
+	for _, payload := range payloads {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, newCallbackRequest(payload, signCallback(t, testSecretKey, payload)))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, CallbackAck, recorder.Body.String())
+	}
+
+	assert.Equal(t, []string{"success:a", "declined:b", "refund:c", "error:"}, dispatched)
+}
+
+func TestCallbackHandlerRejects(t *testing.T) {
+	payload := `{"result-data":{"gw":{"gateway-transaction-id":"a","status-code":7}}}`
+	validSign := signCallback(t, testSecretKey, payload)
+
+	examples := []struct {
+		name     string
+		request  *http.Request
+		status   int
+		logEntry string
+	}{
+		{"unsigned", newCallbackRequest(payload, ""), http.StatusUnauthorized, "callback is not signed"},
+		{"tampered", newCallbackRequest(strings.Replace(payload, "7", "8", 1), signCallback(t, testSecretKey, payload)),
+			http.StatusUnauthorized, "digest mismatch"},
+		{"wrong secret", newCallbackRequest(payload, signCallback(t, "wrong", payload)), http.StatusUnauthorized, "digest mismatch"},
+		{"garbage sign", newCallbackRequest(payload, "Digest username=\"x"), http.StatusUnauthorized, "unterminated quoted string"},
+		{"not json", newCallbackRequest("{", signCallback(t, testSecretKey, "{")), http.StatusBadRequest, "malformed callback: cannot decode payload"},
+		{"method", httptest.NewRequest(http.MethodGet, "/callback", nil), http.StatusMethodNotAllowed, "unexpected method GET"},
+	}
+
+	var logBuffer bytes.Buffer
+	called := false
+	handler := NewCallbackHandler(testLookup)
+	handler.ErrorLog = log.New(&logBuffer, "", 0)
+	handler.OnSuccess = func(ctx context.Context, callback *Callback) error {
+		called = true
+		return nil
+	}
+
+	for _, testCase := range examples {
+		t.Run(testCase.name, func(t *testing.T) {
+			called = false
+			logBuffer.Reset()
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, testCase.request)
+
+			assert.False(t, called)
+			assert.Equal(t, testCase.status, recorder.Code)
+			assert.Contains(t, logBuffer.String(), "callback from 192.0.2.1:1234 rejected: ")
+			assert.Contains(t, logBuffer.String(), testCase.logEntry)
+		})
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newCallbackRequest(payload, validSign))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.True(t, called)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newCallbackRequest(payload, validSign))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestNewCallbackHandlerDefaults(t *testing.T) {
+	handler := NewCallbackHandler(testLookup)
+	assert.Same(t, log.Default(), handler.ErrorLog)
+	if assert.NotNil(t, handler.ReplayGuard) {
+		assert.Equal(t, structures.DefaultMaxClockSkew, handler.ReplayGuard.MaxClockSkew)
+	}
+}
+
+func TestCallbackHandlerFunctionError(t *testing.T) {
+	var logBuffer bytes.Buffer
+	handler := NewCallbackHandler(testLookup)
+	handler.ErrorLog = log.New(&logBuffer, "", 0)
+	handler.OnSuccess = func(ctx context.Context, callback *Callback) error {
+		return errors.New("database is down")
+	}
+
+	payload := `{"result-data":{"gw":{"gateway-transaction-id":"a","status-code":7}}}`
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newCallbackRequest(payload, signCallback(t, testSecretKey, payload)))
+
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, "callback success for a failed: database is down\n", logBuffer.String())
+}
+
+func TestCallbackHandlerReplayGuardRedelivery(t *testing.T) {
+	failNext := true
+	calls := 0
+	handler := NewCallbackHandler(testLookup)
+	handler.OnSuccess = func(ctx context.Context, callback *Callback) error {
+		calls++
+		if failNext {
+			failNext = false
+			return errors.New("database is down")
+		}
+		return nil
+	}
+
+	payload := `{"result-data":{"gw":{"gateway-transaction-id":"a","status-code":7}}}`
+	sign := signCallback(t, testSecretKey, payload)
+	deliver := func() int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, newCallbackRequest(payload, sign))
+		return recorder.Code
+	}
+
+	// redelivery of a failed callback is processed again, a replay of the processed one is rejected
+	assert.Equal(t, http.StatusInternalServerError, deliver())
+	assert.Equal(t, http.StatusOK, deliver())
+	assert.Equal(t, http.StatusUnauthorized, deliver())
+	assert.Equal(t, 2, calls)
+}
+
+func TestCallbackHandlerDedupInProgress(t *testing.T) {
+	dedup := store.NewMemoryCallbackDedupStore()
+	dedup.ClaimTimeout = 50 * time.Millisecond
+
+	calls := 0
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := NewCallbackHandler(testLookup)
+	handler.Dedup = dedup
+	handler.OnSuccess = func(ctx context.Context, callback *Callback) error {
+		calls++
+		if calls == 1 {
+			close(started)
+			<-release
+		}
+		return nil
+	}
+
+	deliver := func(payload string) int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, newCallbackRequest(payload, signCallback(t, testSecretKey, payload)))
+		return recorder.Code
+	}
+
+	// a redelivery arriving while the callback is processed isn't acknowledged
+	payload := `{"result-data":{"gw":{"gateway-transaction-id":"a","status-code":7}}}`
+	done := make(chan int)
+	go func() { done <- deliver(payload) }()
+	<-started
+	assert.Equal(t, http.StatusConflict, deliver(payload))
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, http.StatusOK, deliver(payload))
+	assert.Equal(t, 1, calls)
+
+	// a claim abandoned by a crashed process is taken over after the timeout
+	abandoned := `{"result-data":{"gw":{"gateway-transaction-id":"b","status-code":7}}}`
+	_, err := dedup.Claim(context.Background(), store.CallbackKey{GatewayTransactionID: "b", Status: structures.StatusSuccess})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, deliver(abandoned))
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, deliver(abandoned))
+	assert.Equal(t, 2, calls)
+}
+
+func TestCallbackHandlerDedupWithReplayGuard(t *testing.T) {
+	calls := 0
+	handler := NewCallbackHandler(testLookup)
+	handler.Dedup = store.NewMemoryCallbackDedupStore()
+	handler.OnSuccess = func(ctx context.Context, callback *Callback) error {
+		calls++
+		return nil
+	}
+
+	deliver := func(payload, sign string) int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, newCallbackRequest(payload, sign))
+		return recorder.Code
+	}
+
+	payload := `{"result-data":{"gw":{"gateway-transaction-id":"a","status-code":7}}}`
+	sign := signCallback(t, testSecretKey, payload)
+
+	// a replayed snonce is acknowledged as a duplicate
+	assert.Equal(t, http.StatusOK, deliver(payload, sign))
+	assert.Equal(t, http.StatusOK, deliver(payload, sign))
+	assert.Equal(t, 1, calls)
+
+	// callbacks without gateway transaction ID aren't deduplicated, their replays are rejected
+	withoutID := `{"result-data":{"gw":{"status-code":7}}}`
+	sign = signCallback(t, testSecretKey, withoutID)
+	assert.Equal(t, http.StatusOK, deliver(withoutID, sign))
+	assert.Equal(t, http.StatusUnauthorized, deliver(withoutID, sign))
+	assert.Equal(t, 2, calls)
+
+	// stale callbacks are rejected anyway
+	handler.ReplayGuard.Now = func() time.Time { return time.Now().Add(time.Hour) }
+	assert.Equal(t, http.StatusUnauthorized, deliver(payload, signCallback(t, testSecretKey, payload)))
+}
+
+func jsonUnmarshal(payload string, v interface{}) error {
+	return json.Unmarshal([]byte(payload), v)
+}
+
+func TestCallbackHandlerDigestStore(t *testing.T) {
+	digests := store.NewMemoryDigestStore()
+	_ = digests.Save(context.Background(), store.DigestRecord{GatewayTransactionID: "a", URI: "/v3.0/sms", Cnonce: []byte("1:cnonce")})
+	_ = digests.Save(context.Background(), store.DigestRecord{GatewayTransactionID: "b", URI: "/v3.0/sms", Cnonce: []byte("2:other")})
+
+	var logBuffer bytes.Buffer
+	handler := NewCallbackHandler(testLookup)
+	handler.Digests = digests
+	handler.ErrorLog = log.New(&logBuffer, "", 0)
+
+	examples := []struct {
+		id       string
+		status   int
+		logEntry string
+	}{
+		{"a", http.StatusOK, ""},
+		{"b", http.StatusUnauthorized, "digest mismatch: cnonce mismatch"},
+		{"c", http.StatusUnauthorized, "original request digest for c: request digest not found"},
+	}
+
+	for _, testCase := range examples {
+		t.Run(testCase.id, func(t *testing.T) {
+			logBuffer.Reset()
+			payload := `{"result-data":{"gw":{"gateway-transaction-id":"` + testCase.id + `","status-code":7}}}`
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, newCallbackRequest(payload, signCallback(t, testSecretKey, payload)))
+
+			assert.Equal(t, testCase.status, recorder.Code)
+			assert.Contains(t, logBuffer.String(), testCase.logEntry)
+		})
+	}
+}
+
+func TestCallbackHandlerDedup(t *testing.T) {
+	var (
+		dispositions []store.CallbackDisposition
+		failNext     bool
+	)
+	handler := NewCallbackHandler(testLookup)
+	handler.Dedup = store.NewMemoryCallbackDedupStore()
+	record := func(ctx context.Context, callback *Callback) error {
+		if failNext {
+			failNext = false
+			return errors.New("database is down")
+		}
+		dispositions = append(dispositions, callback.Disposition)
+		return nil
+	}
+	handler.OnSuccess = record
+	handler.OnOther = record
+
+	deliver := func(payload, sign string) int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, newCallbackRequest(payload, sign))
+		return recorder.Code
+	}
+
+	success := `{"result-data":{"gw":{"gateway-transaction-id":"a","status-code":7}}}`
+	successSign := signCallback(t, testSecretKey, success)
+	onSite := `{"result-data":{"gw":{"gateway-transaction-id":"a","status-code":14}}}`
+
+	// failed processing is repeated on redelivery
+	failNext = true
+	assert.Equal(t, http.StatusInternalServerError, deliver(success, successSign))
+	assert.Equal(t, http.StatusOK, deliver(success, successSign))
+
+	// duplicates are acknowledged, but not dispatched
+	assert.Equal(t, http.StatusOK, deliver(success, successSign))
+	assert.Equal(t, http.StatusOK, deliver(success, signCallback(t, testSecretKey, success)))
+
+	assert.Equal(t, http.StatusOK, deliver(onSite, signCallback(t, testSecretKey, onSite)))
+
+	assert.Equal(t, []store.CallbackDisposition{store.CallbackNew, store.CallbackOutOfOrder}, dispositions)
+}
@@ -0,0 +1,76 @@
+package tprogateway
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+const (
+	testObjectGUID = "3383e58e-9cde-4ffa-85cf-81cd25b2423e"
+	testSecretKey  = "SecKey"
+)
+
+// testGatewayHandler returns HTTP status code and body for a request to a test server
+type testGatewayHandler func(r *http.Request, body []byte) (int, string)
+
+// newTestGateway starts a server that answers like the Gateway, signing successful responses
+// with the same algorithm and QOP as the request
+func newTestGateway(t *testing.T, handler testGatewayHandler) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestBody, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		status, responseBody := handler(r, requestBody)
+
+		if signature := signTestResponse(r.Header.Get("Authorization"), []byte(responseBody)); signature != "" {
+			w.Header().Set("Authorization", signature)
+		}
+
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(responseBody))
+	}))
+}
+
+// signTestResponse signs response body for given request Authorization header
+// with the same algorithm and QOP as the request, returns empty string if the request isn't signed
+func signTestResponse(requestAuthorization string, body []byte) string {
+	credentials, err := structures.ParseDigestHeader(requestAuthorization, structures.DigestParseLenient)
+	if err != nil {
+		return ""
+	}
+
+	uri, _ := credentials.Get("uri")
+	rawCnonce, _ := credentials.Get("cnonce")
+	rawAlgorithm, _ := credentials.Get("algorithm")
+	rawQOP, _ := credentials.Get("qop")
+
+	cnonce, _ := base64.StdEncoding.DecodeString(rawCnonce)
+	algorithm, _ := structures.NewAlgorithm(rawAlgorithm)
+	qop, _ := structures.NewQOP(rawQOP)
+
+	responseDigest, err := structures.NewResponseDigestForRequest(testObjectGUID, uri, cnonce, body,
+		structures.WithAlgorithm(algorithm), structures.WithQOP(qop))
+	if err != nil {
+		return ""
+	}
+
+	signature, _ := responseDigest.CreateHeader(testSecretKey)
+	return signature
+}
+
+// newTestClient creates client for given test server
+func newTestClient(t *testing.T, server *httptest.Server, opts ...Option) *GatewayClient {
+	gateCli, err := NewGatewayClient(testObjectGUID, testSecretKey, append([]Option{WithBaseURL(server.URL)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return gateCli
+}
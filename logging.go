@@ -0,0 +1,109 @@
+package tprogateway
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/redact"
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+type (
+	// Logger receives a structured record for every Gateway exchange
+	Logger interface {
+		LogExchange(entry *LogEntry)
+	}
+
+	// LoggerFunc is an adapter to use ordinary functions as Logger
+	LoggerFunc func(entry *LogEntry)
+
+	// LogEntry describes one Gateway exchange. Cardholder data, tokens and
+	// Authorization digests are masked in headers and payloads.
+	LogEntry struct {
+		OperationType structures.OperationType
+		Method        string
+		URL           string
+		Latency       time.Duration
+		// HTTPStatus is zero if no response was received
+		HTTPStatus int
+		// Status is the Gateway transaction status, if the response contains it
+		Status structures.Status
+		// ErrorCode is the Gateway error code, if the response contains it
+		ErrorCode       structures.ErrorCode
+		RequestHeaders  http.Header
+		RequestPayload  []byte
+		ResponsePayload []byte
+		Err             error
+	}
+
+	// responseSummary is a minimal view of a Gateway response, common for most operations
+	responseSummary struct {
+		Gateway struct {
+			StatusCode structures.Status `json:"status-code"`
+		} `json:"gw"`
+		Error *structures.Error `json:"error"`
+	}
+)
+
+// LogExchange calls f(entry)
+func (f LoggerFunc) LogExchange(entry *LogEntry) {
+	f(entry)
+}
+
+// LoggingMiddleware returns middleware that passes every exchange to the logger with sensitive data masked
+func LoggingMiddleware(logger Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(exchange *Exchange) (*structures.GatewayResponse, error) {
+			started := time.Now()
+			response, err := next(exchange)
+
+			entry := &LogEntry{
+				OperationType:  exchange.OperationType,
+				Method:         exchange.Request.Method,
+				URL:            exchange.Request.URL.String(),
+				Latency:        time.Since(started),
+				RequestHeaders: redact.Header(exchange.Request.Header),
+				RequestPayload: redact.JSON(exchange.Payload),
+				Err:            err,
+			}
+
+			if response != nil && response.Response != nil {
+				entry.HTTPStatus = response.StatusCode
+				entry.ResponsePayload = redact.JSON(response.Payload)
+				entry.Status, entry.ErrorCode = summarizeResponse(response)
+			}
+
+			logger.LogExchange(entry)
+			return response, err
+		}
+	}
+}
+
+// WithLogger adds logging middleware for the logger to the client's chain
+func WithLogger(logger Logger) Option {
+	return func(gc *GatewayClient) error {
+		if logger == nil {
+			return errors.New("logger can't be nil")
+		}
+
+		gc.Use(LoggingMiddleware(logger))
+		return nil
+	}
+}
+
+// summarizeResponse extracts transaction status and error code from a response, if it has them
+func summarizeResponse(response *structures.GatewayResponse) (status structures.Status, errorCode structures.ErrorCode) {
+	var summary responseSummary
+	if json.Unmarshal(response.Payload, &summary) != nil {
+		return
+	}
+
+	status = summary.Gateway.StatusCode
+	if summary.Error != nil {
+		errorCode = summary.Error.Code
+	}
+
+	return
+}
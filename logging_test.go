@@ -0,0 +1,70 @@
+package tprogateway
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggingMiddleware(t *testing.T) {
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		return http.StatusOK, "{\"gw\":{\"gateway-transaction-id\":\"gw-1\",\"status-code\":19},\"error\":{\"code\":1102,\"message\":\"Invalid pan\"}," +
+			"\"payment-method-data-token\":\"tok-123\"}"
+	})
+	defer server.Close()
+
+	var entries []*LogEntry
+	gateCli := newTestClient(t, server, WithLogger(LoggerFunc(func(entry *LogEntry) {
+		entries = append(entries, entry)
+	})))
+
+	order := gateCli.OperationBuilder().NewSms()
+	order.PaymentMethod.Pan = "4111111111111111"
+	order.PaymentMethod.Cvv = "123"
+	order.PaymentMethod.ExpMmYy = "09/31"
+	order.PaymentMethod.CardholderName = "John Doe"
+	order.CommandData.PaymentMethodDataToken = "tok-123"
+
+	_, err := gateCli.NewRequest(order)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	entry := entries[0]
+	assert.Equal(t, structures.SMS, entry.OperationType)
+	assert.Equal(t, http.MethodPost, entry.Method)
+	assert.Equal(t, server.URL+"/v3.0/sms", entry.URL)
+	assert.Equal(t, http.StatusOK, entry.HTTPStatus)
+	assert.Equal(t, structures.StatusBusinessRulesValidationFailed, entry.Status)
+	assert.Equal(t, structures.EecCardBadNumber, entry.ErrorCode)
+	assert.True(t, entry.Latency > 0)
+	assert.NoError(t, entry.Err)
+
+	assert.Contains(t, entry.RequestHeaders.Get("Authorization"), "response=\"***\"")
+
+	for _, secret := range []string{"4111111111111111", "\"123\"", "09/31", "John Doe", "tok-123"} {
+		assert.NotContains(t, string(entry.RequestPayload), secret)
+		assert.NotContains(t, string(entry.ResponsePayload), secret)
+	}
+	assert.Contains(t, string(entry.RequestPayload), "411111***1111")
+	assert.Contains(t, string(entry.ResponsePayload), "gw-1")
+}
+
+func TestLoggingMiddlewareTransportError(t *testing.T) {
+	var entry *LogEntry
+	gateCli, _ := NewGatewayClient(testObjectGUID, testSecretKey,
+		WithBaseURL("http://127.0.0.1:1"),
+		WithLogger(LoggerFunc(func(e *LogEntry) { entry = e })))
+
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.Error(t, err)
+	assert.Equal(t, err, entry.Err)
+	assert.Equal(t, 0, entry.HTTPStatus)
+	assert.Nil(t, entry.ResponsePayload)
+}
+
+func TestWithLoggerValidation(t *testing.T) {
+	_, err := NewGatewayClient(testObjectGUID, testSecretKey, WithLogger(nil))
+	assert.EqualError(t, err, "logger can't be nil")
+}
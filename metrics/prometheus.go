@@ -0,0 +1,234 @@
+// Package metrics provides Observer implementations for Transact Pro Gateway client metrics
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	tprogateway "github.com/TransactPRO/gw3-go-client"
+)
+
+// DefaultBuckets are histogram upper bounds (in seconds) suitable for Gateway request latencies
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+type (
+	// PrometheusCollector collects client metrics in memory and exposes them
+	// in Prometheus text exposition format. It implements tprogateway.Observer
+	// (spans are ignored) and http.Handler to be used as a scrape endpoint.
+	PrometheusCollector struct {
+		tprogateway.NopObserver
+
+		mu         sync.Mutex
+		buckets    []float64
+		help       map[string]string
+		counters   map[string]map[string]*counter
+		histograms map[string]map[string]*histogram
+	}
+
+	counter struct {
+		labels string
+		value  float64
+	}
+
+	histogram struct {
+		labels string
+		counts []uint64
+		sum    float64
+		count  uint64
+	}
+)
+
+// NewPrometheusCollector creates a collector with given histogram buckets, DefaultBuckets are used if none given
+func NewPrometheusCollector(buckets ...float64) *PrometheusCollector {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	return &PrometheusCollector{
+		buckets: sorted,
+		help: map[string]string{
+			tprogateway.MetricRequestsTotal:   "Total number of Transact Pro Gateway requests.",
+			tprogateway.MetricRequestDuration: "Transact Pro Gateway request duration in seconds.",
+			tprogateway.MetricQueueDuration:   "Time Transact Pro Gateway requests waited for client-side limits in seconds.",
+		},
+		counters:   make(map[string]map[string]*counter),
+		histograms: make(map[string]map[string]*histogram),
+	}
+}
+
+// Describe sets help text for a metric
+func (c *PrometheusCollector) Describe(name, help string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.help[name] = help
+}
+
+// IncCounter increments the named counter
+func (c *PrometheusCollector) IncCounter(name string, tags tprogateway.Tags) {
+	labels := formatLabels(tags)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	series, ok := c.counters[name]
+	if !ok {
+		series = make(map[string]*counter)
+		c.counters[name] = series
+	}
+
+	item, ok := series[labels]
+	if !ok {
+		item = &counter{labels: labels}
+		series[labels] = item
+	}
+	item.value++
+}
+
+// ObserveHistogram records a value for the named histogram
+func (c *PrometheusCollector) ObserveHistogram(name string, value float64, tags tprogateway.Tags) {
+	labels := formatLabels(tags)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	series, ok := c.histograms[name]
+	if !ok {
+		series = make(map[string]*histogram)
+		c.histograms[name] = series
+	}
+
+	item, ok := series[labels]
+	if !ok {
+		item = &histogram{labels: labels, counts: make([]uint64, len(c.buckets))}
+		series[labels] = item
+	}
+
+	for i, bound := range c.buckets {
+		if value <= bound {
+			item.counts[i]++
+		}
+	}
+	item.sum += value
+	item.count++
+}
+
+// ServeHTTP writes collected metrics in Prometheus text exposition format
+func (c *PrometheusCollector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = c.Export(w)
+}
+
+// Export writes collected metrics in Prometheus text exposition format
+func (c *PrometheusCollector) Export(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var out strings.Builder
+
+	counterNames := make([]string, 0, len(c.counters))
+	for name := range c.counters {
+		counterNames = append(counterNames, name)
+	}
+	sort.Strings(counterNames)
+
+	for _, name := range counterNames {
+		c.writeHeader(&out, name, "counter")
+
+		series := make([]*counter, 0, len(c.counters[name]))
+		for _, item := range c.counters[name] {
+			series = append(series, item)
+		}
+		sort.Slice(series, func(i, j int) bool { return series[i].labels < series[j].labels })
+
+		for _, item := range series {
+			fmt.Fprintf(&out, "%s%s %s\n", name, wrapLabels(item.labels), formatValue(item.value))
+		}
+	}
+
+	histogramNames := make([]string, 0, len(c.histograms))
+	for name := range c.histograms {
+		histogramNames = append(histogramNames, name)
+	}
+	sort.Strings(histogramNames)
+
+	for _, name := range histogramNames {
+		c.writeHeader(&out, name, "histogram")
+
+		series := make([]*histogram, 0, len(c.histograms[name]))
+		for _, item := range c.histograms[name] {
+			series = append(series, item)
+		}
+		sort.Slice(series, func(i, j int) bool { return series[i].labels < series[j].labels })
+
+		for _, item := range series {
+			labels := item.labels
+			for i, bound := range c.buckets {
+				fmt.Fprintf(&out, "%s_bucket%s %d\n", name, wrapLabels(joinLabels(labels, "le=\""+formatValue(bound)+"\"")), item.counts[i])
+			}
+			fmt.Fprintf(&out, "%s_bucket%s %d\n", name, wrapLabels(joinLabels(labels, "le=\"+Inf\"")), item.count)
+			fmt.Fprintf(&out, "%s_sum%s %s\n", name, wrapLabels(labels), formatValue(item.sum))
+			fmt.Fprintf(&out, "%s_count%s %d\n", name, wrapLabels(labels), item.count)
+		}
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func (c *PrometheusCollector) writeHeader(out *strings.Builder, name, metricType string) {
+	if help, ok := c.help[name]; ok {
+		fmt.Fprintf(out, "# HELP %s %s\n", name, strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(help))
+	}
+	fmt.Fprintf(out, "# TYPE %s %s\n", name, metricType)
+}
+
+// formatLabels converts tags to a sorted, escaped label list without braces
+func formatLabels(tags tprogateway.Tags) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	escaper := strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("%s=\"%s\"", key, escaper.Replace(tags[key]))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func joinLabels(labels, extra string) string {
+	if labels == "" {
+		return extra
+	}
+
+	return labels + "," + extra
+}
+
+func wrapLabels(labels string) string {
+	if labels == "" {
+		return ""
+	}
+
+	return "{" + labels + "}"
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tprogateway "github.com/TransactPRO/gw3-go-client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusCollectorExposition(t *testing.T) {
+	collector := NewPrometheusCollector(0.1, 1)
+
+	collector.IncCounter(tprogateway.MetricRequestsTotal, tprogateway.Tags{"operation": "sms", "http_status": "200"})
+	collector.IncCounter(tprogateway.MetricRequestsTotal, tprogateway.Tags{"operation": "sms", "http_status": "200"})
+	collector.IncCounter(tprogateway.MetricRequestsTotal, tprogateway.Tags{"operation": "status", "http_status": "500"})
+	collector.ObserveHistogram(tprogateway.MetricRequestDuration, 0.05, tprogateway.Tags{"operation": "sms"})
+	collector.ObserveHistogram(tprogateway.MetricRequestDuration, 0.5, tprogateway.Tags{"operation": "sms"})
+	collector.ObserveHistogram(tprogateway.MetricRequestDuration, 3, tprogateway.Tags{"operation": "sms"})
+
+	expected := strings.Join([]string{
+		"# HELP gateway_requests_total Total number of Transact Pro Gateway requests.",
+		"# TYPE gateway_requests_total counter",
+		"gateway_requests_total{http_status=\"200\",operation=\"sms\"} 2",
+		"gateway_requests_total{http_status=\"500\",operation=\"status\"} 1",
+		"# HELP gateway_request_duration_seconds Transact Pro Gateway request duration in seconds.",
+		"# TYPE gateway_request_duration_seconds histogram",
+		"gateway_request_duration_seconds_bucket{operation=\"sms\",le=\"0.1\"} 1",
+		"gateway_request_duration_seconds_bucket{operation=\"sms\",le=\"1\"} 2",
+		"gateway_request_duration_seconds_bucket{operation=\"sms\",le=\"+Inf\"} 3",
+		"gateway_request_duration_seconds_sum{operation=\"sms\"} 3.55",
+		"gateway_request_duration_seconds_count{operation=\"sms\"} 3",
+		"",
+	}, "\n")
+
+	var out strings.Builder
+	assert.NoError(t, collector.Export(&out))
+	assert.Equal(t, expected, out.String())
+}
+
+func TestPrometheusCollectorEscaping(t *testing.T) {
+	collector := NewPrometheusCollector()
+	collector.Describe("custom_total", "Line one\nline two")
+	collector.IncCounter("custom_total", tprogateway.Tags{"value": "a\"b\\c\nd"})
+	collector.IncCounter("no_labels_total", nil)
+
+	var out strings.Builder
+	assert.NoError(t, collector.Export(&out))
+	assert.Contains(t, out.String(), "# HELP custom_total Line one\\nline two\n")
+	assert.Contains(t, out.String(), "custom_total{value=\"a\\\"b\\\\c\\nd\"} 1\n")
+	assert.Contains(t, out.String(), "# TYPE no_labels_total counter\nno_labels_total 1\n")
+}
+
+func TestPrometheusCollectorServeHTTP(t *testing.T) {
+	collector := NewPrometheusCollector()
+	collector.IncCounter(tprogateway.MetricRequestsTotal, tprogateway.Tags{"operation": "sms"})
+
+	recorder := httptest.NewRecorder()
+	collector.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), "gateway_requests_total{operation=\"sms\"} 1")
+}
+
+func TestPrometheusCollectorIsObserver(t *testing.T) {
+	_, err := tprogateway.NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey",
+		tprogateway.WithObserver(NewPrometheusCollector()))
+	assert.NoError(t, err)
+}
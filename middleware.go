@@ -0,0 +1,51 @@
+package tprogateway
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+type (
+	// Exchange describes a single signed request to Transact Pro API.
+	// Request's context is the one passed to NewRequestWithContext.
+	Exchange struct {
+		// OperationType of the request
+		OperationType structures.OperationType
+		// Payload is the marshalled request body, empty for GET requests
+		Payload []byte
+		// Request is a signed HTTP request, ready to be sent
+		Request *http.Request
+		// Digest is the request's Authorization header data
+		Digest *structures.RequestDigest
+	}
+
+	// Handler sends an exchange and returns the Gateway response with verified digest.
+	// Like NewRequest, it may return non-nil response with an error if the response failed validation.
+	Handler func(exchange *Exchange) (*structures.GatewayResponse, error)
+
+	// Middleware wraps a Handler to add behavior before and after the request is sent
+	Middleware func(next Handler) Handler
+)
+
+// Use appends middlewares to the client's chain. Middlewares are called in the order they were added,
+// so the first one sees the request first and the response last. Every retry attempt passes the chain again.
+// Use is not safe to call concurrently with requests.
+func (gc *GatewayClient) Use(middlewares ...Middleware) {
+	gc.middlewares = append(gc.middlewares, middlewares...)
+}
+
+// WithMiddleware appends middlewares to the client's chain, see GatewayClient.Use
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(gc *GatewayClient) error {
+		for _, middleware := range middlewares {
+			if middleware == nil {
+				return errors.New("middleware can't be nil")
+			}
+		}
+
+		gc.Use(middlewares...)
+		return nil
+	}
+}
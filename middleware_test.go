@@ -0,0 +1,91 @@
+package tprogateway
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareChainOrder(t *testing.T) {
+	var calls []string
+	tracer := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(exchange *Exchange) (*structures.GatewayResponse, error) {
+				calls = append(calls, name+" before")
+				response, err := next(exchange)
+				calls = append(calls, name+" after")
+				return response, err
+			}
+		}
+	}
+
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		calls = append(calls, "gateway")
+		return http.StatusOK, "{}"
+	})
+	defer server.Close()
+
+	gateCli := newTestClient(t, server, WithMiddleware(tracer("first")))
+	gateCli.Use(tracer("second"))
+
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first before", "second before", "gateway", "second after", "first after"}, calls)
+}
+
+func TestMiddlewareSeesExchange(t *testing.T) {
+	var receivedHeader string
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		receivedHeader = r.Header.Get("X-Request-ID")
+		return http.StatusOK, "{\"gw\":{\"status-code\":7}}"
+	})
+	defer server.Close()
+
+	var seen *Exchange
+	var seenResponse *structures.GatewayResponse
+	gateCli := newTestClient(t, server)
+	gateCli.Use(func(next Handler) Handler {
+		return func(exchange *Exchange) (*structures.GatewayResponse, error) {
+			seen = exchange
+			exchange.Request.Header.Set("X-Request-ID", "req-1")
+
+			response, err := next(exchange)
+			seenResponse = response
+			return response, err
+		}
+	})
+
+	order := gateCli.OperationBuilder().NewSms()
+	order.Money.Amount = 100
+	_, err := gateCli.NewRequest(order)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "req-1", receivedHeader)
+	assert.Equal(t, structures.SMS, seen.OperationType)
+	assert.Contains(t, string(seen.Payload), "\"amount\":100")
+	assert.NotEmpty(t, seen.Request.Header.Get("Authorization"))
+	assert.Equal(t, "/v3.0/sms", seen.Digest.URI)
+	assert.NotNil(t, seenResponse.Digest)
+	assert.Equal(t, seen.Digest.Cnonce, seenResponse.Digest.Cnonce)
+}
+
+func TestMiddlewareMayShortCircuit(t *testing.T) {
+	gateCli, _ := NewGatewayClient(testObjectGUID, testSecretKey, WithBaseURL("http://127.0.0.1:1"))
+	expectedErr := errors.New("blocked")
+	gateCli.Use(func(next Handler) Handler {
+		return func(exchange *Exchange) (*structures.GatewayResponse, error) {
+			return nil, expectedErr
+		}
+	})
+
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.Equal(t, expectedErr, err)
+}
+
+func TestWithMiddlewareValidation(t *testing.T) {
+	_, err := NewGatewayClient(testObjectGUID, testSecretKey, WithMiddleware(nil))
+	assert.EqualError(t, err, "middleware can't be nil")
+}
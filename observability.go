@@ -0,0 +1,145 @@
+package tprogateway
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// Metric names reported to Observer
+const (
+	MetricRequestsTotal   = "gateway_requests_total"
+	MetricRequestDuration = "gateway_request_duration_seconds"
+)
+
+// Tag names attached to spans and metrics
+const (
+	TagOperation     = "operation"
+	TagHTTPStatus    = "http_status"
+	TagStatusCode    = "status_code"
+	TagErrorCategory = "error_category"
+	TagDigest        = "digest"
+)
+
+// Digest verification outcomes reported with TagDigest
+const (
+	DigestVerified = "verified"
+	DigestFailed   = "failed"
+	DigestSkipped  = "skipped"
+)
+
+type (
+	// Tags are key-value labels attached to spans and metrics
+	Tags map[string]string
+
+	// Observer receives tracing spans and metrics for every NewRequest call.
+	// One span and one set of metrics is reported per call, regardless of retries.
+	Observer interface {
+		// StartSpan is called before the request is sent, returned context is used for the request
+		StartSpan(ctx context.Context, opType structures.OperationType) (context.Context, Span)
+		// IncCounter increments the named counter
+		IncCounter(name string, tags Tags)
+		// ObserveHistogram records a value for the named histogram
+		ObserveHistogram(name string, value float64, tags Tags)
+	}
+
+	// Span is a started tracing span
+	Span interface {
+		// Finish is called with the call's outcome tags and error once the call is completed
+		Finish(tags Tags, err error)
+	}
+
+	// NopObserver is an Observer that does nothing.
+	// It may be embedded to implement only a part of the Observer interface.
+	NopObserver struct{}
+
+	nopSpan struct{}
+)
+
+// StartSpan returns unchanged context and a span that does nothing
+func (NopObserver) StartSpan(ctx context.Context, _ structures.OperationType) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+// IncCounter does nothing
+func (NopObserver) IncCounter(string, Tags) {}
+
+// ObserveHistogram does nothing
+func (NopObserver) ObserveHistogram(string, float64, Tags) {}
+
+func (nopSpan) Finish(Tags, error) {}
+
+// WithObserver sets observer for tracing and metrics of every request
+func WithObserver(observer Observer) Option {
+	return func(gc *GatewayClient) error {
+		if observer == nil {
+			return errors.New("observer can't be nil")
+		}
+
+		gc.observer = observer
+		return nil
+	}
+}
+
+// observe executes the request, reporting a span and metrics to the client's observer
+func (gc *GatewayClient) observe(ctx context.Context, opData structures.OperationRequestInterface) (*structures.GatewayResponse, error) {
+	opType := opData.GetOperationType()
+
+	spanCtx, span := gc.observer.StartSpan(ctx, opType)
+	if spanCtx == nil {
+		spanCtx = ctx
+	}
+
+	started := time.Now()
+	response, err := gc.execute(spanCtx, opData)
+	duration := time.Since(started)
+
+	tags := outcomeTags(opType, response, err)
+	span.Finish(tags, err)
+	gc.observer.IncCounter(MetricRequestsTotal, tags)
+	gc.observer.ObserveHistogram(MetricRequestDuration, duration.Seconds(), Tags{TagOperation: string(opType)})
+
+	return response, err
+}
+
+// outcomeTags describes a request's outcome
+func outcomeTags(opType structures.OperationType, response *structures.GatewayResponse, err error) Tags {
+	tags := Tags{
+		TagOperation:     string(opType),
+		TagHTTPStatus:    "0",
+		TagStatusCode:    "0",
+		TagErrorCategory: "none",
+		TagDigest:        DigestSkipped,
+	}
+
+	var errorCode structures.ErrorCode
+	if response != nil && response.Response != nil {
+		var status structures.Status
+		status, errorCode = summarizeResponse(response)
+
+		tags[TagHTTPStatus] = strconv.Itoa(response.StatusCode)
+		tags[TagStatusCode] = strconv.Itoa(int(status))
+
+		if response.Digest != nil && err == nil {
+			tags[TagDigest] = DigestVerified
+		}
+	}
+
+	var gwErr *structures.GatewayError
+	switch {
+	case errors.As(err, &gwErr):
+		tags[TagErrorCategory] = gwErr.Category.String()
+		if gwErr.Category == structures.ErrorCategoryAuth && response != nil {
+			tags[TagDigest] = DigestFailed
+		}
+	case err != nil:
+		tags[TagErrorCategory] = structures.ErrorCategoryUnknown.String()
+	case errorCode != 0:
+		tags[TagErrorCategory] = errorCode.Category().String()
+	}
+
+	return tags
+}
@@ -0,0 +1,138 @@
+package tprogateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSpan struct {
+	tags Tags
+	err  error
+}
+
+func (s *testSpan) Finish(tags Tags, err error) {
+	s.tags = tags
+	s.err = err
+}
+
+type testObserver struct {
+	spans      []*testSpan
+	counters   map[string][]Tags
+	histograms map[string][]float64
+}
+
+type testObserverKey struct{}
+
+func newTestObserver() *testObserver {
+	return &testObserver{counters: map[string][]Tags{}, histograms: map[string][]float64{}}
+}
+
+func (o *testObserver) StartSpan(ctx context.Context, opType structures.OperationType) (context.Context, Span) {
+	span := &testSpan{}
+	o.spans = append(o.spans, span)
+	return context.WithValue(ctx, testObserverKey{}, opType), span
+}
+
+func (o *testObserver) IncCounter(name string, tags Tags) {
+	o.counters[name] = append(o.counters[name], tags)
+}
+
+func (o *testObserver) ObserveHistogram(name string, value float64, tags Tags) {
+	o.histograms[name] = append(o.histograms[name], value)
+}
+
+func TestObserverSuccessfulRequest(t *testing.T) {
+	var spanContextValue interface{}
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		return http.StatusOK, "{\"gw\":{\"status-code\":7},\"error\":{}}"
+	})
+	defer server.Close()
+
+	observer := newTestObserver()
+	gateCli := newTestClient(t, server, WithObserver(observer))
+	gateCli.Use(func(next Handler) Handler {
+		return func(exchange *Exchange) (*structures.GatewayResponse, error) {
+			spanContextValue = exchange.Request.Context().Value(testObserverKey{})
+			return next(exchange)
+		}
+	})
+
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.NoError(t, err)
+
+	expectedTags := Tags{
+		TagOperation:     "sms",
+		TagHTTPStatus:    "200",
+		TagStatusCode:    "7",
+		TagErrorCategory: "none",
+		TagDigest:        DigestVerified,
+	}
+
+	assert.Len(t, observer.spans, 1)
+	assert.Equal(t, expectedTags, observer.spans[0].tags)
+	assert.Equal(t, []Tags{expectedTags}, observer.counters[MetricRequestsTotal])
+	assert.Len(t, observer.histograms[MetricRequestDuration], 1)
+	assert.Equal(t, structures.SMS, spanContextValue)
+}
+
+func TestObserverDecline(t *testing.T) {
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		return http.StatusPaymentRequired, "{\"gw\":{\"status-code\":8},\"error\":{\"code\":1303}}"
+	})
+	defer server.Close()
+
+	observer := newTestObserver()
+	gateCli := newTestClient(t, server, WithObserver(observer))
+
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.NoError(t, err)
+
+	tags := observer.counters[MetricRequestsTotal][0]
+	assert.Equal(t, "402", tags[TagHTTPStatus])
+	assert.Equal(t, "soft decline", tags[TagErrorCategory])
+	assert.Equal(t, DigestVerified, tags[TagDigest])
+}
+
+func TestObserverDigestFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	observer := newTestObserver()
+	gateCli := newTestClient(t, server, WithObserver(observer))
+
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.Error(t, err)
+
+	assert.Equal(t, err, observer.spans[0].err)
+	tags := observer.spans[0].tags
+	assert.Equal(t, "auth", tags[TagErrorCategory])
+	assert.Equal(t, DigestFailed, tags[TagDigest])
+}
+
+func TestObserverTransportError(t *testing.T) {
+	observer := newTestObserver()
+	gateCli, _ := NewGatewayClient(testObjectGUID, testSecretKey, WithBaseURL("http://127.0.0.1:1"), WithObserver(observer))
+
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.Error(t, err)
+
+	tags := observer.spans[0].tags
+	assert.Equal(t, "0", tags[TagHTTPStatus])
+	assert.Equal(t, "transport", tags[TagErrorCategory])
+	assert.Equal(t, DigestSkipped, tags[TagDigest])
+}
+
+func TestWithObserverValidation(t *testing.T) {
+	_, err := NewGatewayClient(testObjectGUID, testSecretKey, WithObserver(nil))
+	assert.EqualError(t, err, "observer can't be nil")
+
+	_, err = NewGatewayClient(testObjectGUID, testSecretKey, WithObserver(NopObserver{}))
+	assert.NoError(t, err)
+}
@@ -48,6 +48,11 @@ func (op *B2PAssembly) GetOperationType() structures.OperationType {
 	return op.opHTTPData.GetOperationType()
 }
 
+// GetMerchantTransactionID returns merchant-side transaction ID that may be used to explore the operation's outcome
+func (op *B2PAssembly) GetMerchantTransactionID() string {
+	return op.GeneralData.OrderData.MerchantTransactionID
+}
+
 // ParseResponse parses Gateway response into corresponding data structure
 func (op *B2PAssembly) ParseResponse(response *structures.GatewayResponse) (result *structures.TransactionResponse, err error) {
 	result = new(structures.TransactionResponse)
@@ -48,6 +48,11 @@ func (op *HoldDMSAssembly) GetOperationType() structures.OperationType {
 	return op.opHTTPData.GetOperationType()
 }
 
+// GetMerchantTransactionID returns merchant-side transaction ID that may be used to explore the operation's outcome
+func (op *HoldDMSAssembly) GetMerchantTransactionID() string {
+	return op.GeneralData.OrderData.MerchantTransactionID
+}
+
 // ParseResponse parses Gateway response into corresponding data structure
 func (op *HoldDMSAssembly) ParseResponse(response *structures.GatewayResponse) (result *structures.TransactionResponse, err error) {
 	result = new(structures.TransactionResponse)
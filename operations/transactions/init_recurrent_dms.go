@@ -48,6 +48,11 @@ func (op *InitRecurrentDMSAssembly) GetOperationType() structures.OperationType
 	return op.opHTTPData.GetOperationType()
 }
 
+// GetMerchantTransactionID returns merchant-side transaction ID that may be used to explore the operation's outcome
+func (op *InitRecurrentDMSAssembly) GetMerchantTransactionID() string {
+	return op.GeneralData.OrderData.MerchantTransactionID
+}
+
 // ParseResponse parses Gateway response into corresponding data structure
 func (op *InitRecurrentDMSAssembly) ParseResponse(response *structures.GatewayResponse) (result *structures.TransactionResponse, err error) {
 	result = new(structures.TransactionResponse)
@@ -90,3 +90,9 @@ func TestParseSMSResponseError(t *testing.T) {
 	}
 	assert.Equal(t, expectedWarnings, parsedResponse.Warnings)
 }
+
+func TestSMSMerchantTransactionID(t *testing.T) {
+	order := NewSMSAssembly()
+	order.GeneralData.OrderData.MerchantTransactionID = "order-1"
+	assert.Equal(t, "order-1", order.GetMerchantTransactionID())
+}
@@ -0,0 +1,270 @@
+package tprogateway
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// Option configures GatewayClient on creation.
+// Options are applied in the order they were passed, the first failed option aborts client creation.
+type Option func(gc *GatewayClient) error
+
+// Environment is a predefined Transact Pro API endpoint
+type Environment string
+
+// Known environments
+const (
+	EnvironmentSandbox    Environment = "sandbox"
+	EnvironmentProduction Environment = "production"
+)
+
+var environment2url = map[Environment]string{
+	EnvironmentSandbox:    dAPIBaseURI,
+	EnvironmentProduction: dAPIProductionBaseURI,
+}
+
+// WithEnvironment selects one of predefined API endpoints.
+// Can't be combined with WithBaseURL.
+func WithEnvironment(env Environment) Option {
+	return func(gc *GatewayClient) error {
+		baseURL, ok := environment2url[env]
+		if !ok {
+			return fmt.Errorf("unknown environment %q", env)
+		}
+
+		if gc.customBaseURL {
+			return errors.New("environment can't be combined with custom base URL")
+		}
+
+		gc.environment = env
+		gc.API.BaseURI = baseURL
+		return nil
+	}
+}
+
+// WithBaseURL sets custom API base URL (scheme and host, like https://some.host), a trailing slash is trimmed.
+// Can't be combined with WithEnvironment.
+func WithBaseURL(baseURL string) Option {
+	return func(gc *GatewayClient) error {
+		parsed, err := url.Parse(baseURL)
+		if err != nil {
+			return fmt.Errorf("incorrect base URL: %s", err)
+		}
+
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			return fmt.Errorf("incorrect base URL: unsupported scheme %q", parsed.Scheme)
+		}
+
+		if parsed.Host == "" {
+			return errors.New("incorrect base URL: host is empty")
+		}
+
+		if parsed.Path != "" && parsed.Path != "/" {
+			return fmt.Errorf("incorrect base URL: unexpected path %q", parsed.Path)
+		}
+
+		if gc.environment != "" {
+			return errors.New("custom base URL can't be combined with environment")
+		}
+
+		gc.customBaseURL = true
+		gc.API.BaseURI = strings.TrimSuffix(baseURL, "/")
+		return nil
+	}
+}
+
+// WithAPIVersion sets API version used as a route path prefix (like 3.0)
+func WithAPIVersion(version string) Option {
+	return func(gc *GatewayClient) error {
+		if version == "" {
+			return errors.New("API version can't be empty")
+		}
+
+		gc.API.Version = version
+		return nil
+	}
+}
+
+// WithHTTPClient sets HTTP client used to send requests.
+// Client's settings are copied, so WithTimeout and WithTransport will not affect given instance.
+// They override the client's timeout and transport regardless of the order of options.
+func WithHTTPClient(client *http.Client) Option {
+	return func(gc *GatewayClient) error {
+		if client == nil {
+			return errors.New("HTTP client can't be nil")
+		}
+
+		// keep the timeout and transport set by the options passed before
+		timeout, transport := gc.HTTPClient.Timeout, gc.HTTPClient.Transport
+		gc.HTTPClient = *client
+		if timeout != 0 {
+			gc.HTTPClient.Timeout = timeout
+		}
+		if transport != nil {
+			gc.HTTPClient.Transport = transport
+		}
+
+		return nil
+	}
+}
+
+// WithTimeout sets overall time limit for a single HTTP request
+func WithTimeout(timeout time.Duration) Option {
+	return func(gc *GatewayClient) error {
+		if timeout <= 0 {
+			return fmt.Errorf("timeout must be positive, got %s", timeout)
+		}
+
+		gc.HTTPClient.Timeout = timeout
+		return nil
+	}
+}
+
+// WithTransport sets HTTP transport used to send requests
+func WithTransport(transport http.RoundTripper) Option {
+	return func(gc *GatewayClient) error {
+		if transport == nil {
+			return errors.New("transport can't be nil")
+		}
+
+		gc.HTTPClient.Transport = transport
+		return nil
+	}
+}
+
+// WithUserAgent sets User-Agent header value for every request
+func WithUserAgent(userAgent string) Option {
+	return func(gc *GatewayClient) error {
+		if userAgent == "" {
+			return errors.New("user agent can't be empty")
+		}
+
+		gc.userAgent = userAgent
+		return nil
+	}
+}
+
+// WithReplayGuard sets the guard used to reject stale and replayed responses.
+// By default, responses older than structures.DefaultMaxClockSkew and repeated snonces are rejected.
+// Passing nil disables the check.
+func WithReplayGuard(guard *structures.ReplayGuard) Option {
+	return func(gc *GatewayClient) error {
+		if guard != nil && guard.MaxClockSkew <= 0 {
+			return fmt.Errorf("replay guard: clock skew window must be positive, got %s", guard.MaxClockSkew)
+		}
+
+		gc.replayGuard = guard
+		return nil
+	}
+}
+
+// WithDigestAlgorithm sets the algorithm used to sign requests, structures.AlgorithmSHA256 is used by default
+func WithDigestAlgorithm(algorithm structures.Algorithm) Option {
+	return func(gc *GatewayClient) error {
+		if _, err := algorithm.Hash(); err != nil {
+			return fmt.Errorf("digest algorithm: %s", err)
+		}
+
+		gc.digestAlgorithm = algorithm
+		return nil
+	}
+}
+
+// WithDigestQOP sets the QOP used to sign requests with given HTTP methods or all requests if no methods given.
+// Method-specific values take precedence over the value for all methods, structures.QopAuthInt is used by default.
+// structures.QopAuth doesn't cover request body, so it is meant for requests without body, like
+// WithDigestQOP(structures.QopAuth, http.MethodGet).
+func WithDigestQOP(qop structures.QOP, methods ...string) Option {
+	return func(gc *GatewayClient) error {
+		if qop != structures.QopAuth && qop != structures.QopAuthInt {
+			return fmt.Errorf("digest QOP: unsupported value %s", qop)
+		}
+
+		if gc.digestQOP == nil {
+			gc.digestQOP = make(map[string]structures.QOP)
+		}
+
+		if len(methods) == 0 {
+			gc.digestQOP[""] = qop
+			return nil
+		}
+
+		for _, method := range methods {
+			if method == "" {
+				return errors.New("digest QOP: HTTP method can't be empty")
+			}
+
+			gc.digestQOP[strings.ToUpper(method)] = qop
+		}
+
+		return nil
+	}
+}
+
+// WithAcceptedDigestAlgorithms sets the allow-list of algorithms accepted in response digests.
+// By default, any of structures.SupportedAlgorithms() is accepted.
+func WithAcceptedDigestAlgorithms(algorithms ...structures.Algorithm) Option {
+	return func(gc *GatewayClient) error {
+		if len(algorithms) == 0 {
+			return errors.New("accepted digest algorithms can't be empty")
+		}
+
+		for _, algorithm := range algorithms {
+			if _, err := algorithm.Hash(); err != nil {
+				return fmt.Errorf("accepted digest algorithms: %s", err)
+			}
+		}
+
+		gc.acceptedAlgorithms = append([]structures.Algorithm(nil), algorithms...)
+		return nil
+	}
+}
+
+// WithClock sets the source of current time for digest nonces, time.Now is used by default.
+// Meant for tests, e.g. reproducible requests or digest expiry. Responses are still checked for freshness
+// with the replay guard's clock, set ReplayGuard.Now (see WithReplayGuard) to check them against the same time.
+func WithClock(now func() time.Time) Option {
+	return func(gc *GatewayClient) error {
+		if now == nil {
+			return errors.New("clock can't be nil")
+		}
+
+		gc.clock = now
+		return nil
+	}
+}
+
+// WithRandom sets the source of random bytes for digest nonces, crypto/rand.Reader is used by default.
+// Predictable sources make request digests reproducible, so they must be used only in tests.
+// The reader is guarded by a mutex, so the client stays safe for concurrent use.
+func WithRandom(random io.Reader) Option {
+	return func(gc *GatewayClient) error {
+		if random == nil {
+			return errors.New("random source can't be nil")
+		}
+
+		gc.random = &lockedReader{r: random}
+		return nil
+	}
+}
+
+// lockedReader serializes reads from the underlying reader
+type lockedReader struct {
+	mu sync.Mutex
+	r  io.Reader
+}
+
+func (l *lockedReader) Read(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.r.Read(p)
+}
@@ -0,0 +1,263 @@
+package tprogateway
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionsApplied(t *testing.T) {
+	transport := &http.Transport{}
+	gateCli, err := NewGatewayClient(
+		"3383e58e-9cde-4ffa-85cf-81cd25b2423e",
+		"SecKey",
+		WithBaseURL("https://some.host"),
+		WithAPIVersion("42.1"),
+		WithHTTPClient(&http.Client{Timeout: time.Second}),
+		WithTimeout(5*time.Second),
+		WithTransport(transport),
+		WithUserAgent("my-shop/1.0"),
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://some.host", gateCli.API.BaseURI)
+	assert.Equal(t, "42.1", gateCli.API.Version)
+	assert.Equal(t, 5*time.Second, gateCli.HTTPClient.Timeout)
+	assert.Equal(t, transport, gateCli.HTTPClient.Transport)
+	assert.Equal(t, "my-shop/1.0", gateCli.userAgent)
+}
+
+func TestWithEnvironment(t *testing.T) {
+	sandbox, err := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey", WithEnvironment(EnvironmentSandbox))
+	assert.NoError(t, err)
+	assert.Equal(t, dAPIBaseURI, sandbox.API.BaseURI)
+
+	production, err := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey", WithEnvironment(EnvironmentProduction))
+	assert.NoError(t, err)
+	assert.Equal(t, dAPIProductionBaseURI, production.API.BaseURI)
+
+	forSession, err := NewGatewayClientForSession("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey", "session", WithEnvironment(EnvironmentProduction))
+	assert.NoError(t, err)
+	assert.Equal(t, dAPIProductionBaseURI, forSession.API.BaseURI)
+}
+
+func TestWithBaseURLTrimsTrailingSlash(t *testing.T) {
+	gateCli, err := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey", WithBaseURL("https://some.host/"))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://some.host", gateCli.API.BaseURI)
+}
+
+func TestOptionsValidation(t *testing.T) {
+	examples := []struct {
+		option        Option
+		expectedError string
+	}{
+		{WithEnvironment("staging"), "unknown environment \"staging\""},
+		{WithBaseURL("ftp://some.host"), "incorrect base URL: unsupported scheme \"ftp\""},
+		{WithBaseURL("https://"), "incorrect base URL: host is empty"},
+		{WithBaseURL(":"), "incorrect base URL: parse \":\": missing protocol scheme"},
+		{WithBaseURL("https://some.host/v3.0"), "incorrect base URL: unexpected path \"/v3.0\""},
+		{WithBaseURL("https://some.host//"), "incorrect base URL: unexpected path \"//\""},
+		{WithAPIVersion(""), "API version can't be empty"},
+		{WithHTTPClient(nil), "HTTP client can't be nil"},
+		{WithTimeout(0), "timeout must be positive, got 0s"},
+		{WithTransport(nil), "transport can't be nil"},
+		{WithUserAgent(""), "user agent can't be empty"},
+		{WithDigestAlgorithm(structures.AlgorithmUnknown), "digest algorithm: unsupported algorithm unknown"},
+		{WithDigestQOP(structures.QopUnknown), "digest QOP: unsupported value unknown"},
+		{WithDigestQOP(structures.QopAuth, ""), "digest QOP: HTTP method can't be empty"},
+		{WithAcceptedDigestAlgorithms(), "accepted digest algorithms can't be empty"},
+		{WithAcceptedDigestAlgorithms(structures.AlgorithmUnknown), "accepted digest algorithms: unsupported algorithm unknown"},
+		{WithClock(nil), "clock can't be nil"},
+		{WithRandom(nil), "random source can't be nil"},
+	}
+
+	for _, testCase := range examples {
+		t.Run(testCase.expectedError, func(t *testing.T) {
+			gateCli, err := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey", testCase.option)
+			assert.Nil(t, gateCli)
+			assert.EqualError(t, err, testCase.expectedError)
+		})
+	}
+}
+
+func TestWithHTTPClientOrder(t *testing.T) {
+	transport := &http.Transport{}
+	jar := &cookiejar.Jar{}
+	client := &http.Client{Timeout: time.Second, Jar: jar}
+
+	gateCli, err := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey",
+		WithTimeout(5*time.Second),
+		WithTransport(transport),
+		WithHTTPClient(client),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, gateCli.HTTPClient.Timeout)
+	assert.Equal(t, transport, gateCli.HTTPClient.Transport)
+	assert.Equal(t, jar, gateCli.HTTPClient.Jar)
+
+	// the client's own settings are used without overriding options
+	gateCli, err = NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey", WithHTTPClient(client))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, gateCli.HTTPClient.Timeout)
+	assert.Nil(t, gateCli.HTTPClient.Transport)
+	assert.Nil(t, client.Transport)
+}
+
+func TestEnvironmentAndBaseURLAreExclusive(t *testing.T) {
+	_, err := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey",
+		WithEnvironment(EnvironmentProduction), WithBaseURL("https://some.host"))
+	assert.EqualError(t, err, "custom base URL can't be combined with environment")
+
+	_, err = NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey",
+		WithBaseURL("https://some.host"), WithEnvironment(EnvironmentProduction))
+	assert.EqualError(t, err, "environment can't be combined with custom base URL")
+}
+
+func TestWithUserAgentSetsHeader(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	gateCli, _ := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey",
+		WithBaseURL(server.URL), WithUserAgent("my-shop/1.0"))
+
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.NoError(t, err)
+	assert.Equal(t, "my-shop/1.0", userAgent)
+}
+
+func TestWithReplayGuard(t *testing.T) {
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		return http.StatusOK, "{}"
+	})
+	defer server.Close()
+
+	guard := structures.NewReplayGuard(time.Minute, nil)
+	guard.Now = func() time.Time { return time.Now().Add(time.Hour) }
+
+	gateCli := newTestClient(t, server, WithReplayGuard(guard))
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.True(t, errors.Is(err, structures.ErrStaleNonce))
+	assert.True(t, errors.Is(err, structures.ErrAuth))
+
+	gateCli = newTestClient(t, server, WithReplayGuard(nil))
+	_, err = gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.NoError(t, err)
+
+	_, err = NewGatewayClient(testObjectGUID, testSecretKey, WithReplayGuard(&structures.ReplayGuard{}))
+	assert.EqualError(t, err, "replay guard: clock skew window must be positive, got 0s")
+}
+
+func TestWithDigestAlgorithmAndQOP(t *testing.T) {
+	var authorizations []string
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		return http.StatusOK, "{}"
+	})
+	defer server.Close()
+
+	gateCli := newTestClient(t, server,
+		WithDigestAlgorithm(structures.AlgorithmSHA512),
+		WithDigestQOP(structures.QopAuth, http.MethodGet),
+	)
+
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.NoError(t, err)
+
+	formURL, _ := url.Parse(server.URL + "/form")
+	paymentResponse := &structures.TransactionResponse{}
+	paymentResponse.Gateway.RedirectURL = (*structures.URL)(formURL)
+	retrieveForm, err := gateCli.OperationBuilder().NewRetrieveForm(paymentResponse)
+	assert.NoError(t, err)
+
+	_, err = gateCli.NewRequest(retrieveForm)
+	assert.NoError(t, err)
+
+	if assert.Len(t, authorizations, 2) {
+		assert.Contains(t, authorizations[0], "algorithm=SHA-512")
+		assert.Contains(t, authorizations[0], "qop=auth-int")
+		assert.Contains(t, authorizations[1], "algorithm=SHA-512")
+		assert.Contains(t, authorizations[1], "qop=auth,")
+	}
+}
+
+func TestWithAcceptedDigestAlgorithms(t *testing.T) {
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		return http.StatusOK, "{}"
+	})
+	defer server.Close()
+
+	gateCli := newTestClient(t, server,
+		WithDigestAlgorithm(structures.AlgorithmSHA384),
+		WithAcceptedDigestAlgorithms(structures.AlgorithmSHA256, structures.AlgorithmSHA512),
+	)
+
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.True(t, errors.Is(err, structures.ErrAuth))
+	assert.Contains(t, err.Error(), "digest mismatch: algorithm SHA-384 is not allowed")
+
+	gateCli = newTestClient(t, server,
+		WithDigestAlgorithm(structures.AlgorithmSHA384),
+		WithAcceptedDigestAlgorithms(structures.AlgorithmSHA384),
+	)
+
+	_, err = gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.NoError(t, err)
+}
+
+func TestWithClockAndRandom(t *testing.T) {
+	var authorizations []string
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		return http.StatusOK, "{}"
+	})
+	defer server.Close()
+
+	now := time.Now().Truncate(time.Second)
+	for i := 0; i < 2; i++ {
+		gateCli := newTestClient(t, server,
+			WithClock(func() time.Time { return now }),
+			WithRandom(bytes.NewReader(bytes.Repeat([]byte{7}, 1024))),
+		)
+
+		_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+		assert.NoError(t, err)
+	}
+
+	if assert.Len(t, authorizations, 2) {
+		assert.Equal(t, authorizations[0], authorizations[1])
+	}
+}
+
+func TestWithClockDoesNotAffectResponseFreshness(t *testing.T) {
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		return http.StatusOK, "{}"
+	})
+	defer server.Close()
+
+	// the client's clock doesn't affect response freshness checks, so a fixed clock works with real responses
+	fixed := time.Unix(1591866573, 0)
+	gateCli := newTestClient(t, server, WithClock(func() time.Time { return fixed }))
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.NoError(t, err)
+
+	// responses are checked against the replay guard's clock
+	guard := structures.NewReplayGuard(time.Minute, nil)
+	guard.Now = func() time.Time { return fixed }
+	gateCli = newTestClient(t, server, WithReplayGuard(guard), WithClock(guard.Now))
+	_, err = gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.True(t, errors.Is(err, structures.ErrStaleNonce))
+	assert.True(t, errors.Is(err, structures.ErrAuth))
+}
@@ -0,0 +1,115 @@
+package tprogateway
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/TransactPRO/gw3-go-client/redact"
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// PreparedRequest is a signed request exactly as NewRequest would send it
+type PreparedRequest struct {
+	OperationType structures.OperationType
+	Method        string
+	URL           string
+	Header        http.Header
+	// Body is the marshalled request payload, empty for GET requests
+	Body []byte
+	// Digest is the request's Authorization header data
+	Digest *structures.RequestDigest
+}
+
+// DryRunError is returned by NewRequest in dry-run mode instead of sending the request
+type DryRunError struct {
+	Request *PreparedRequest
+}
+
+func (e *DryRunError) Error() string {
+	return fmt.Sprintf("dry run: %s %s was not sent", e.Request.Method, e.Request.URL)
+}
+
+// WithDryRun makes NewRequest prepare and sign requests without sending them.
+// Every request fails with an error wrapping *DryRunError, which holds the prepared request.
+// Retries, middleware and Observer are not involved.
+func WithDryRun(enabled bool) Option {
+	return func(gc *GatewayClient) error {
+		gc.dryRun = enabled
+		return nil
+	}
+}
+
+// Prepare builds and signs the operation's request without sending it, e.g. to inspect it while debugging.
+// Every call signs the request with a fresh digest, so the digest differs from the one of a request sent later.
+// Returned errors are *structures.GatewayError.
+func (gc *GatewayClient) Prepare(opData structures.OperationRequestInterface) (*PreparedRequest, error) {
+	return gc.prepare(context.Background(), opData)
+}
+
+func (gc *GatewayClient) prepare(ctx context.Context, opData structures.OperationRequestInterface) (*PreparedRequest, error) {
+	opType := opData.GetOperationType()
+
+	payload, requestURL, err := gc.payloadAndURL(opData)
+	if err != nil {
+		return nil, err
+	}
+
+	httpRequest, requestDigest, err := buildHTTPRequest(ctx, gc, opData.GetHTTPMethod(), requestURL, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, withOperationType(err, opType)
+	}
+
+	return &PreparedRequest{
+		OperationType: opType,
+		Method:        httpRequest.Method,
+		URL:           httpRequest.URL.String(),
+		Header:        httpRequest.Header,
+		Body:          payload,
+		Digest:        requestDigest,
+	}, nil
+}
+
+// dryRunError prepares the request and returns it as DryRunError
+func (gc *GatewayClient) dryRunError(ctx context.Context, opData structures.OperationRequestInterface) error {
+	prepared, err := gc.prepare(ctx, opData)
+	if err != nil {
+		return err
+	}
+
+	return newGatewayError(structures.ErrorCategoryUnknown, prepared.OperationType, 0, &DryRunError{Request: prepared})
+}
+
+// Curl renders the request as a curl command with cardholder data and the digest hash redacted,
+// so it's safe to share, e.g. with the Gateway support. The command can't be sent as is because of the redaction.
+func (p *PreparedRequest) Curl() string {
+	var command strings.Builder
+	command.WriteString("curl -X " + p.Method + " " + shellQuote(p.URL))
+
+	header := redact.Header(p.Header)
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range header[key] {
+			command.WriteString(" -H " + shellQuote(key+": "+value))
+		}
+	}
+
+	if len(p.Body) > 0 {
+		command.WriteString(" --data-raw " + shellQuote(string(redact.JSON(p.Body))))
+	}
+
+	return command.String()
+}
+
+// shellQuote quotes a value for POSIX shells
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,111 @@
+package tprogateway
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrepareMatchesSentRequest(t *testing.T) {
+	var sentAuthorization string
+	var sentBody []byte
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		sentAuthorization, sentBody = r.Header.Get("Authorization"), body
+		return http.StatusOK, "{}"
+	})
+	defer server.Close()
+
+	now := time.Now()
+	newClient := func() *GatewayClient {
+		return newTestClient(t, server,
+			WithClock(func() time.Time { return now }),
+			WithRandom(bytes.NewReader(bytes.Repeat([]byte{7}, 1024))),
+			WithUserAgent("my-shop/1.0"),
+		)
+	}
+
+	gateCli := newClient()
+	sms := gateCli.OperationBuilder().NewSms()
+	sms.PaymentMethod.Pan = "4111111111111111"
+
+	prepared, err := gateCli.Prepare(sms)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, structures.SMS, prepared.OperationType)
+	assert.Equal(t, http.MethodPost, prepared.Method)
+	assert.Equal(t, server.URL+"/v3.0/sms", prepared.URL)
+	assert.Equal(t, "application/json", prepared.Header.Get("Content-Type"))
+	assert.Equal(t, "my-shop/1.0", prepared.Header.Get("User-Agent"))
+	assert.Equal(t, "/v3.0/sms", prepared.Digest.URI)
+
+	gateCli = newClient()
+	_, err = gateCli.NewRequest(sms)
+	assert.NoError(t, err)
+	assert.Equal(t, sentAuthorization, prepared.Header.Get("Authorization"))
+	assert.Equal(t, sentBody, prepared.Body)
+}
+
+func TestPrepareValidation(t *testing.T) {
+	gateCli, _ := NewGatewayClient(testObjectGUID, testSecretKey)
+	gateCli.API.Version = ""
+
+	_, err := gateCli.Prepare(gateCli.OperationBuilder().NewSms())
+	assert.True(t, errors.Is(err, structures.ErrValidation))
+	assert.EqualError(t, err, "sms: validation: gateway client's Version is empty in, API settings")
+}
+
+func TestPreparedRequestCurl(t *testing.T) {
+	prepared := &PreparedRequest{
+		Method: http.MethodPost,
+		URL:    "https://api.sandbox.transactpro.io/v3.0/sms",
+		Header: http.Header{
+			"Authorization": {`Digest username=guid, uri="/v3.0/sms", response="abcdef"`},
+			"Content-Type":  {"application/json"},
+		},
+		Body: []byte(`{"data":{"payment-method":{"pan":"4111111111111111","cardholder-name":"John O'Neil"}}}`),
+	}
+
+	assert.Equal(t, `curl -X POST 'https://api.sandbox.transactpro.io/v3.0/sms'`+
+		` -H 'Authorization: Digest username=guid, uri="/v3.0/sms", response="***"'`+
+		` -H 'Content-Type: application/json'`+
+		` --data-raw '{"data":{"payment-method":{"cardholder-name":"***","pan":"411111***1111"}}}'`,
+		prepared.Curl())
+
+	prepared.Body = nil
+	prepared.Header = http.Header{"X-Note": {"it's"}}
+	assert.Equal(t, `curl -X POST 'https://api.sandbox.transactpro.io/v3.0/sms' -H 'X-Note: it'\''s'`, prepared.Curl())
+}
+
+func TestWithDryRun(t *testing.T) {
+	requests := 0
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		requests++
+		return http.StatusOK, "{}"
+	})
+	defer server.Close()
+
+	gateCli := newTestClient(t, server, WithDryRun(true))
+	gwResponse, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.Nil(t, gwResponse)
+	assert.Equal(t, 0, requests)
+
+	var dryRun *DryRunError
+	if assert.True(t, errors.As(err, &dryRun)) {
+		assert.Equal(t, structures.SMS, dryRun.Request.OperationType)
+		assert.Equal(t, server.URL+"/v3.0/sms", dryRun.Request.URL)
+		assert.NotEmpty(t, dryRun.Request.Header.Get("Authorization"))
+	}
+	assert.EqualError(t, err, "sms: unknown: dry run: POST "+server.URL+"/v3.0/sms was not sent")
+
+	assert.NoError(t, WithDryRun(false)(gateCli))
+	_, err = gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, requests)
+}
@@ -0,0 +1,180 @@
+package tprogateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// ErrMissingMerchantTransactionID is returned on recovery attempt of an operation without merchant transaction ID
+var ErrMissingMerchantTransactionID = errors.New("operation can't be recovered: merchant transaction ID is empty")
+
+// RecoverableOperation is a money-moving operation which outcome can be explored by its merchant transaction ID
+type RecoverableOperation interface {
+	structures.OperationRequestInterface
+	GetMerchantTransactionID() string
+}
+
+// RecoveryResult describes the real outcome of an operation with unknown result
+type RecoveryResult struct {
+	// Found is TRUE if the Gateway knows a transaction with the operation's merchant transaction ID
+	Found bool
+	// Resent is TRUE if the operation was sent again by ResendOrRecover
+	Resent bool
+	// GatewayTransactionID is the found transaction's ID in the Gateway
+	GatewayTransactionID string
+	// Status is the found transaction's current status
+	Status structures.Status
+	// Result is the found transaction's result data as it was returned by the Gateway
+	Result *structures.TransactionResponse
+}
+
+// SafeToResend returns TRUE if the Gateway has no transaction for the operation,
+// so it may be sent again without risk of a double charge
+func (o *RecoveryResult) SafeToResend() bool {
+	return !o.Found
+}
+
+// IsOutcomeUnknown returns TRUE if the error means that a request might have reached the Gateway,
+// but its result wasn't received (e.g., timeout or connection loss while waiting for response).
+// Outcome of such operations should be checked with RecoverTransaction before sending them again.
+func IsOutcomeUnknown(err error) bool {
+	return errors.Is(err, structures.ErrTransport)
+}
+
+// RecoverTransaction explores the Gateway for a transaction created by given operation,
+// using the merchant transaction ID that was sent with it.
+// Returned result tells whether the transaction exists and, if so, its current status and result.
+func (gc *GatewayClient) RecoverTransaction(ctx context.Context, op RecoverableOperation) (*RecoveryResult, error) {
+	merchantTransactionID := op.GetMerchantTransactionID()
+	if merchantTransactionID == "" {
+		return nil, ErrMissingMerchantTransactionID
+	}
+
+	statusRequest := gc.OperationBuilder().NewGetStatus()
+	statusRequest.CommandData.MerchantTransactionIDs = []string{merchantTransactionID}
+
+	statusResponse, err := gc.NewRequestWithContext(ctx, statusRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if !statusResponse.Successful() {
+		return nil, newGatewayError(structures.ErrorCategoryGateway, structures.ExploringStatus, statusResponse.StatusCode,
+			fmt.Errorf("cannot explore transaction status: unexpected HTTP status %d", statusResponse.StatusCode))
+	}
+
+	statuses, err := statusRequest.ParseResponse(statusResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	if statuses.Error != nil && statuses.Error.Code != 0 {
+		return nil, structures.NewGatewayError(structures.ExploringStatus, statusResponse.StatusCode, *statuses.Error)
+	}
+
+	if len(statuses.Transactions) == 0 {
+		return &RecoveryResult{}, nil
+	}
+
+	item := statuses.Transactions[0]
+	if item.Error != nil && item.Error.Code != 0 {
+		if item.Error.Code == structures.EecWrongGwUniqID || item.Error.Code == structures.EecUnacceptableGwUniqID {
+			return &RecoveryResult{}, nil
+		}
+
+		return nil, structures.NewGatewayError(structures.ExploringStatus, statusResponse.StatusCode, *item.Error)
+	}
+
+	result := &RecoveryResult{Found: true, GatewayTransactionID: item.GatewayTransactionID}
+	if len(item.Status) > 0 {
+		result.Status = item.Status[0].StatusCode
+		if result.GatewayTransactionID == "" {
+			result.GatewayTransactionID = item.Status[0].GatewayTransactionID
+		}
+	}
+
+	if result.Result, err = gc.exploreResult(ctx, result.GatewayTransactionID); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ResendOrRecover sends an operation with unknown outcome again only if the Gateway has no transaction for it.
+// If the transaction exists, its outcome is returned instead. If the resent operation collides with
+// a transaction created in the meantime (EecGwUniqIDConflict), the existing transaction's outcome is returned.
+// If the resent operation is rejected without creating a transaction, *structures.GatewayError is returned.
+func (gc *GatewayClient) ResendOrRecover(ctx context.Context, op RecoverableOperation) (*RecoveryResult, error) {
+	recovered, err := gc.RecoverTransaction(ctx, op)
+	if err != nil || !recovered.SafeToResend() {
+		return recovered, err
+	}
+
+	response, err := gc.NewRequestWithContext(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+
+	opType := op.GetOperationType()
+	parsed := new(structures.TransactionResponse)
+	parseErr := response.ParseJSON(parsed)
+	if parseErr == nil && parsed.Error.Code == structures.EecGwUniqIDConflict {
+		return gc.RecoverTransaction(ctx, op)
+	}
+
+	// a decline creates a transaction, while an error without gateway transaction ID means nothing was created
+	if !response.Successful() || (parseErr == nil && parsed.Error.Code != 0 && parsed.Gateway.GatewayTransactionID == "") {
+		return nil, responseError(opType, response)
+	}
+
+	if parseErr != nil {
+		return nil, newGatewayError(structures.ErrorCategoryGateway, opType, response.StatusCode, parseErr)
+	}
+
+	return &RecoveryResult{
+		Found:                true,
+		Resent:               true,
+		GatewayTransactionID: parsed.Gateway.GatewayTransactionID,
+		Status:               parsed.Gateway.StatusCode,
+		Result:               parsed,
+	}, nil
+}
+
+// exploreResult loads result data of a transaction
+func (gc *GatewayClient) exploreResult(ctx context.Context, gatewayTransactionID string) (*structures.TransactionResponse, error) {
+	resultRequest := gc.OperationBuilder().NewGetResult()
+	resultRequest.CommandData.GWTransactionIDs = []string{gatewayTransactionID}
+
+	resultResponse, err := gc.NewRequestWithContext(ctx, resultRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if !resultResponse.Successful() {
+		return nil, newGatewayError(structures.ErrorCategoryGateway, structures.ExploringResult, resultResponse.StatusCode,
+			fmt.Errorf("cannot explore transaction result: unexpected HTTP status %d", resultResponse.StatusCode))
+	}
+
+	results, err := resultRequest.ParseResponse(resultResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	if results.Error != nil && results.Error.Code != 0 {
+		return nil, structures.NewGatewayError(structures.ExploringResult, resultResponse.StatusCode, *results.Error)
+	}
+
+	if len(results.Transactions) == 0 {
+		return nil, newGatewayError(structures.ErrorCategoryGateway, structures.ExploringResult, resultResponse.StatusCode,
+			fmt.Errorf("cannot explore transaction result: no result for %s", gatewayTransactionID))
+	}
+
+	if results.Transactions[0].Error != nil && results.Transactions[0].Error.Code != 0 {
+		return nil, structures.NewGatewayError(structures.ExploringResult, resultResponse.StatusCode, *results.Transactions[0].Error)
+	}
+
+	return &results.Transactions[0].ResultData, nil
+}
@@ -0,0 +1,230 @@
+package tprogateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/TransactPRO/gw3-go-client/operations/transactions"
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	recoveryStatusFound = "{\"transactions\":[{\"gateway-transaction-id\":\"gw-1\",\"status\":[{\"gateway-transaction-id\":\"gw-1\"," +
+		"\"status-code\":7,\"status-text\":\"SUCCESS\"}]}]}"
+	recoveryStatusNotFound = "{\"transactions\":[]}"
+	recoveryResult         = "{\"transactions\":[{\"gateway-transaction-id\":\"gw-1\",\"date-created\":\"2020-06-09 09:56:53\"," +
+		"\"date-finished\":\"2020-06-09 09:57:53\",\"result-data\":{\"gw\":{\"gateway-transaction-id\":\"gw-1\"," +
+		"\"merchant-transaction-id\":\"order-1\",\"status-code\":7,\"status-text\":\"SUCCESS\"},\"error\":{}}}]}"
+)
+
+func newRecoverableSMS() *transactions.SMSAssembly {
+	order := transactions.NewSMSAssembly()
+	order.GeneralData.OrderData.MerchantTransactionID = "order-1"
+	return order
+}
+
+func TestRecoverTransactionFound(t *testing.T) {
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		switch r.URL.Path {
+		case "/v3.0/status":
+			var request struct {
+				Data struct {
+					CommandData structures.CommandDataExploreMerchantTransactionIDs `json:"command-data"`
+				} `json:"data"`
+			}
+			_ = json.Unmarshal(body, &request)
+			assert.Equal(t, []string{"order-1"}, request.Data.CommandData.MerchantTransactionIDs)
+			return http.StatusOK, recoveryStatusFound
+		case "/v3.0/result":
+			return http.StatusOK, recoveryResult
+		}
+
+		return http.StatusNotFound, ""
+	})
+	defer server.Close()
+
+	gateCli := newTestClient(t, server)
+	result, err := gateCli.RecoverTransaction(context.Background(), newRecoverableSMS())
+	assert.NoError(t, err)
+
+	assert.True(t, result.Found)
+	assert.False(t, result.SafeToResend())
+	assert.False(t, result.Resent)
+	assert.Equal(t, "gw-1", result.GatewayTransactionID)
+	assert.Equal(t, structures.StatusSuccess, result.Status)
+	assert.Equal(t, "order-1", result.Result.Gateway.MerchantTransactionID)
+}
+
+func TestRecoverTransactionNotFound(t *testing.T) {
+	examples := map[string]string{
+		"empty list": recoveryStatusNotFound,
+		"item error": "{\"transactions\":[{\"error\":{\"code\":1151,\"message\":\"not found\"}}]}",
+	}
+
+	for name, statusResponse := range examples {
+		t.Run(name, func(t *testing.T) {
+			server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+				return http.StatusOK, statusResponse
+			})
+			defer server.Close()
+
+			gateCli := newTestClient(t, server)
+			result, err := gateCli.RecoverTransaction(context.Background(), newRecoverableSMS())
+			assert.NoError(t, err)
+			assert.False(t, result.Found)
+			assert.True(t, result.SafeToResend())
+		})
+	}
+}
+
+func TestRecoverTransactionErrors(t *testing.T) {
+	gateCli, _ := NewGatewayClient(testObjectGUID, testSecretKey)
+	_, err := gateCli.RecoverTransaction(context.Background(), transactions.NewSMSAssembly())
+	assert.Equal(t, ErrMissingMerchantTransactionID, err)
+
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		return http.StatusOK, "{\"transactions\":[{\"error\":{\"code\":1000,\"message\":\"General error\"}}]}"
+	})
+	defer server.Close()
+
+	gateCli = newTestClient(t, server)
+	_, err = gateCli.RecoverTransaction(context.Background(), newRecoverableSMS())
+	assert.True(t, errors.Is(err, structures.ErrGateway))
+
+	server = newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		return http.StatusServiceUnavailable, ""
+	})
+	defer server.Close()
+
+	gateCli = newTestClient(t, server, WithRetryPolicy(nil))
+	_, err = gateCli.RecoverTransaction(context.Background(), newRecoverableSMS())
+	assert.EqualError(t, err, "status: gateway: cannot explore transaction status: unexpected HTTP status 503")
+	assert.True(t, errors.Is(err, structures.ErrGateway))
+}
+
+func TestResendOrRecoverResends(t *testing.T) {
+	var sent bool
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		switch r.URL.Path {
+		case "/v3.0/status":
+			return http.StatusOK, recoveryStatusNotFound
+		case "/v3.0/sms":
+			sent = true
+			return http.StatusOK, "{\"gw\":{\"gateway-transaction-id\":\"gw-2\",\"status-code\":7},\"error\":{}}"
+		}
+
+		return http.StatusNotFound, ""
+	})
+	defer server.Close()
+
+	gateCli := newTestClient(t, server)
+	result, err := gateCli.ResendOrRecover(context.Background(), newRecoverableSMS())
+	assert.NoError(t, err)
+	assert.True(t, sent)
+	assert.True(t, result.Found)
+	assert.True(t, result.Resent)
+	assert.Equal(t, "gw-2", result.GatewayTransactionID)
+	assert.Equal(t, structures.StatusSuccess, result.Status)
+}
+
+func TestResendOrRecoverRejected(t *testing.T) {
+	examples := []struct {
+		name          string
+		status        int
+		payload       string
+		expectedError string
+		category      error
+	}{
+		{"validation error", http.StatusBadRequest, "{\"error\":{\"code\":1100,\"message\":\"Invalid request\"}}",
+			"sms: validation: 1100 Invalid request", structures.ErrValidation},
+		{"error with success status", http.StatusOK, "{\"gw\":{\"status-code\":19},\"error\":{\"code\":1000,\"message\":\"General error\"}}",
+			"sms: gateway: 1000 General error", structures.ErrGateway},
+		{"unexpected status", http.StatusInternalServerError, "<html></html>",
+			"sms: gateway: unexpected HTTP status 500", structures.ErrGateway},
+		{"malformed payload", http.StatusOK, "<html></html>", "", structures.ErrGateway},
+	}
+
+	for _, testCase := range examples {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+				if r.URL.Path == "/v3.0/status" {
+					return http.StatusOK, recoveryStatusNotFound
+				}
+				return testCase.status, testCase.payload
+			})
+			defer server.Close()
+
+			gateCli := newTestClient(t, server, WithRetryPolicy(nil))
+			result, err := gateCli.ResendOrRecover(context.Background(), newRecoverableSMS())
+			assert.Nil(t, result)
+			assert.True(t, errors.Is(err, testCase.category))
+			if testCase.expectedError != "" {
+				assert.EqualError(t, err, testCase.expectedError)
+			}
+
+			var gwErr *structures.GatewayError
+			assert.True(t, errors.As(err, &gwErr))
+		})
+	}
+}
+
+func TestResendOrRecoverDoesNotResendExisting(t *testing.T) {
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		switch r.URL.Path {
+		case "/v3.0/status":
+			return http.StatusOK, recoveryStatusFound
+		case "/v3.0/result":
+			return http.StatusOK, recoveryResult
+		}
+
+		t.Errorf("unexpected request to %s", r.URL.Path)
+		return http.StatusNotFound, ""
+	})
+	defer server.Close()
+
+	gateCli := newTestClient(t, server)
+	result, err := gateCli.ResendOrRecover(context.Background(), newRecoverableSMS())
+	assert.NoError(t, err)
+	assert.True(t, result.Found)
+	assert.False(t, result.Resent)
+	assert.Equal(t, "gw-1", result.GatewayTransactionID)
+}
+
+func TestResendOrRecoverConflict(t *testing.T) {
+	var statusRequests int
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		switch r.URL.Path {
+		case "/v3.0/status":
+			statusRequests++
+			if statusRequests == 1 {
+				return http.StatusOK, recoveryStatusNotFound
+			}
+			return http.StatusOK, recoveryStatusFound
+		case "/v3.0/result":
+			return http.StatusOK, recoveryResult
+		case "/v3.0/sms":
+			return http.StatusOK, "{\"gw\":{\"status-code\":19},\"error\":{\"code\":1153,\"message\":\"conflict\"}}"
+		}
+
+		return http.StatusNotFound, ""
+	})
+	defer server.Close()
+
+	gateCli := newTestClient(t, server)
+	result, err := gateCli.ResendOrRecover(context.Background(), newRecoverableSMS())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, statusRequests)
+	assert.True(t, result.Found)
+	assert.False(t, result.Resent)
+	assert.Equal(t, "gw-1", result.GatewayTransactionID)
+	assert.Equal(t, structures.StatusSuccess, result.Status)
+}
+
+func TestIsOutcomeUnknown(t *testing.T) {
+	assert.True(t, IsOutcomeUnknown(newGatewayError(structures.ErrorCategoryTransport, structures.SMS, 0, errors.New("eof"))))
+	assert.False(t, IsOutcomeUnknown(newGatewayError(structures.ErrorCategoryValidation, structures.SMS, 0, errors.New("bad URL"))))
+}
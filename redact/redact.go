@@ -0,0 +1,125 @@
+// Package redact masks cardholder data and secrets in Transact Pro Gateway payloads and headers,
+// so they can be logged or stored without leaking sensitive information.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// Mask replaces fully hidden values
+const Mask = "***"
+
+// sensitiveFields are JSON keys which values must never be exposed, mapped to their masking functions
+var sensitiveFields = map[string]func(string) string{
+	"pan":                       PAN,
+	"cvv":                       full,
+	"exp-mm-yy":                 full,
+	"cardholder-name":           full,
+	"payment-method-data-token": full,
+	"session-id":                full,
+	"cavv":                      full,
+	"xid":                       full,
+}
+
+// sensitiveHeaders are HTTP headers which values are masked by Header
+var sensitiveHeaders = map[string]func(string) string{
+	"Authorization": Authorization,
+	"Cookie":        full,
+	"Set-Cookie":    full,
+}
+
+var digestResponsePattern = regexp.MustCompile(`(response=)("[^"]*"|[^,\s]*)`)
+
+func full(string) string {
+	return Mask
+}
+
+// PAN masks a card number, keeping only the first 6 and the last 4 digits visible
+func PAN(pan string) string {
+	if len(pan) < 13 {
+		return Mask
+	}
+
+	return pan[:6] + Mask + pan[len(pan)-4:]
+}
+
+// Authorization masks the response hash of a digest Authorization header value,
+// other digest fields (like username and URI) stay visible
+func Authorization(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	return digestResponsePattern.ReplaceAllString(value, `${1}"`+Mask+`"`)
+}
+
+// Header returns a copy of HTTP headers with sensitive values masked
+func Header(header http.Header) http.Header {
+	result := make(http.Header, len(header))
+	for key, values := range header {
+		masker, sensitive := sensitiveHeaders[http.CanonicalHeaderKey(key)]
+
+		copied := make([]string, len(values))
+		for i := range values {
+			if sensitive {
+				copied[i] = masker(values[i])
+			} else {
+				copied[i] = values[i]
+			}
+		}
+		result[key] = copied
+	}
+
+	return result
+}
+
+// JSON returns a copy of a JSON payload with sensitive fields masked at any nesting level.
+// Payloads that are not JSON (like CSV reports or HTML forms) can't be inspected,
+// so they are replaced with a short description.
+func JSON(payload []byte) []byte {
+	if len(bytes.TrimSpace(payload)) == 0 {
+		return payload
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil || decoder.More() {
+		return []byte(fmt.Sprintf("[%d bytes of non-JSON payload redacted]", len(payload)))
+	}
+
+	result, err := json.Marshal(walk(document))
+	if err != nil {
+		return []byte(fmt.Sprintf("[%d bytes of payload redacted]", len(payload)))
+	}
+
+	return result
+}
+
+func walk(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, item := range typed {
+			if masker, ok := sensitiveFields[key]; ok {
+				if str, isString := item.(string); isString {
+					typed[key] = masker(str)
+				} else if item != nil {
+					typed[key] = Mask
+				}
+				continue
+			}
+			typed[key] = walk(item)
+		}
+	case []interface{}:
+		for i := range typed {
+			typed[i] = walk(typed[i])
+		}
+	}
+
+	return value
+}
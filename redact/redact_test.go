@@ -0,0 +1,55 @@
+package redact
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPAN(t *testing.T) {
+	assert.Equal(t, "411111***1111", PAN("4111111111111111"))
+	assert.Equal(t, "***", PAN("41111111"))
+	assert.Equal(t, "***", PAN(""))
+}
+
+func TestAuthorization(t *testing.T) {
+	header := "Digest username=guid, uri=\"/v3.0/sms\", algorithm=SHA-256, cnonce=\"MTU5\", qop=auth-int, " +
+		"response=\"a21df219fd9bb2efb71554eb9ebb47f6a7a61769a289f9ab4fcbe41d7544e28d\""
+	expected := "Digest username=guid, uri=\"/v3.0/sms\", algorithm=SHA-256, cnonce=\"MTU5\", qop=auth-int, response=\"***\""
+
+	assert.Equal(t, expected, Authorization(header))
+	assert.Equal(t, "Digest username=guid, response=\"***\"", Authorization("Digest username=guid, response=abc"))
+	assert.Equal(t, "", Authorization(""))
+}
+
+func TestHeader(t *testing.T) {
+	original := http.Header{
+		"Authorization": {"Digest username=guid, response=\"abc\""},
+		"Content-Type":  {"application/json"},
+	}
+
+	redacted := Header(original)
+	assert.Equal(t, "Digest username=guid, response=\"***\"", redacted.Get("Authorization"))
+	assert.Equal(t, "application/json", redacted.Get("Content-Type"))
+	assert.Equal(t, "Digest username=guid, response=\"abc\"", original.Get("Authorization"))
+}
+
+func TestJSON(t *testing.T) {
+	payload := "{\"auth-data\":{\"session-id\":\"secret-session\"},\"data\":{\"command-data\":{\"payment-method-data-token\":\"tok\"}," +
+		"\"payment-method-data\":{\"pan\":\"4111111111111111\",\"exp-mm-yy\":\"09/31\",\"cvv\":\"123\",\"cardholder-name\":\"John Doe\"," +
+		"\"external-mpi-data\":{\"cavv\":\"AAAB\",\"xid\":\"x\"}},\"money-data\":{\"amount\":100,\"currency\":\"EUR\"}}," +
+		"\"transactions\":[{\"cardholder-name\":\"John Doe\"}]}"
+	expected := "{\"auth-data\":{\"session-id\":\"***\"},\"data\":{\"command-data\":{\"payment-method-data-token\":\"***\"}," +
+		"\"money-data\":{\"amount\":100,\"currency\":\"EUR\"},\"payment-method-data\":{\"cardholder-name\":\"***\",\"cvv\":\"***\"," +
+		"\"exp-mm-yy\":\"***\",\"external-mpi-data\":{\"cavv\":\"***\",\"xid\":\"***\"},\"pan\":\"411111***1111\"}}," +
+		"\"transactions\":[{\"cardholder-name\":\"***\"}]}"
+
+	assert.Equal(t, expected, string(JSON([]byte(payload))))
+}
+
+func TestJSONNonJSONPayload(t *testing.T) {
+	assert.Equal(t, "[34 bytes of non-JSON payload redacted]", string(JSON([]byte("name,pan\nJohn Doe,4111111111111111"))))
+	assert.Equal(t, "[4 bytes of non-JSON payload redacted]", string(JSON([]byte("{}{}"))))
+	assert.Equal(t, "", string(JSON(nil)))
+}
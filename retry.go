@@ -0,0 +1,159 @@
+package tprogateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// Default retry policy settings
+const (
+	dRetryMaxAttempts    = 3
+	dRetryInitialBackoff = 200 * time.Millisecond
+	dRetryMaxBackoff     = 2 * time.Second
+	dRetryMultiplier     = 2
+	dRetryJitter         = 0.2
+)
+
+// readOnlyOperations are operations that don't change any state in the Gateway and are safe to repeat
+var readOnlyOperations = map[structures.OperationType]bool{
+	structures.ExploringStatus:  true,
+	structures.ExploringResult:  true,
+	structures.ExploringHistory: true,
+	structures.ExploringLimits:  true,
+	structures.Report:           true,
+}
+
+// RetryPolicy describes how failed requests are repeated.
+// Every attempt is signed with a fresh digest and cnonce.
+type RetryPolicy struct {
+	// MaxAttempts limits the number of attempts, including the first one
+	MaxAttempts int
+	// InitialBackoff is a delay before the second attempt
+	InitialBackoff time.Duration
+	// MaxBackoff limits exponential growth of the delay
+	MaxBackoff time.Duration
+	// Multiplier is the delay growth factor per attempt
+	Multiplier float64
+	// Jitter is a fraction of the delay randomly added or subtracted (from 0 to 1)
+	Jitter float64
+	// ShouldRetry decides whether a failed attempt may be repeated.
+	// Response is nil if no response was received.
+	ShouldRetry func(opType structures.OperationType, response *http.Response, err error) bool
+}
+
+// DefaultRetryPolicy returns policy that repeats read-only operations on transport failures and 5xx responses
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    dRetryMaxAttempts,
+		InitialBackoff: dRetryInitialBackoff,
+		MaxBackoff:     dRetryMaxBackoff,
+		Multiplier:     dRetryMultiplier,
+		Jitter:         dRetryJitter,
+		ShouldRetry:    DefaultShouldRetry,
+	}
+}
+
+// IsReadOnlyOperation returns TRUE for operations that are safe to repeat
+func IsReadOnlyOperation(opType structures.OperationType) bool {
+	return readOnlyOperations[opType]
+}
+
+// DefaultShouldRetry allows retries of read-only operations only,
+// for transport failures (except aborted requests) and 5xx responses
+func DefaultShouldRetry(opType structures.OperationType, response *http.Response, err error) bool {
+	if !IsReadOnlyOperation(opType) {
+		return false
+	}
+
+	if err != nil {
+		var abortedErr *RequestAbortedError
+		return errors.Is(err, structures.ErrTransport) && !errors.As(err, &abortedErr)
+	}
+
+	return response != nil && response.StatusCode >= http.StatusInternalServerError
+}
+
+// WithRetryPolicy sets retry policy for the client, nil disables retries
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(gc *GatewayClient) error {
+		if policy != nil {
+			if err := policy.validate(); err != nil {
+				return err
+			}
+		}
+
+		gc.retryPolicy = policy
+		return nil
+	}
+}
+
+func (p *RetryPolicy) validate() error {
+	switch {
+	case p.MaxAttempts < 1:
+		return fmt.Errorf("retry policy: max attempts must be positive, got %d", p.MaxAttempts)
+	case p.InitialBackoff < 0:
+		return fmt.Errorf("retry policy: initial backoff can't be negative, got %s", p.InitialBackoff)
+	case p.MaxBackoff < p.InitialBackoff:
+		return fmt.Errorf("retry policy: max backoff %s is less than initial backoff %s", p.MaxBackoff, p.InitialBackoff)
+	case p.Multiplier < 1:
+		return fmt.Errorf("retry policy: multiplier must be at least 1, got %g", p.Multiplier)
+	case p.Jitter < 0 || p.Jitter > 1:
+		return fmt.Errorf("retry policy: jitter must be between 0 and 1, got %g", p.Jitter)
+	case p.ShouldRetry == nil:
+		return errors.New("retry policy: ShouldRetry can't be nil")
+	}
+
+	return nil
+}
+
+// allowsRetry reports whether the failed attempt with given number may be repeated
+func (p *RetryPolicy) allowsRetry(attempt int, opType structures.OperationType, response *structures.GatewayResponse, err error) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+
+	var httpResponse *http.Response
+	if response != nil {
+		httpResponse = response.Response
+	}
+
+	if err == nil && response.Successful() {
+		return false
+	}
+
+	return p.ShouldRetry(opType, httpResponse, err)
+}
+
+// backoff returns delay after given attempt number
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay)
+}
+
+// sleepContext waits for given duration or until the context is done
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,194 @@
+package tprogateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+func fastRetryPolicy() *RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.MaxBackoff = time.Millisecond
+	return policy
+}
+
+func TestRetryReadOnlyOperationOnServerError(t *testing.T) {
+	var attempts int32
+	var cnonces []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		cnonces = append(cnonces, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	gateCli, _ := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey",
+		WithBaseURL(server.URL), WithRetryPolicy(fastRetryPolicy()))
+
+	resp, err := gateCli.NewRequest(gateCli.OperationBuilder().NewGetStatus())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(dRetryMaxAttempts), atomic.LoadInt32(&attempts))
+
+	// every attempt must be signed with a fresh digest
+	assert.Len(t, cnonces, dRetryMaxAttempts)
+	assert.NotEqual(t, cnonces[0], cnonces[1])
+	assert.NotEqual(t, cnonces[1], cnonces[2])
+}
+
+func TestNoRetryForMoneyMovingOperation(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	gateCli, _ := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey",
+		WithBaseURL(server.URL), WithRetryPolicy(fastRetryPolicy()))
+
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestRetryOnTransportError(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		_ = conn.Close()
+	}))
+	defer server.Close()
+
+	gateCli, _ := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey",
+		WithBaseURL(server.URL), WithRetryPolicy(fastRetryPolicy()))
+
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewGetLimits())
+	assert.True(t, errors.Is(err, structures.ErrTransport))
+	assert.Equal(t, int32(dRetryMaxAttempts), atomic.LoadInt32(&attempts))
+}
+
+func TestRetryDisabled(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	gateCli, _ := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey",
+		WithBaseURL(server.URL), WithRetryPolicy(nil))
+
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewGetStatus())
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestRetryCustomHook(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	policy := fastRetryPolicy()
+	policy.MaxAttempts = 2
+	policy.ShouldRetry = func(opType structures.OperationType, response *http.Response, err error) bool {
+		return response != nil && response.StatusCode == http.StatusTooManyRequests
+	}
+
+	gateCli, _ := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey",
+		WithBaseURL(server.URL), WithRetryPolicy(policy))
+
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+}
+
+func TestRetryBackoffRespectsContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Hour
+	policy.MaxBackoff = time.Hour
+
+	gateCli, _ := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey",
+		WithBaseURL(server.URL), WithRetryPolicy(policy))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := gateCli.NewRequestWithContext(ctx, gateCli.OperationBuilder().NewGetStatus())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := &RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     3,
+	}
+
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 300*time.Millisecond, policy.backoff(2))
+	assert.Equal(t, 900*time.Millisecond, policy.backoff(3))
+	assert.Equal(t, time.Second, policy.backoff(4))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := policy.backoff(1)
+		assert.True(t, delay >= 50*time.Millisecond && delay <= 150*time.Millisecond)
+	}
+}
+
+func TestRetryPolicyValidation(t *testing.T) {
+	examples := []struct {
+		modify        func(p *RetryPolicy)
+		expectedError string
+	}{
+		{func(p *RetryPolicy) { p.MaxAttempts = 0 }, "retry policy: max attempts must be positive, got 0"},
+		{func(p *RetryPolicy) { p.InitialBackoff = -1 }, "retry policy: initial backoff can't be negative, got -1ns"},
+		{func(p *RetryPolicy) { p.MaxBackoff = time.Millisecond }, "retry policy: max backoff 1ms is less than initial backoff 200ms"},
+		{func(p *RetryPolicy) { p.Multiplier = 0.5 }, "retry policy: multiplier must be at least 1, got 0.5"},
+		{func(p *RetryPolicy) { p.Jitter = 2 }, "retry policy: jitter must be between 0 and 1, got 2"},
+		{func(p *RetryPolicy) { p.ShouldRetry = nil }, "retry policy: ShouldRetry can't be nil"},
+	}
+
+	for _, testCase := range examples {
+		t.Run(testCase.expectedError, func(t *testing.T) {
+			policy := DefaultRetryPolicy()
+			testCase.modify(policy)
+
+			_, err := NewGatewayClient("3383e58e-9cde-4ffa-85cf-81cd25b2423e", "SecKey", WithRetryPolicy(policy))
+			assert.EqualError(t, err, testCase.expectedError)
+		})
+	}
+}
+
+func TestDefaultShouldRetry(t *testing.T) {
+	transportErr := newGatewayError(structures.ErrorCategoryTransport, structures.ExploringStatus, 0, errors.New("eof"))
+	abortedErr := newGatewayError(structures.ErrorCategoryTransport, structures.ExploringStatus, 0, &RequestAbortedError{Err: context.Canceled})
+	authErr := newGatewayError(structures.ErrorCategoryAuth, structures.ExploringStatus, 0, errors.New("digest mismatch"))
+
+	assert.True(t, DefaultShouldRetry(structures.ExploringStatus, nil, transportErr))
+	assert.False(t, DefaultShouldRetry(structures.ExploringStatus, nil, abortedErr))
+	assert.False(t, DefaultShouldRetry(structures.ExploringStatus, nil, authErr))
+	assert.True(t, DefaultShouldRetry(structures.Report, &http.Response{StatusCode: 500}, nil))
+	assert.False(t, DefaultShouldRetry(structures.Report, &http.Response{StatusCode: 400}, nil))
+	assert.False(t, DefaultShouldRetry(structures.SMS, nil, transportErr))
+	assert.False(t, DefaultShouldRetry(structures.ExploringRefunds, nil, transportErr))
+}
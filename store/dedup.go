@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// DefaultClaimTimeout is the time a callback claim is held by default, before it's considered abandoned
+const DefaultClaimTimeout = 5 * time.Minute
+
+// CallbackDisposition tells how a callback relates to the callbacks seen before for the same transaction
+type CallbackDisposition int
+
+// Callback dispositions
+const (
+	// CallbackNew is the first callback with its status for the transaction
+	CallbackNew CallbackDisposition = iota
+	// CallbackDuplicate repeats a status already processed for the transaction
+	CallbackDuplicate
+	// CallbackOutOfOrder is the first callback with its status, but a later status was already seen for the transaction
+	CallbackOutOfOrder
+	// CallbackInProgress repeats a status which callback is being processed by another delivery
+	CallbackInProgress
+)
+
+var callbackDisposition2string = map[CallbackDisposition]string{
+	CallbackNew:        "new",
+	CallbackDuplicate:  "duplicate",
+	CallbackOutOfOrder: "out-of-order",
+	CallbackInProgress: "in-progress",
+}
+
+func (o CallbackDisposition) String() string {
+	if result, ok := callbackDisposition2string[o]; ok {
+		return result
+	}
+
+	return "unknown"
+}
+
+type (
+	// CallbackKey identifies a callback delivery
+	CallbackKey struct {
+		GatewayTransactionID string
+		Status               structures.Status
+		Snonce               []byte
+	}
+
+	// CallbackDedupStore remembers processed callbacks, so every status transition of a transaction is processed once.
+	// A callback is claimed before processing and committed after it succeeded: a claim that is neither committed
+	// nor forgotten (e.g. the process crashed) is abandoned after a timeout, and the next delivery claims it again.
+	// Implementations must be safe for concurrent use.
+	CallbackDedupStore interface {
+		// Claim atomically registers the callback as being processed and reports its disposition.
+		// Callbacks with the same transaction ID and status are duplicates once committed, regardless of their snonce,
+		// and CallbackInProgress while claimed by another delivery. Only CallbackNew and CallbackOutOfOrder
+		// callbacks are claimed, they must be committed or forgotten.
+		Claim(ctx context.Context, key CallbackKey) (CallbackDisposition, error)
+		// Commit marks the callback claimed with the same key (including snonce) as processed
+		Commit(ctx context.Context, key CallbackKey) error
+		// Forget removes the claim with the same key (including snonce), so its redelivery is processed again.
+		// It's used when callback processing failed.
+		Forget(ctx context.Context, key CallbackKey) error
+	}
+)
+
+// statusRanks orders statuses along a transaction's lifecycle, unknown statuses have zero rank
+var statusRanks = map[structures.Status]int{
+	structures.StatusInit: 1,
+
+	structures.StatusSent2Bank:            2,
+	structures.StatusCardholderOnSite:     2,
+	structures.StatusCallbackURLGenerated: 2,
+	structures.StatusWaitingCardFormFill:  2,
+	structures.StatusMpiURLGenerated:      2,
+	structures.StatusWaitingMpi:           2,
+	structures.StatusCardFormURLSent:      2,
+
+	structures.StatusDmsHoldOK: 3,
+
+	structures.StatusSuccess:                       4,
+	structures.StatusDmsHoldFailed:                 4,
+	structures.StatusSmsFailed:                     4,
+	structures.StatusDmsChargeFailed:               4,
+	structures.StatusExpired:                       4,
+	structures.StatusHoldExpired:                   4,
+	structures.StatusDmsCanceled:                   4,
+	structures.StatusDmsCancelFailed:               4,
+	structures.StatusInputValidationFailed:         4,
+	structures.StatusBusinessRulesValidationFailed: 4,
+	structures.StatusTerminalGroupSelectFailed:     4,
+	structures.StatusTerminalSelectFailed:          4,
+	structures.StatusInitParamsInvalid:             4,
+	structures.StatusDeclinedByBusinessRulesAction: 4,
+	structures.StatusMpiFailed:                     4,
+	structures.StatusMpiNotReachable:               4,
+	structures.StatusMpiAuthError:                  4,
+	structures.StatusAcquirerNotReachable:          4,
+	structures.StatusCreditFailed:                  4,
+	structures.StatusP2PFailed:                     4,
+	structures.StatusB2PFailed:                     4,
+	structures.StatusTokenCreated:                  4,
+	structures.StatusTokenCreateFailed:             4,
+
+	structures.StatusRefundPending: 5,
+
+	structures.StatusRefundSuccess:  6,
+	structures.StatusRefundFailed:   6,
+	structures.StatusReversed:       6,
+	structures.StatusReversalFailed: 6,
+}
+
+// StatusRank returns the position of the status in a transaction's lifecycle:
+// initial and intermediate statuses rank lower than final ones, refunds and reversals rank the highest.
+// Unknown statuses have zero rank and are never considered out-of-order.
+func StatusRank(status structures.Status) int {
+	return statusRanks[status]
+}
+
+// disposition decides disposition of a callback which status wasn't claimed yet, given other statuses
+// seen before for the transaction
+func disposition(status structures.Status, seen []structures.Status) CallbackDisposition {
+	rank := StatusRank(status)
+	for _, seenStatus := range seen {
+		if rank > 0 && StatusRank(seenStatus) > rank {
+			return CallbackOutOfOrder
+		}
+	}
+
+	return CallbackNew
+}
+
+// claimTimeout returns given timeout or DefaultClaimTimeout if it's not positive
+func claimTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return DefaultClaimTimeout
+	}
+
+	return timeout
+}
+
+func (k *CallbackKey) validate() error {
+	if k.GatewayTransactionID == "" {
+		return errors.New("gateway transaction ID can't be empty")
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// MemoryCallbackDedupStore is an in-memory CallbackDedupStore, its records are lost on restart
+type MemoryCallbackDedupStore struct {
+	// ClaimTimeout is the time a claim is held before it's considered abandoned, DefaultClaimTimeout if zero
+	ClaimTimeout time.Duration
+
+	mu           sync.Mutex
+	transactions map[string]map[structures.Status]*callbackClaim
+}
+
+// callbackClaim is the state of a claimed status of a transaction
+type callbackClaim struct {
+	snonce    []byte
+	claimedAt time.Time
+	committed bool
+}
+
+// NewMemoryCallbackDedupStore creates empty MemoryCallbackDedupStore
+func NewMemoryCallbackDedupStore() *MemoryCallbackDedupStore {
+	return &MemoryCallbackDedupStore{transactions: make(map[string]map[structures.Status]*callbackClaim)}
+}
+
+// Claim implements CallbackDedupStore
+func (s *MemoryCallbackDedupStore) Claim(ctx context.Context, key CallbackKey) (CallbackDisposition, error) {
+	if err := key.validate(); err != nil {
+		return CallbackNew, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	statuses := s.transactions[key.GatewayTransactionID]
+	if claim, ok := statuses[key.Status]; ok {
+		if claim.committed {
+			return CallbackDuplicate, nil
+		}
+		if now.Sub(claim.claimedAt) < claimTimeout(s.ClaimTimeout) {
+			return CallbackInProgress, nil
+		}
+	}
+
+	seen := make([]structures.Status, 0, len(statuses))
+	for status := range statuses {
+		if status != key.Status {
+			seen = append(seen, status)
+		}
+	}
+
+	if statuses == nil {
+		statuses = make(map[structures.Status]*callbackClaim)
+		s.transactions[key.GatewayTransactionID] = statuses
+	}
+	statuses[key.Status] = &callbackClaim{snonce: append([]byte(nil), key.Snonce...), claimedAt: now}
+
+	return disposition(key.Status, seen), nil
+}
+
+// Commit implements CallbackDedupStore
+func (s *MemoryCallbackDedupStore) Commit(ctx context.Context, key CallbackKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if claim, ok := s.transactions[key.GatewayTransactionID][key.Status]; ok && bytes.Equal(claim.snonce, key.Snonce) {
+		claim.committed = true
+	}
+
+	return nil
+}
+
+// Forget implements CallbackDedupStore
+func (s *MemoryCallbackDedupStore) Forget(ctx context.Context, key CallbackKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := s.transactions[key.GatewayTransactionID]
+	if claim, ok := statuses[key.Status]; ok && !claim.committed && bytes.Equal(claim.snonce, key.Snonce) {
+		delete(statuses, key.Status)
+		if len(statuses) == 0 {
+			delete(s.transactions, key.GatewayTransactionID)
+		}
+	}
+
+	return nil
+}
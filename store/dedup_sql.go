@@ -0,0 +1,209 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// DefaultCallbackTable is the table name used by SQLCallbackDedupStore by default
+const DefaultCallbackTable = "gateway_callbacks"
+
+// SQLCallbackDedupStore is a CallbackDedupStore backed by database/sql, shared between replicas using the same database.
+// Concurrent deliveries of the same callback are resolved by the table's primary key.
+type SQLCallbackDedupStore struct {
+	// ClaimTimeout is the time a claim is held before it's considered abandoned, DefaultClaimTimeout if zero.
+	// It should exceed the time callback functions take.
+	ClaimTimeout time.Duration
+
+	db          *sql.DB
+	table       string
+	placeholder Placeholder
+}
+
+// NewSQLCallbackDedupStore creates SQLCallbackDedupStore using given table (DefaultCallbackTable if empty)
+// and placeholder style (QuestionPlaceholder if nil). Use CreateTable or CallbackTableSchema to create the table.
+func NewSQLCallbackDedupStore(db *sql.DB, table string, placeholder Placeholder) (*SQLCallbackDedupStore, error) {
+	if db == nil {
+		return nil, errors.New("database can't be nil")
+	}
+
+	if table == "" {
+		table = DefaultCallbackTable
+	}
+	if !tableNamePattern.MatchString(table) {
+		return nil, fmt.Errorf("incorrect table name %q", table)
+	}
+
+	if placeholder == nil {
+		placeholder = QuestionPlaceholder
+	}
+
+	return &SQLCallbackDedupStore{db: db, table: table, placeholder: placeholder}, nil
+}
+
+// CallbackTableSchema returns CREATE TABLE statement for SQLCallbackDedupStore table
+func CallbackTableSchema(table string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	gateway_transaction_id VARCHAR(64) NOT NULL,
+	status_code INTEGER NOT NULL,
+	snonce VARCHAR(255) NOT NULL,
+	claimed_at TIMESTAMP NOT NULL,
+	processed_at TIMESTAMP NULL,
+	PRIMARY KEY (gateway_transaction_id, status_code)
+)`, table)
+}
+
+// CreateTable creates the store's table if it doesn't exist
+func (s *SQLCallbackDedupStore) CreateTable(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, CallbackTableSchema(s.table)); err != nil {
+		return fmt.Errorf("cannot create callback table: %w", err)
+	}
+
+	return nil
+}
+
+// Claim implements CallbackDedupStore
+func (s *SQLCallbackDedupStore) Claim(ctx context.Context, key CallbackKey) (CallbackDisposition, error) {
+	if err := key.validate(); err != nil {
+		return CallbackNew, err
+	}
+
+	claims, err := s.claims(ctx, key.GatewayTransactionID)
+	if err != nil {
+		return CallbackNew, err
+	}
+
+	var (
+		current *sqlCallbackClaim
+		seen    []structures.Status
+	)
+	for i := range claims {
+		if claims[i].status == key.Status {
+			current = &claims[i]
+		} else {
+			seen = append(seen, claims[i].status)
+		}
+	}
+
+	now := time.Now().UTC()
+	snonce := base64.StdEncoding.EncodeToString(key.Snonce)
+	if current != nil {
+		if current.processed {
+			return CallbackDuplicate, nil
+		}
+		if now.Sub(current.claimedAt) < claimTimeout(s.ClaimTimeout) {
+			return CallbackInProgress, nil
+		}
+
+		// the claim is abandoned, it's taken over unless another delivery did it meanwhile
+		update := fmt.Sprintf(
+			"UPDATE %s SET snonce = %s, claimed_at = %s WHERE gateway_transaction_id = %s AND status_code = %s "+
+				"AND processed_at IS NULL AND claimed_at = %s",
+			s.table, s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4), s.placeholder(5),
+		)
+		result, err := s.db.ExecContext(ctx, update, snonce, now, key.GatewayTransactionID, int64(key.Status), current.claimedAt)
+		if err != nil {
+			return CallbackNew, fmt.Errorf("cannot claim callback: %w", err)
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			return CallbackInProgress, nil
+		}
+
+		return disposition(key.Status, seen), nil
+	}
+
+	insert := fmt.Sprintf(
+		"INSERT INTO %s (gateway_transaction_id, status_code, snonce, claimed_at) VALUES (%s, %s, %s, %s)",
+		s.table, s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4),
+	)
+	_, insertErr := s.db.ExecContext(ctx, insert, key.GatewayTransactionID, int64(key.Status), snonce, now)
+	if insertErr == nil {
+		return disposition(key.Status, seen), nil
+	}
+
+	// the insert fails on primary key conflict if the same callback was claimed concurrently
+	if claims, err = s.claims(ctx, key.GatewayTransactionID); err == nil {
+		for _, claim := range claims {
+			if claim.status == key.Status && claim.processed {
+				return CallbackDuplicate, nil
+			}
+			if claim.status == key.Status {
+				return CallbackInProgress, nil
+			}
+		}
+	}
+
+	return CallbackNew, fmt.Errorf("cannot claim callback: %w", insertErr)
+}
+
+// Commit implements CallbackDedupStore
+func (s *SQLCallbackDedupStore) Commit(ctx context.Context, key CallbackKey) error {
+	query := fmt.Sprintf(
+		"UPDATE %s SET processed_at = %s WHERE gateway_transaction_id = %s AND status_code = %s AND snonce = %s",
+		s.table, s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4),
+	)
+	if _, err := s.db.ExecContext(ctx, query,
+		time.Now().UTC(), key.GatewayTransactionID, int64(key.Status), base64.StdEncoding.EncodeToString(key.Snonce)); err != nil {
+		return fmt.Errorf("cannot commit callback: %w", err)
+	}
+
+	return nil
+}
+
+// Forget implements CallbackDedupStore
+func (s *SQLCallbackDedupStore) Forget(ctx context.Context, key CallbackKey) error {
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE gateway_transaction_id = %s AND status_code = %s AND snonce = %s AND processed_at IS NULL",
+		s.table, s.placeholder(1), s.placeholder(2), s.placeholder(3),
+	)
+	if _, err := s.db.ExecContext(ctx, query,
+		key.GatewayTransactionID, int64(key.Status), base64.StdEncoding.EncodeToString(key.Snonce)); err != nil {
+		return fmt.Errorf("cannot forget callback: %w", err)
+	}
+
+	return nil
+}
+
+// sqlCallbackClaim is a row of the callback table
+type sqlCallbackClaim struct {
+	status    structures.Status
+	claimedAt time.Time
+	processed bool
+}
+
+func (s *SQLCallbackDedupStore) claims(ctx context.Context, gatewayTransactionID string) ([]sqlCallbackClaim, error) {
+	query := fmt.Sprintf("SELECT status_code, claimed_at, processed_at FROM %s WHERE gateway_transaction_id = %s", s.table, s.placeholder(1))
+
+	rows, err := s.db.QueryContext(ctx, query, gatewayTransactionID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load callbacks: %w", err)
+	}
+	defer rows.Close()
+
+	var result []sqlCallbackClaim
+	for rows.Next() {
+		var (
+			status      int64
+			claim       sqlCallbackClaim
+			processedAt sql.NullTime
+		)
+		if err = rows.Scan(&status, &claim.claimedAt, &processedAt); err != nil {
+			return nil, fmt.Errorf("cannot load callbacks: %w", err)
+		}
+		claim.status = structures.Status(status)
+		claim.processed = processedAt.Valid
+		result = append(result, claim)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("cannot load callbacks: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeCallbackRow struct {
+	id          string
+	status      int64
+	snonce      string
+	claimedAt   time.Time
+	processedAt driver.Value
+}
+
+// newFakeCallbackTable emulates callback table for queries issued by SQLCallbackDedupStore.
+// conflicts makes given number of inserts fail as if the row was inserted concurrently.
+func newFakeCallbackTable(queries *[]string, conflicts *int) fakeQueryFunc {
+	var table []*fakeCallbackRow
+	find := func(id, status, snonce driver.Value) *fakeCallbackRow {
+		for _, existing := range table {
+			if existing.id == id && existing.status == status && (snonce == nil || existing.snonce == snonce) {
+				return existing
+			}
+		}
+		return nil
+	}
+
+	return func(query string, args []driver.Value) ([]string, [][]driver.Value, int64, error) {
+		*queries = append(*queries, query)
+
+		switch {
+		case strings.HasPrefix(query, "CREATE TABLE"):
+			return nil, nil, 0, nil
+		case strings.HasPrefix(query, "INSERT"):
+			row := &fakeCallbackRow{id: args[0].(string), status: args[1].(int64), snonce: args[2].(string), claimedAt: args[3].(time.Time)}
+			if *conflicts > 0 {
+				*conflicts--
+				row.processedAt = row.claimedAt
+				table = append(table, row)
+				return nil, nil, 0, errors.New("duplicate key value violates unique constraint")
+			}
+			if find(row.id, row.status, nil) != nil {
+				return nil, nil, 0, errors.New("duplicate key value violates unique constraint")
+			}
+			table = append(table, row)
+			return nil, nil, 1, nil
+		case strings.HasPrefix(query, "UPDATE") && strings.Contains(query, "SET processed_at"):
+			if row := find(args[1], args[2], args[3]); row != nil {
+				row.processedAt = args[0]
+				return nil, nil, 1, nil
+			}
+			return nil, nil, 0, nil
+		case strings.HasPrefix(query, "UPDATE"):
+			if row := find(args[2], args[3], nil); row != nil && row.processedAt == nil && row.claimedAt.Equal(args[4].(time.Time)) {
+				row.snonce, row.claimedAt = args[0].(string), args[1].(time.Time)
+				return nil, nil, 1, nil
+			}
+			return nil, nil, 0, nil
+		case strings.HasPrefix(query, "DELETE"):
+			for i, existing := range table {
+				if existing.id == args[0] && existing.status == args[1] && existing.snonce == args[2] && existing.processedAt == nil {
+					table = append(table[:i], table[i+1:]...)
+					return nil, nil, 1, nil
+				}
+			}
+			return nil, nil, 0, nil
+		case strings.HasPrefix(query, "SELECT"):
+			var rows [][]driver.Value
+			for _, existing := range table {
+				if existing.id == args[0] {
+					rows = append(rows, []driver.Value{existing.status, existing.claimedAt, existing.processedAt})
+				}
+			}
+			return []string{"status_code", "claimed_at", "processed_at"}, rows, 0, nil
+		}
+
+		return nil, nil, 0, errors.New("unexpected query")
+	}
+}
+
+func TestSQLCallbackDedupStore(t *testing.T) {
+	var (
+		queries   []string
+		conflicts int
+	)
+	db := openFakeDB(newFakeCallbackTable(&queries, &conflicts))
+	defer db.Close()
+
+	dedup, err := NewSQLCallbackDedupStore(db, "", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, dedup.CreateTable(context.Background()))
+	dedup.ClaimTimeout = testClaimTimeout
+
+	testCallbackDedupContract(t, dedup)
+
+	assert.Equal(t, CallbackTableSchema(DefaultCallbackTable), queries[0])
+	assert.Contains(t, queries, "INSERT INTO gateway_callbacks (gateway_transaction_id, status_code, snonce, claimed_at) VALUES (?, ?, ?, ?)")
+	assert.Contains(t, queries, "UPDATE gateway_callbacks SET processed_at = ? WHERE gateway_transaction_id = ? AND status_code = ? AND snonce = ?")
+	assert.Contains(t, queries, "DELETE FROM gateway_callbacks WHERE gateway_transaction_id = ? AND status_code = ? AND snonce = ? AND processed_at IS NULL")
+
+	// another replica processed the callback between the check and the insert
+	conflicts = 1
+	disposition, err := dedup.Claim(context.Background(), CallbackKey{"gw-3", structures.StatusSuccess, []byte("1:a")})
+	assert.NoError(t, err)
+	assert.Equal(t, CallbackDuplicate, disposition)
+}
+
+func TestSQLCallbackDedupStoreErrors(t *testing.T) {
+	_, err := NewSQLCallbackDedupStore(nil, "", nil)
+	assert.EqualError(t, err, "database can't be nil")
+
+	db := openFakeDB(func(query string, args []driver.Value) ([]string, [][]driver.Value, int64, error) {
+		if strings.HasPrefix(query, "SELECT") {
+			return []string{"status_code", "claimed_at", "processed_at"}, nil, 0, nil
+		}
+		return nil, nil, 0, errors.New("connection refused")
+	})
+	defer db.Close()
+
+	_, err = NewSQLCallbackDedupStore(db, "callbacks--", nil)
+	assert.EqualError(t, err, "incorrect table name \"callbacks--\"")
+
+	dedup, err := NewSQLCallbackDedupStore(db, "", DollarPlaceholder)
+	assert.NoError(t, err)
+
+	_, err = dedup.Claim(context.Background(), CallbackKey{"gw-1", structures.StatusSuccess, []byte("1:a")})
+	assert.EqualError(t, err, "cannot claim callback: connection refused")
+
+	err = dedup.Commit(context.Background(), CallbackKey{"gw-1", structures.StatusSuccess, []byte("1:a")})
+	assert.EqualError(t, err, "cannot commit callback: connection refused")
+
+	err = dedup.Forget(context.Background(), CallbackKey{"gw-1", structures.StatusSuccess, []byte("1:a")})
+	assert.EqualError(t, err, "cannot forget callback: connection refused")
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusRank(t *testing.T) {
+	assert.True(t, StatusRank(structures.StatusInit) < StatusRank(structures.StatusCardholderOnSite))
+	assert.True(t, StatusRank(structures.StatusCardholderOnSite) < StatusRank(structures.StatusDmsHoldOK))
+	assert.True(t, StatusRank(structures.StatusDmsHoldOK) < StatusRank(structures.StatusSuccess))
+	assert.True(t, StatusRank(structures.StatusSuccess) < StatusRank(structures.StatusRefundPending))
+	assert.True(t, StatusRank(structures.StatusRefundPending) < StatusRank(structures.StatusRefundSuccess))
+	assert.Equal(t, 0, StatusRank(structures.Status(999)))
+	assert.Equal(t, "out-of-order", CallbackOutOfOrder.String())
+	assert.Equal(t, "in-progress", CallbackInProgress.String())
+}
+
+// testCallbackDedupContract checks behaviour shared by all CallbackDedupStore implementations,
+// the store's claim timeout must be testClaimTimeout
+func testCallbackDedupContract(t *testing.T, dedup CallbackDedupStore) {
+	ctx := context.Background()
+	claim := func(id string, status structures.Status, snonce string) CallbackDisposition {
+		disposition, err := dedup.Claim(ctx, CallbackKey{id, status, []byte(snonce)})
+		assert.NoError(t, err)
+		return disposition
+	}
+
+	examples := []struct {
+		key      CallbackKey
+		expected CallbackDisposition
+	}{
+		{CallbackKey{"gw-1", structures.StatusCardholderOnSite, []byte("1:a")}, CallbackNew},
+		{CallbackKey{"gw-1", structures.StatusSuccess, []byte("2:b")}, CallbackNew},
+		// the same delivery and a re-signed redelivery
+		{CallbackKey{"gw-1", structures.StatusSuccess, []byte("2:b")}, CallbackDuplicate},
+		{CallbackKey{"gw-1", structures.StatusSuccess, []byte("3:c")}, CallbackDuplicate},
+		// intermediate status delivered late
+		{CallbackKey{"gw-1", structures.StatusSent2Bank, []byte("4:d")}, CallbackOutOfOrder},
+		{CallbackKey{"gw-1", structures.StatusSent2Bank, []byte("4:d")}, CallbackDuplicate},
+		{CallbackKey{"gw-1", structures.StatusRefundSuccess, []byte("5:e")}, CallbackNew},
+		{CallbackKey{"gw-1", structures.Status(999), []byte("6:f")}, CallbackNew},
+		// another transaction is independent
+		{CallbackKey{"gw-2", structures.StatusSuccess, []byte("7:g")}, CallbackNew},
+	}
+
+	for _, testCase := range examples {
+		disposition, err := dedup.Claim(ctx, testCase.key)
+		assert.NoError(t, err)
+		assert.Equal(t, testCase.expected, disposition, "%s %d %s", testCase.key.GatewayTransactionID, testCase.key.Status, testCase.key.Snonce)
+		if disposition == CallbackNew || disposition == CallbackOutOfOrder {
+			assert.NoError(t, dedup.Commit(ctx, testCase.key))
+		}
+	}
+
+	// committed callbacks can't be forgotten
+	assert.NoError(t, dedup.Forget(ctx, CallbackKey{"gw-2", structures.StatusSuccess, []byte("7:g")}))
+	assert.Equal(t, CallbackDuplicate, claim("gw-2", structures.StatusSuccess, "7:g"))
+
+	// a claimed callback is in progress until it's committed or forgotten, only its own delivery may forget it
+	assert.Equal(t, CallbackNew, claim("gw-3", structures.StatusSuccess, "1:a"))
+	assert.Equal(t, CallbackInProgress, claim("gw-3", structures.StatusSuccess, "2:b"))
+	assert.NoError(t, dedup.Forget(ctx, CallbackKey{"gw-3", structures.StatusSuccess, []byte("2:b")}))
+	assert.Equal(t, CallbackInProgress, claim("gw-3", structures.StatusSuccess, "2:b"))
+	assert.NoError(t, dedup.Forget(ctx, CallbackKey{"gw-3", structures.StatusSuccess, []byte("1:a")}))
+	assert.Equal(t, CallbackNew, claim("gw-3", structures.StatusSuccess, "2:b"))
+
+	// an abandoned claim is taken over
+	time.Sleep(2 * testClaimTimeout)
+	assert.Equal(t, CallbackNew, claim("gw-3", structures.StatusSuccess, "3:c"))
+	assert.NoError(t, dedup.Forget(ctx, CallbackKey{"gw-3", structures.StatusSuccess, []byte("2:b")}))
+	assert.Equal(t, CallbackInProgress, claim("gw-3", structures.StatusSuccess, "4:d"))
+	assert.NoError(t, dedup.Commit(ctx, CallbackKey{"gw-3", structures.StatusSuccess, []byte("3:c")}))
+	assert.Equal(t, CallbackDuplicate, claim("gw-3", structures.StatusSuccess, "4:d"))
+
+	_, err := dedup.Claim(ctx, CallbackKey{Status: structures.StatusSuccess})
+	assert.EqualError(t, err, "gateway transaction ID can't be empty")
+}
+
+// testClaimTimeout is the claim timeout of stores checked by testCallbackDedupContract
+const testClaimTimeout = 20 * time.Millisecond
+
+func TestMemoryCallbackDedupStore(t *testing.T) {
+	dedup := NewMemoryCallbackDedupStore()
+	dedup.ClaimTimeout = testClaimTimeout
+	testCallbackDedupContract(t, dedup)
+}
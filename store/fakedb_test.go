@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// fakeQueryFunc answers a query of the fake database driver
+type fakeQueryFunc func(query string, args []driver.Value) (columns []string, rows [][]driver.Value, affected int64, err error)
+
+var (
+	fakeDrivers   sync.Map
+	fakeDriverSeq int64
+)
+
+func init() {
+	sql.Register("fake", fakeDriver{})
+}
+
+// openFakeDB opens database which queries are answered by given function
+func openFakeDB(fn fakeQueryFunc) *sql.DB {
+	name := fmt.Sprintf("db%d", atomic.AddInt64(&fakeDriverSeq, 1))
+	fakeDrivers.Store(name, fn)
+
+	db, err := sql.Open("fake", name)
+	if err != nil {
+		panic(err)
+	}
+
+	return db
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fn, ok := fakeDrivers.Load(name)
+	if !ok {
+		return nil, errors.New("unknown fake database")
+	}
+
+	return &fakeConn{fn: fn.(fakeQueryFunc)}, nil
+}
+
+type fakeConn struct {
+	fn fakeQueryFunc
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	_, _, affected, err := c.fn(query, values(args))
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(affected), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	columns, rows, _, err := c.fn(query, values(args))
+	if err != nil {
+		return nil, err
+	}
+
+	return &fakeRows{columns: columns, rows: rows}, nil
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	result := make([]driver.Value, len(args))
+	for i := range args {
+		result[i] = args[i].Value
+	}
+
+	return result
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
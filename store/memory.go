@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryDigestStore is an in-memory DigestStore, its records are lost on restart.
+// Useful for tests and single-process setups.
+type MemoryDigestStore struct {
+	mu         sync.RWMutex
+	byGateway  map[string]DigestRecord
+	byMerchant map[string]string
+}
+
+// NewMemoryDigestStore creates empty MemoryDigestStore
+func NewMemoryDigestStore() *MemoryDigestStore {
+	return &MemoryDigestStore{
+		byGateway:  make(map[string]DigestRecord),
+		byMerchant: make(map[string]string),
+	}
+}
+
+// Save implements DigestStore
+func (s *MemoryDigestStore) Save(ctx context.Context, record DigestRecord) error {
+	if err := record.validate(); err != nil {
+		return err
+	}
+
+	record.Cnonce = append([]byte(nil), record.Cnonce...)
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.byGateway[record.GatewayTransactionID] = record
+	if record.MerchantTransactionID != "" {
+		s.byMerchant[record.MerchantTransactionID] = record.GatewayTransactionID
+	}
+
+	return nil
+}
+
+// FindByGatewayTransactionID implements DigestStore
+func (s *MemoryDigestStore) FindByGatewayTransactionID(ctx context.Context, gatewayTransactionID string) (*DigestRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.byGateway[gatewayTransactionID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &record, nil
+}
+
+// FindByMerchantTransactionID implements DigestStore
+func (s *MemoryDigestStore) FindByMerchantTransactionID(ctx context.Context, merchantTransactionID string) (*DigestRecord, error) {
+	s.mu.RLock()
+	gatewayTransactionID, ok := s.byMerchant[merchantTransactionID]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return s.FindByGatewayTransactionID(ctx, gatewayTransactionID)
+}
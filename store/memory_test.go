@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testDigestStoreContract checks behaviour shared by all DigestStore implementations
+func testDigestStoreContract(t *testing.T, digestStore DigestStore) {
+	ctx := context.Background()
+
+	_, err := digestStore.FindByGatewayTransactionID(ctx, "gw-1")
+	assert.Equal(t, ErrNotFound, err)
+
+	_, err = digestStore.FindByMerchantTransactionID(ctx, "m-1")
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.EqualError(t, digestStore.Save(ctx, DigestRecord{URI: "/v3.0/sms", Cnonce: []byte("1:a")}),
+		"gateway transaction ID can't be empty")
+	assert.EqualError(t, digestStore.Save(ctx, DigestRecord{GatewayTransactionID: "gw-1"}),
+		"digest URI and cnonce can't be empty")
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	first := DigestRecord{GatewayTransactionID: "gw-1", MerchantTransactionID: "m-1", URI: "/v3.0/sms", Cnonce: []byte("1:a"), CreatedAt: createdAt}
+	assert.NoError(t, digestStore.Save(ctx, first))
+
+	found, err := digestStore.FindByGatewayTransactionID(ctx, "gw-1")
+	assert.NoError(t, err)
+	assert.Equal(t, first, *found)
+
+	found, err = digestStore.FindByMerchantTransactionID(ctx, "m-1")
+	assert.NoError(t, err)
+	assert.Equal(t, first, *found)
+
+	// a retry of the same merchant transaction got a new gateway transaction
+	second := DigestRecord{GatewayTransactionID: "gw-2", MerchantTransactionID: "m-1", URI: "/v3.0/sms", Cnonce: []byte("2:b"), CreatedAt: createdAt.Add(time.Minute)}
+	assert.NoError(t, digestStore.Save(ctx, second))
+
+	found, err = digestStore.FindByMerchantTransactionID(ctx, "m-1")
+	assert.NoError(t, err)
+	assert.Equal(t, second, *found)
+
+	replaced := first
+	replaced.Cnonce = []byte("3:c")
+	assert.NoError(t, digestStore.Save(ctx, replaced))
+	// saving the same record again is not an error
+	assert.NoError(t, digestStore.Save(ctx, replaced))
+
+	found, err = digestStore.FindByGatewayTransactionID(ctx, "gw-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("3:c"), found.Cnonce)
+}
+
+func TestMemoryDigestStore(t *testing.T) {
+	testDigestStoreContract(t, NewMemoryDigestStore())
+}
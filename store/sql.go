@@ -0,0 +1,171 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// DefaultDigestTable is the table name used by SQLDigestStore by default
+const DefaultDigestTable = "gateway_request_digests"
+
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Placeholder returns n-th (starting from 1) query parameter placeholder of a database driver
+type Placeholder func(n int) string
+
+// QuestionPlaceholder is used by MySQL and SQLite drivers
+func QuestionPlaceholder(n int) string {
+	return "?"
+}
+
+// DollarPlaceholder is used by PostgreSQL drivers
+func DollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// SQLDigestStore is a DigestStore backed by database/sql.
+// Records survive restarts and are shared between replicas using the same database.
+type SQLDigestStore struct {
+	db          *sql.DB
+	table       string
+	placeholder Placeholder
+}
+
+// NewSQLDigestStore creates SQLDigestStore using given table (DefaultDigestTable if empty)
+// and placeholder style (QuestionPlaceholder if nil). Use CreateTable or DigestTableSchema to create the table.
+func NewSQLDigestStore(db *sql.DB, table string, placeholder Placeholder) (*SQLDigestStore, error) {
+	if db == nil {
+		return nil, errors.New("database can't be nil")
+	}
+
+	if table == "" {
+		table = DefaultDigestTable
+	}
+	if !tableNamePattern.MatchString(table) {
+		return nil, fmt.Errorf("incorrect table name %q", table)
+	}
+
+	if placeholder == nil {
+		placeholder = QuestionPlaceholder
+	}
+
+	return &SQLDigestStore{db: db, table: table, placeholder: placeholder}, nil
+}
+
+// DigestTableSchema returns CREATE TABLE statement for SQLDigestStore table
+func DigestTableSchema(table string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	gateway_transaction_id VARCHAR(64) NOT NULL PRIMARY KEY,
+	merchant_transaction_id VARCHAR(255) NOT NULL,
+	uri VARCHAR(2048) NOT NULL,
+	cnonce VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP NOT NULL
+)`, table)
+}
+
+// CreateTable creates the store's table if it doesn't exist
+func (s *SQLDigestStore) CreateTable(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, DigestTableSchema(s.table)); err != nil {
+		return fmt.Errorf("cannot create digest table: %w", err)
+	}
+
+	return nil
+}
+
+// Save implements DigestStore
+func (s *SQLDigestStore) Save(ctx context.Context, record DigestRecord) error {
+	if err := record.validate(); err != nil {
+		return err
+	}
+
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	cnonce := base64.StdEncoding.EncodeToString(record.Cnonce)
+
+	insert := fmt.Sprintf(
+		"INSERT INTO %s (gateway_transaction_id, merchant_transaction_id, uri, cnonce, created_at) VALUES (%s, %s, %s, %s, %s)",
+		s.table, s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4), s.placeholder(5),
+	)
+	_, insertErr := s.db.ExecContext(ctx, insert,
+		record.GatewayTransactionID, record.MerchantTransactionID, record.URI, cnonce, record.CreatedAt.UTC())
+	if insertErr == nil {
+		return nil
+	}
+
+	// the insert fails on primary key conflict if the digest was saved before (possibly by another replica)
+	update := fmt.Sprintf(
+		"UPDATE %s SET merchant_transaction_id = %s, uri = %s, cnonce = %s, created_at = %s WHERE gateway_transaction_id = %s",
+		s.table, s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4), s.placeholder(5),
+	)
+	result, err := s.db.ExecContext(ctx, update,
+		record.MerchantTransactionID, record.URI, cnonce, record.CreatedAt.UTC(), record.GatewayTransactionID)
+	if err != nil {
+		return fmt.Errorf("cannot save request digest: %w", err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected > 0 {
+		return nil
+	}
+
+	// MySQL doesn't count rows the update didn't change, so the row's existence is checked
+	if _, err = s.FindByGatewayTransactionID(ctx, record.GatewayTransactionID); err == nil {
+		return nil
+	}
+
+	return fmt.Errorf("cannot save request digest: %w", insertErr)
+}
+
+// FindByGatewayTransactionID implements DigestStore
+func (s *SQLDigestStore) FindByGatewayTransactionID(ctx context.Context, gatewayTransactionID string) (*DigestRecord, error) {
+	return s.find(ctx, "gateway_transaction_id", gatewayTransactionID)
+}
+
+// FindByMerchantTransactionID implements DigestStore
+func (s *SQLDigestStore) FindByMerchantTransactionID(ctx context.Context, merchantTransactionID string) (*DigestRecord, error) {
+	if merchantTransactionID == "" {
+		return nil, ErrNotFound
+	}
+
+	return s.find(ctx, "merchant_transaction_id", merchantTransactionID)
+}
+
+func (s *SQLDigestStore) find(ctx context.Context, column, value string) (*DigestRecord, error) {
+	query := fmt.Sprintf(
+		"SELECT gateway_transaction_id, merchant_transaction_id, uri, cnonce, created_at FROM %s WHERE %s = %s ORDER BY created_at DESC",
+		s.table, column, s.placeholder(1),
+	)
+
+	rows, err := s.db.QueryContext(ctx, query, value)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load request digest: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, fmt.Errorf("cannot load request digest: %w", err)
+		}
+		return nil, ErrNotFound
+	}
+
+	var (
+		record DigestRecord
+		cnonce string
+	)
+	if err = rows.Scan(&record.GatewayTransactionID, &record.MerchantTransactionID, &record.URI, &cnonce, &record.CreatedAt); err != nil {
+		return nil, fmt.Errorf("cannot load request digest: %w", err)
+	}
+
+	if record.Cnonce, err = base64.StdEncoding.DecodeString(cnonce); err != nil {
+		return nil, fmt.Errorf("cannot load request digest: corrupted cnonce: %w", err)
+	}
+
+	return &record, nil
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakeDigestTable emulates digest table for queries issued by SQLDigestStore,
+// updates not changing the row aren't counted as affected like MySQL does
+func newFakeDigestTable(queries *[]string) fakeQueryFunc {
+	table := make(map[string][]driver.Value)
+
+	return func(query string, args []driver.Value) ([]string, [][]driver.Value, int64, error) {
+		*queries = append(*queries, query)
+
+		switch {
+		case strings.HasPrefix(query, "CREATE TABLE"):
+			return nil, nil, 0, nil
+		case strings.HasPrefix(query, "UPDATE"):
+			id := args[4].(string)
+			row, ok := table[id]
+			if !ok {
+				return nil, nil, 0, nil
+			}
+			updated := []driver.Value{id, args[0], args[1], args[2], args[3]}
+			if fmt.Sprint(row) == fmt.Sprint(updated) {
+				return nil, nil, 0, nil
+			}
+			table[id] = updated
+			return nil, nil, 1, nil
+		case strings.HasPrefix(query, "INSERT"):
+			if _, ok := table[args[0].(string)]; ok {
+				return nil, nil, 0, errors.New("duplicate key")
+			}
+			table[args[0].(string)] = args
+			return nil, nil, 1, nil
+		case strings.HasPrefix(query, "SELECT"):
+			column := 0
+			if strings.Contains(query, "WHERE merchant_transaction_id") {
+				column = 1
+			}
+
+			var rows [][]driver.Value
+			for _, row := range table {
+				if row[column] == args[0] {
+					rows = append(rows, row)
+				}
+			}
+			sort.Slice(rows, func(i, j int) bool {
+				return rows[i][4].(time.Time).After(rows[j][4].(time.Time))
+			})
+
+			return []string{"gateway_transaction_id", "merchant_transaction_id", "uri", "cnonce", "created_at"}, rows, 0, nil
+		}
+
+		return nil, nil, 0, errors.New("unexpected query")
+	}
+}
+
+func TestSQLDigestStore(t *testing.T) {
+	var queries []string
+	db := openFakeDB(newFakeDigestTable(&queries))
+	defer db.Close()
+
+	digestStore, err := NewSQLDigestStore(db, "", DollarPlaceholder)
+	assert.NoError(t, err)
+	assert.NoError(t, digestStore.CreateTable(context.Background()))
+
+	testDigestStoreContract(t, digestStore)
+
+	assert.Equal(t, DigestTableSchema(DefaultDigestTable), queries[0])
+	assert.Contains(t, queries, "INSERT INTO gateway_request_digests (gateway_transaction_id, merchant_transaction_id, uri, cnonce, created_at) "+
+		"VALUES ($1, $2, $3, $4, $5)")
+	assert.Contains(t, queries, "UPDATE gateway_request_digests SET merchant_transaction_id = $1, uri = $2, cnonce = $3, created_at = $4 "+
+		"WHERE gateway_transaction_id = $5")
+	assert.Contains(t, queries, "SELECT gateway_transaction_id, merchant_transaction_id, uri, cnonce, created_at "+
+		"FROM gateway_request_digests WHERE merchant_transaction_id = $1 ORDER BY created_at DESC")
+}
+
+func TestSQLDigestStoreErrors(t *testing.T) {
+	_, err := NewSQLDigestStore(nil, "", nil)
+	assert.EqualError(t, err, "database can't be nil")
+
+	// the insert conflicts with an existing row, the update fails
+	db := openFakeDB(func(query string, args []driver.Value) ([]string, [][]driver.Value, int64, error) {
+		if strings.HasPrefix(query, "INSERT") {
+			return nil, nil, 0, errors.New("duplicate key")
+		}
+		return nil, nil, 0, errors.New("connection refused")
+	})
+	defer db.Close()
+
+	_, err = NewSQLDigestStore(db, "digests; DROP TABLE users", nil)
+	assert.EqualError(t, err, "incorrect table name \"digests; DROP TABLE users\"")
+
+	digestStore, err := NewSQLDigestStore(db, "payments.digests", nil)
+	assert.NoError(t, err)
+
+	err = digestStore.Save(context.Background(), DigestRecord{GatewayTransactionID: "gw-1", URI: "/v3.0/sms", Cnonce: []byte("1:a")})
+	assert.EqualError(t, err, "cannot save request digest: connection refused")
+
+	_, err = digestStore.FindByGatewayTransactionID(context.Background(), "gw-1")
+	assert.EqualError(t, err, "cannot load request digest: connection refused")
+}
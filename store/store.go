@@ -0,0 +1,47 @@
+// Package store persists request digests of Transact Pro Gateway transactions,
+// so callbacks can be verified against the original request's URI and cnonce later,
+// in another process or on another replica.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when there is no digest for given transaction ID
+var ErrNotFound = errors.New("request digest not found")
+
+type (
+	// DigestRecord holds the original request's digest values of a transaction
+	DigestRecord struct {
+		GatewayTransactionID  string
+		MerchantTransactionID string
+		URI                   string
+		Cnonce                []byte
+		CreatedAt             time.Time
+	}
+
+	// DigestStore persists request digests keyed by gateway and merchant transaction IDs.
+	// Implementations must be safe for concurrent use.
+	DigestStore interface {
+		// Save stores the record, replacing a record with the same gateway transaction ID
+		Save(ctx context.Context, record DigestRecord) error
+		// FindByGatewayTransactionID returns the record or ErrNotFound
+		FindByGatewayTransactionID(ctx context.Context, gatewayTransactionID string) (*DigestRecord, error)
+		// FindByMerchantTransactionID returns the latest record for merchant transaction ID or ErrNotFound
+		FindByMerchantTransactionID(ctx context.Context, merchantTransactionID string) (*DigestRecord, error)
+	}
+)
+
+func (r *DigestRecord) validate() error {
+	if r.GatewayTransactionID == "" {
+		return errors.New("gateway transaction ID can't be empty")
+	}
+
+	if r.URI == "" || len(r.Cnonce) == 0 {
+		return errors.New("digest URI and cnonce can't be empty")
+	}
+
+	return nil
+}
@@ -5,11 +5,13 @@ import (
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"hash"
+	"io"
 	"strconv"
 	"strings"
 	"time"
@@ -46,6 +48,18 @@ type (
 		Snonce         []byte
 		OriginalURI    string
 		OriginalCnonce []byte
+		// AllowedAlgorithms limits accepted algorithms on verification, any supported algorithm is accepted if empty
+		AllowedAlgorithms []Algorithm
+	}
+
+	// DigestOption customizes RequestDigest creation
+	DigestOption func(o *digestOptions)
+
+	digestOptions struct {
+		algorithm Algorithm
+		qop       QOP
+		now       func() time.Time
+		random    io.Reader
 	}
 )
 
@@ -87,14 +101,25 @@ func (o QOP) String() string {
 const (
 	AlgorithmUnknown Algorithm = iota
 	AlgorithmSHA256
+	AlgorithmSHA384
+	AlgorithmSHA512
 )
 
 var algorithm2string = map[Algorithm]string{
 	AlgorithmSHA256: "SHA-256",
+	AlgorithmSHA384: "SHA-384",
+	AlgorithmSHA512: "SHA-512",
 }
 
 var string2algorithm = map[string]Algorithm{
 	"SHA-256": AlgorithmSHA256,
+	"SHA-384": AlgorithmSHA384,
+	"SHA-512": AlgorithmSHA512,
+}
+
+// SupportedAlgorithms returns all algorithms that may be used for digest creation and verification
+func SupportedAlgorithms() []Algorithm {
+	return []Algorithm{AlgorithmSHA256, AlgorithmSHA384, AlgorithmSHA512}
 }
 
 // NewAlgorithm creates Algorithm value from raw string or returns an error if input is unknown
@@ -119,6 +144,10 @@ func (o Algorithm) Hash() (hashCreator func() hash.Hash, err error) {
 	switch o {
 	case AlgorithmSHA256:
 		hashCreator = sha256.New
+	case AlgorithmSHA384:
+		hashCreator = sha512.New384
+	case AlgorithmSHA512:
+		hashCreator = sha512.New
 	default:
 		err = fmt.Errorf("unsupported algorithm %s", o)
 	}
@@ -126,31 +155,110 @@ func (o Algorithm) Hash() (hashCreator func() hash.Hash, err error) {
 	return
 }
 
-func calcNonce() (nonce []byte, err error) {
+func calcNonce(now time.Time, random io.Reader) (nonce []byte, err error) {
 	nonceRand := make([]byte, 32)
-	if _, err := rand.Read(nonceRand); err != nil {
-		return nil, fmt.Errorf("cannot create cnonce: %s", err)
+	if _, err := io.ReadFull(random, nonceRand); err != nil {
+		return nil, fmt.Errorf("cannot create nonce: %s", err)
 	}
 
-	buf := bytes.NewBuffer([]byte(fmt.Sprintf("%d:", time.Now().UTC().Unix())))
+	buf := bytes.NewBuffer([]byte(fmt.Sprintf("%d:", now.UTC().Unix())))
 	buf.Write(nonceRand)
 	nonce = buf.Bytes()
 
 	return
 }
 
+// sign calculates digest response value, snonce is omitted for requests
+func (o *digest) sign(secret string, snonce []byte) (string, error) {
+	hashFunc, err := o.Algorithm.Hash()
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(hashFunc, []byte(secret))
+	mac.Write([]byte(o.Username))
+	mac.Write(o.Cnonce)
+	mac.Write(snonce)
+	mac.Write([]byte(o.QOP.String()))
+	mac.Write([]byte(o.URI))
+	if o.QOP == QopAuthInt {
+		mac.Write(o.Body)
+	}
+
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func newDigestOptions(opts []DigestOption) (options digestOptions, err error) {
+	options = digestOptions{algorithm: AlgorithmSHA256, qop: QopAuthInt}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.now == nil {
+		options.now = time.Now
+	}
+	if options.random == nil {
+		options.random = rand.Reader
+	}
+
+	if _, err = options.algorithm.Hash(); err != nil {
+		return
+	}
+
+	if _, ok := qop2string[options.qop]; !ok {
+		err = fmt.Errorf("unsupported QOP %s", options.qop)
+	}
+
+	return
+}
+
+// WithAlgorithm sets digest algorithm, AlgorithmSHA256 is used by default
+func WithAlgorithm(algorithm Algorithm) DigestOption {
+	return func(o *digestOptions) {
+		o.algorithm = algorithm
+	}
+}
+
+// WithQOP sets digest QOP, QopAuthInt is used by default.
+// QopAuth doesn't protect request body, so it should be used only for requests without body.
+func WithQOP(qop QOP) DigestOption {
+	return func(o *digestOptions) {
+		o.qop = qop
+	}
+}
+
+// WithClock sets the source of nonce timestamps, time.Now is used by default (or if nil)
+func WithClock(now func() time.Time) DigestOption {
+	return func(o *digestOptions) {
+		o.now = now
+	}
+}
+
+// WithRandom sets the source of nonce random bytes, crypto/rand.Reader is used by default (or if nil).
+// Predictable sources make digests reproducible, so they should be used only in tests.
+func WithRandom(random io.Reader) DigestOption {
+	return func(o *digestOptions) {
+		o.random = random
+	}
+}
+
 // NewRequestDigest creates new RequestDigest structure
-func NewRequestDigest(ObjectGUID, secret, uri string, body []byte) (result *RequestDigest, err error) {
+func NewRequestDigest(ObjectGUID, secret, uri string, body []byte, opts ...DigestOption) (result *RequestDigest, err error) {
+	var options digestOptions
+	if options, err = newDigestOptions(opts); err != nil {
+		return
+	}
+
 	var cnonce []byte
-	if cnonce, err = calcNonce(); err != nil {
+	if cnonce, err = calcNonce(options.now(), options.random); err != nil {
 		return
 	}
 
 	result = &RequestDigest{
 		digest: digest{
 			Username:  ObjectGUID,
-			Algorithm: AlgorithmSHA256,
-			QOP:       QopAuthInt,
+			Algorithm: options.algorithm,
+			QOP:       options.qop,
 			URI:       uri,
 			Cnonce:    cnonce,
 			Body:      body,
@@ -162,122 +270,165 @@ func NewRequestDigest(ObjectGUID, secret, uri string, body []byte) (result *Requ
 
 // CreateHeader creates  a string for Authorization header for a Gateway request
 func (o *RequestDigest) CreateHeader() (digest string, err error) {
-	var hashFunc func() hash.Hash
-	if hashFunc, err = o.Algorithm.Hash(); err != nil {
+	if o.Response, err = o.sign(o.Secret, nil); err != nil {
 		return
 	}
 
-	mac := hmac.New(hashFunc, []byte(o.Secret))
-	mac.Write([]byte(o.Username))
-	mac.Write(o.Cnonce)
-	mac.Write([]byte(o.QOP.String()))
-	mac.Write([]byte(o.URI))
-	if o.QOP == QopAuthInt {
-		mac.Write(o.Body)
+	credentials := &DigestCredentials{Scheme: DigestScheme}
+	credentials.Set("username", o.Username, false)
+	credentials.Set("uri", o.URI, true)
+	credentials.Set("algorithm", o.Algorithm.String(), false)
+	credentials.Set("cnonce", base64.StdEncoding.EncodeToString(o.Cnonce), true)
+	credentials.Set("qop", o.QOP.String(), false)
+	credentials.Set("response", o.Response, true)
+	digest = credentials.String()
+
+	return
+}
+
+// NewResponseDigestForRequest creates ResponseDigest for signing a response to the request with given URI and cnonce.
+// Snonce is generated using current time (see WithClock). Algorithm and QOP may be set with options, they should match the request's ones.
+// The original URI and cnonce are set, so the result may also be verified by the request sender.
+func NewResponseDigestForRequest(objectGUID, uri string, cnonce, body []byte, opts ...DigestOption) (result *ResponseDigest, err error) {
+	var options digestOptions
+	if options, err = newDigestOptions(opts); err != nil {
+		return
+	}
+
+	now := options.now()
+	var snonce []byte
+	if snonce, err = calcNonce(now, options.random); err != nil {
+		return
+	}
+
+	result = &ResponseDigest{
+		digest: digest{
+			Username:  objectGUID,
+			Algorithm: options.algorithm,
+			QOP:       options.qop,
+			URI:       uri,
+			Cnonce:    cnonce,
+			Body:      body,
+		},
+		Timestamp:      int(now.UTC().Unix()),
+		Snonce:         snonce,
+		OriginalURI:    uri,
+		OriginalCnonce: cnonce,
+	}
+	return
+}
+
+// CreateHeader signs the response with given secret and creates a string for response's Authorization header
+func (o *ResponseDigest) CreateHeader(secret string) (digest string, err error) {
+	if o.Response, err = o.sign(secret, o.Snonce); err != nil {
+		return
 	}
-	o.Response = hex.EncodeToString(mac.Sum(nil))
 
-	digest = fmt.Sprintf(
-		"Digest username=%s, uri=\"%s\", algorithm=%s, cnonce=\"%s\", qop=%s, response=\"%s\"",
-		o.Username,
-		o.URI,
-		o.Algorithm,
-		base64.StdEncoding.EncodeToString(o.Cnonce),
-		o.QOP,
-		o.Response,
-	)
+	credentials := &DigestCredentials{Scheme: DigestScheme}
+	credentials.Set("username", o.Username, false)
+	credentials.Set("uri", o.URI, true)
+	credentials.Set("algorithm", o.Algorithm.String(), false)
+	credentials.Set("cnonce", base64.StdEncoding.EncodeToString(o.Cnonce), true)
+	credentials.Set("snonce", base64.StdEncoding.EncodeToString(o.Snonce), true)
+	credentials.Set("qop", o.QOP.String(), false)
+	credentials.Set("response", o.Response, true)
+	digest = credentials.String()
 
 	return
 }
 
-// NewResponseDigest parse Authorization header's content and fill ResponseDigest structure
+// responseDigestParams lists parameters required in response digest, in the order they are checked
+var responseDigestParams = []string{"username", "uri", "response", "algorithm", "qop", "cnonce", "snonce"}
+
+// NewResponseDigest parse Authorization header's content and fill ResponseDigest structure.
+// The header is parsed in DigestParseLenient mode.
 func NewResponseDigest(authorizationHeader string) (result *ResponseDigest, err error) {
-	if len(authorizationHeader) == 0 {
+	return NewResponseDigestWithMode(authorizationHeader, DigestParseLenient)
+}
+
+// NewResponseDigestWithMode parse Authorization header's content using given parse mode and fill ResponseDigest structure
+func NewResponseDigestWithMode(authorizationHeader string, mode DigestParseMode) (result *ResponseDigest, err error) {
+	if len(strings.TrimSpace(authorizationHeader)) == 0 {
 		err = errors.New("authorization header is missing")
 		return
 	}
 
-	values := map[string]string{
-		"username":  "",
-		"uri":       "",
-		"response":  "",
-		"algorithm": "",
-		"qop":       "",
-		"cnonce":    "",
-		"snonce":    "",
-	}
-
-	elements := strings.Split(
-		strings.TrimSpace(
-			strings.ReplaceAll(
-				strings.ReplaceAll(authorizationHeader, "\r", ""),
-				"\n", "",
-			),
-		),
-		",",
-	)
-	for i := range elements {
-		keyValue := strings.TrimSpace(elements[i])
-		delimiterPos := strings.Index(keyValue, "=")
-		if delimiterPos != -1 {
-			key := keyValue[:delimiterPos]
-			if i == 0 {
-				key = strings.Replace(strings.ToLower(key), "digest ", "", 1)
-			}
+	var credentials *DigestCredentials
+	if credentials, err = ParseDigestHeader(authorizationHeader, mode); err != nil {
+		err = fmt.Errorf("digest mismatch: format error: %w", err)
+		return
+	}
 
-			if _, ok := values[key]; ok {
-				value := keyValue[delimiterPos+1:]
-				values[key] = strings.TrimSpace(strings.Trim(value, "\""))
+	result = &ResponseDigest{}
+	for _, key := range responseDigestParams {
+		value, _ := credentials.Get(key)
+		if key == "snonce" {
+			if result.Snonce, result.Timestamp, err = parseNonce(key, value); err != nil {
+				return
 			}
+			continue
 		}
-	}
 
-	result = &ResponseDigest{}
-	for key, value := range values {
-		if len(value) == 0 {
-			err = fmt.Errorf("digest mismatch: empty value for %s", key)
+		if err = result.digest.set(key, value); err != nil {
 			return
 		}
+	}
 
-		switch key {
-		case "username":
-			result.Username = value
-		case "uri":
-			result.URI = value
-		case "response":
-			result.Response = value
-		case "algorithm":
-			if result.Algorithm, err = NewAlgorithm(value); err != nil {
-				err = fmt.Errorf("digest mismatch: format error: %s", err)
-				return
-			}
-		case "qop":
-			if result.QOP, err = NewQOP(value); err != nil {
-				err = fmt.Errorf("digest mismatch: format error: %s", err)
-				return
-			}
-		case "cnonce":
-			if result.Cnonce, err = base64.StdEncoding.DecodeString(value); err != nil {
-				err = fmt.Errorf("digest mismatch: corrupted value for cnonce (%s)", err)
-				return
-			}
-		case "snonce":
-			if result.Snonce, err = base64.StdEncoding.DecodeString(value); err != nil {
-				err = fmt.Errorf("digest mismatch: corrupted value for snonce (%s)", err)
-				return
-			}
+	return
+}
 
-			timestampDelimiterPos := bytes.Index(result.Snonce, []byte(":"))
-			if timestampDelimiterPos == -1 {
-				err = errors.New("digest mismatch: corrupted value for snonce (missing timestamp)")
-				return
-			}
+// set parses and sets the value of given digest parameter
+func (o *digest) set(key, value string) (err error) {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return fmt.Errorf("digest mismatch: empty value for %s", key)
+	}
 
-			if result.Timestamp, err = strconv.Atoi(string(result.Snonce[:timestampDelimiterPos])); err != nil {
-				err = fmt.Errorf("digest mismatch: corrupted value for snonce (unexpected timestamp value: %s)", err)
-				return
-			}
+	switch key {
+	case "username":
+		o.Username = value
+	case "uri":
+		o.URI = value
+	case "response":
+		o.Response = value
+	case "algorithm":
+		if o.Algorithm, err = NewAlgorithm(value); err != nil {
+			err = fmt.Errorf("digest mismatch: format error: %s", err)
 		}
+	case "qop":
+		if o.QOP, err = NewQOP(value); err != nil {
+			err = fmt.Errorf("digest mismatch: format error: %s", err)
+		}
+	case "cnonce":
+		if o.Cnonce, err = base64.StdEncoding.DecodeString(value); err != nil {
+			err = fmt.Errorf("digest mismatch: corrupted value for cnonce (%s)", err)
+		}
+	}
+
+	return
+}
+
+// parseNonce decodes base64 nonce value and extracts its timestamp
+func parseNonce(key, value string) (nonce []byte, timestamp int, err error) {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		err = fmt.Errorf("digest mismatch: empty value for %s", key)
+		return
+	}
+
+	if nonce, err = base64.StdEncoding.DecodeString(value); err != nil {
+		err = fmt.Errorf("digest mismatch: corrupted value for %s (%s)", key, err)
+		return
+	}
+
+	timestampDelimiterPos := bytes.Index(nonce, []byte(":"))
+	if timestampDelimiterPos == -1 {
+		err = fmt.Errorf("digest mismatch: corrupted value for %s (missing timestamp)", key)
+		return
+	}
+
+	if timestamp, err = strconv.Atoi(string(nonce[:timestampDelimiterPos])); err != nil {
+		err = fmt.Errorf("digest mismatch: corrupted value for %s (unexpected timestamp value: %s)", key, err)
 	}
 
 	return
@@ -298,21 +449,14 @@ func (o *ResponseDigest) Verify(objectGUID, secret string) (err error) {
 		return errors.New("digest mismatch: cnonce mismatch")
 	}
 
-	var hashFunc func() hash.Hash
-	if hashFunc, err = o.Algorithm.Hash(); err != nil {
-		return
+	if !algorithmAllowed(o.Algorithm, o.AllowedAlgorithms) {
+		return fmt.Errorf("digest mismatch: algorithm %s is not allowed", o.Algorithm)
 	}
 
-	mac := hmac.New(hashFunc, []byte(secret))
-	mac.Write([]byte(o.Username))
-	mac.Write(o.Cnonce)
-	mac.Write(o.Snonce)
-	mac.Write([]byte(o.QOP.String()))
-	mac.Write([]byte(o.URI))
-	if o.QOP == QopAuthInt {
-		mac.Write(o.Body)
+	var expectedDigest string
+	if expectedDigest, err = o.sign(secret, o.Snonce); err != nil {
+		return
 	}
-	expectedDigest := hex.EncodeToString(mac.Sum(nil))
 
 	if !hmac.Equal([]byte(o.Response), []byte(expectedDigest)) {
 		return errors.New("digest mismatch")
@@ -320,3 +464,32 @@ func (o *ResponseDigest) Verify(objectGUID, secret string) (err error) {
 
 	return nil
 }
+
+// algorithmAllowed checks algorithm against allow-list, empty list allows any algorithm
+func algorithmAllowed(algorithm Algorithm, allowedAlgorithms []Algorithm) bool {
+	if len(allowedAlgorithms) == 0 {
+		return true
+	}
+
+	for _, allowed := range allowedAlgorithms {
+		if allowed == algorithm {
+			return true
+		}
+	}
+
+	return false
+}
+
+// VerifyFresh verifies the digest like Verify does and then checks its snonce with given guard,
+// rejecting stale and replayed responses. Guard check is skipped if guard is nil.
+func (o *ResponseDigest) VerifyFresh(objectGUID, secret string, guard *ReplayGuard) error {
+	if err := o.Verify(objectGUID, secret); err != nil {
+		return err
+	}
+
+	if guard == nil {
+		return nil
+	}
+
+	return guard.Check(o.Timestamp, o.Snonce)
+}
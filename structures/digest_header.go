@@ -0,0 +1,342 @@
+package structures
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DigestScheme is the authentication scheme name of digest Authorization header
+const DigestScheme = "Digest"
+
+// DigestParseMode selects how strictly digest header grammar is enforced
+type DigestParseMode uint
+
+// Digest header parse modes
+const (
+	// DigestParseLenient accepts unknown and duplicate parameters (the last value wins),
+	// a missing scheme and unquoted values with non-token characters (like base64 padding)
+	DigestParseLenient DigestParseMode = iota
+	// DigestParseStrict follows RFC 7616 grammar and rejects unknown and duplicate parameters
+	DigestParseStrict
+)
+
+// digestParams contains parameters defined by RFC 7616 and the Gateway's snonce
+var digestParams = map[string]bool{
+	"username":  true,
+	"username*": true,
+	"realm":     true,
+	"domain":    true,
+	"nonce":     true,
+	"opaque":    true,
+	"stale":     true,
+	"uri":       true,
+	"response":  true,
+	"algorithm": true,
+	"cnonce":    true,
+	"snonce":    true,
+	"qop":       true,
+	"nc":        true,
+	"userhash":  true,
+	"charset":   true,
+}
+
+type (
+	// DigestParam is a single name=value pair of digest header
+	DigestParam struct {
+		Name  string
+		Value string
+		// Quoted makes the serializer emit the value as a quoted-string
+		// Values which are not valid tokens are always quoted
+		Quoted bool
+	}
+
+	// DigestCredentials is a tokenized digest challenge or credentials (Authorization header value)
+	DigestCredentials struct {
+		Scheme string
+		Params []DigestParam
+	}
+
+	// DigestParseError describes digest header syntax error
+	DigestParseError struct {
+		// Position is a byte offset in the header value
+		Position int
+		Reason   string
+	}
+)
+
+func (e *DigestParseError) Error() string {
+	return fmt.Sprintf("malformed digest header at position %d: %s", e.Position, e.Reason)
+}
+
+// Get returns the value of parameter with given name (case-insensitive)
+func (c *DigestCredentials) Get(name string) (string, bool) {
+	name = strings.ToLower(name)
+	for i := len(c.Params) - 1; i >= 0; i-- {
+		if c.Params[i].Name == name {
+			return c.Params[i].Value, true
+		}
+	}
+
+	return "", false
+}
+
+// Set adds parameter or replaces the value of existing one
+func (c *DigestCredentials) Set(name, value string, quoted bool) {
+	name = strings.ToLower(name)
+	for i := range c.Params {
+		if c.Params[i].Name == name {
+			c.Params[i].Value = value
+			c.Params[i].Quoted = quoted
+			return
+		}
+	}
+
+	c.Params = append(c.Params, DigestParam{Name: name, Value: value, Quoted: quoted})
+}
+
+// String serializes credentials to header value, escaping quoted values
+func (c *DigestCredentials) String() string {
+	var sb strings.Builder
+
+	scheme := c.Scheme
+	if scheme == "" {
+		scheme = DigestScheme
+	}
+	sb.WriteString(scheme)
+
+	for i, param := range c.Params {
+		if i == 0 {
+			sb.WriteByte(' ')
+		} else {
+			sb.WriteString(", ")
+		}
+
+		sb.WriteString(param.Name)
+		sb.WriteByte('=')
+		if param.Quoted || !isToken(param.Value) {
+			writeQuoted(&sb, param.Value)
+		} else {
+			sb.WriteString(param.Value)
+		}
+	}
+
+	return sb.String()
+}
+
+func writeQuoted(sb *strings.Builder, value string) {
+	sb.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		if value[i] == '"' || value[i] == '\\' {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(value[i])
+	}
+	sb.WriteByte('"')
+}
+
+// ParseDigestHeader tokenizes digest challenge or credentials header value.
+// Parameter names are lower-cased, quoted values are unescaped.
+// Returned error is always *DigestParseError.
+func ParseDigestHeader(header string, mode DigestParseMode) (*DigestCredentials, error) {
+	p := &digestParser{input: header, strict: mode == DigestParseStrict}
+	return p.parse()
+}
+
+type digestParser struct {
+	input  string
+	pos    int
+	strict bool
+}
+
+func (p *digestParser) fail(pos int, format string, args ...interface{}) error {
+	return &DigestParseError{Position: pos, Reason: fmt.Sprintf(format, args...)}
+}
+
+func (p *digestParser) parse() (*DigestCredentials, error) {
+	result := &DigestCredentials{}
+
+	p.skipSpace()
+	schemeStart := p.pos
+	scheme := p.readToken()
+	if scheme == "" {
+		return nil, p.fail(p.pos, "expected auth scheme")
+	}
+
+	afterScheme := p.pos
+	p.skipSpace()
+	if p.peek() == '=' {
+		if p.strict {
+			return nil, p.fail(schemeStart, "expected auth scheme")
+		}
+		// lenient mode: no scheme, the token is the first parameter name
+		p.pos = schemeStart
+	} else {
+		if !strings.EqualFold(scheme, DigestScheme) {
+			return nil, p.fail(schemeStart, "unsupported auth scheme %q", scheme)
+		}
+		if p.pos == afterScheme && p.pos < len(p.input) {
+			return nil, p.fail(p.pos, "expected whitespace after auth scheme")
+		}
+		result.Scheme = DigestScheme
+	}
+
+	seen := make(map[string]bool)
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.input) {
+			break
+		}
+
+		// empty list elements are allowed by RFC 7230 list syntax
+		if p.peek() == ',' {
+			p.pos++
+			continue
+		}
+
+		paramStart := p.pos
+		param, err := p.readParam()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.strict {
+			if !digestParams[param.Name] {
+				return nil, p.fail(paramStart, "unknown parameter %q", param.Name)
+			}
+			if seen[param.Name] {
+				return nil, p.fail(paramStart, "duplicate parameter %q", param.Name)
+			}
+		}
+		seen[param.Name] = true
+		result.Set(param.Name, param.Value, param.Quoted)
+
+		p.skipSpace()
+		if p.pos < len(p.input) && p.peek() != ',' {
+			return nil, p.fail(p.pos, "expected ',' after parameter %q", param.Name)
+		}
+	}
+
+	if len(result.Params) == 0 && p.strict {
+		return nil, p.fail(p.pos, "expected parameters")
+	}
+
+	return result, nil
+}
+
+func (p *digestParser) readParam() (param DigestParam, err error) {
+	nameStart := p.pos
+	name := p.readToken()
+	if name == "" {
+		err = p.fail(nameStart, "expected parameter name")
+		return
+	}
+	param.Name = strings.ToLower(name)
+
+	p.skipSpace()
+	if p.peek() != '=' {
+		err = p.fail(p.pos, "expected '=' after parameter %q", param.Name)
+		return
+	}
+	p.pos++
+	p.skipSpace()
+
+	if p.peek() == '"' {
+		param.Quoted = true
+		param.Value, err = p.readQuoted()
+		return
+	}
+
+	valueStart := p.pos
+	if p.strict {
+		param.Value = p.readToken()
+		if param.Value == "" {
+			err = p.fail(valueStart, "expected value for parameter %q", param.Name)
+		}
+		return
+	}
+
+	for p.pos < len(p.input) && p.input[p.pos] != ',' && !isSpace(p.input[p.pos]) && p.input[p.pos] != '"' {
+		p.pos++
+	}
+	param.Value = p.input[valueStart:p.pos]
+	return
+}
+
+func (p *digestParser) readQuoted() (string, error) {
+	start := p.pos
+	p.pos++ // opening quote
+
+	var sb strings.Builder
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return sb.String(), nil
+		case c == '\\':
+			if p.pos+1 >= len(p.input) {
+				return "", p.fail(p.pos, "unfinished escape sequence")
+			}
+			sb.WriteByte(p.input[p.pos+1])
+			p.pos += 2
+		case p.strict && (c < ' ' && c != '\t' || c == 0x7f):
+			return "", p.fail(p.pos, "control character in quoted string")
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+
+	return "", p.fail(start, "unterminated quoted string")
+}
+
+func (p *digestParser) readToken() string {
+	start := p.pos
+	for p.pos < len(p.input) && isTokenChar(p.input[p.pos]) {
+		p.pos++
+	}
+
+	return p.input[start:p.pos]
+}
+
+func (p *digestParser) skipSpace() {
+	for p.pos < len(p.input) && isSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *digestParser) peek() byte {
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+
+	return 0
+}
+
+// isSpace reports whether c is whitespace, CR and LF are accepted for folded header values
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// isTokenChar reports whether c is RFC 7230 tchar
+func isTokenChar(c byte) bool {
+	if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+		return true
+	}
+
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) != -1
+}
+
+func isToken(value string) bool {
+	if value == "" {
+		return false
+	}
+
+	for i := 0; i < len(value); i++ {
+		if !isTokenChar(value[i]) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,121 @@
+package structures
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDigestHeaderQuotedValues(t *testing.T) {
+	header := `Digest username=guid, uri="/v3.0/a,b", response="say \"hi\" \\ bye", qop=auth`
+
+	for _, mode := range []DigestParseMode{DigestParseLenient, DigestParseStrict} {
+		credentials, err := ParseDigestHeader(header, mode)
+		assert.NoError(t, err)
+		assert.Equal(t, DigestScheme, credentials.Scheme)
+
+		uri, ok := credentials.Get("URI")
+		assert.True(t, ok)
+		assert.Equal(t, "/v3.0/a,b", uri)
+
+		response, _ := credentials.Get("response")
+		assert.Equal(t, `say "hi" \ bye`, response)
+
+		qop, _ := credentials.Get("qop")
+		assert.Equal(t, "auth", qop)
+
+		_, ok = credentials.Get("cnonce")
+		assert.False(t, ok)
+	}
+}
+
+func TestParseDigestHeaderLenient(t *testing.T) {
+	header := "digest username=first,, Username=second,\r\n cnonce=MTU=, custom=\"x\""
+
+	credentials, err := ParseDigestHeader(header, DigestParseLenient)
+	assert.NoError(t, err)
+
+	username, _ := credentials.Get("username")
+	assert.Equal(t, "second", username)
+
+	cnonce, _ := credentials.Get("cnonce")
+	assert.Equal(t, "MTU=", cnonce)
+
+	custom, _ := credentials.Get("custom")
+	assert.Equal(t, "x", custom)
+
+	withoutScheme, err := ParseDigestHeader("username=guid, qop=auth", DigestParseLenient)
+	assert.NoError(t, err)
+	assert.Equal(t, "", withoutScheme.Scheme)
+	assert.Len(t, withoutScheme.Params, 2)
+}
+
+func TestParseDigestHeaderErrors(t *testing.T) {
+	examples := []struct {
+		header   string
+		mode     DigestParseMode
+		position int
+		reason   string
+	}{
+		{"", DigestParseLenient, 0, "expected auth scheme"},
+		{"Basic dXNlcg==", DigestParseLenient, 0, "unsupported auth scheme \"Basic\""},
+		{"Digest username", DigestParseLenient, 15, "expected '=' after parameter \"username\""},
+		{"Digest username=\"guid", DigestParseLenient, 16, "unterminated quoted string"},
+		{"Digest username=\"guid\\", DigestParseLenient, 21, "unfinished escape sequence"},
+		{"Digest username=\"guid\" qop=auth", DigestParseLenient, 23, "expected ',' after parameter \"username\""},
+		{"Digest username=a, =guid", DigestParseLenient, 19, "expected parameter name"},
+		{"username=guid", DigestParseStrict, 0, "expected auth scheme"},
+		{"Digest", DigestParseStrict, 6, "expected parameters"},
+		{"Digest username=guid, custom=1", DigestParseStrict, 22, "unknown parameter \"custom\""},
+		{"Digest username=guid, Username=guid", DigestParseStrict, 22, "duplicate parameter \"username\""},
+		{"Digest cnonce=MTU=", DigestParseStrict, 17, "expected ',' after parameter \"cnonce\""},
+		{"Digest cnonce=, qop=auth", DigestParseStrict, 14, "expected value for parameter \"cnonce\""},
+		{"Digest uri=\"/a\nb\"", DigestParseStrict, 14, "control character in quoted string"},
+	}
+
+	for _, testCase := range examples {
+		t.Run(testCase.reason, func(t *testing.T) {
+			_, err := ParseDigestHeader(testCase.header, testCase.mode)
+
+			var parseErr *DigestParseError
+			if assert.True(t, errors.As(err, &parseErr)) {
+				assert.Equal(t, testCase.position, parseErr.Position)
+				assert.Equal(t, testCase.reason, parseErr.Reason)
+			}
+		})
+	}
+}
+
+func TestDigestCredentialsString(t *testing.T) {
+	credentials := &DigestCredentials{}
+	credentials.Set("username", "guid", false)
+	credentials.Set("uri", `/v3.0/"a",b\c`, true)
+	credentials.Set("realm", "two words", false)
+	credentials.Set("qop", "auth-int", false)
+
+	header := credentials.String()
+	assert.Equal(t, `Digest username=guid, uri="/v3.0/\"a\",b\\c", realm="two words", qop=auth-int`, header)
+
+	parsed, err := ParseDigestHeader(header, DigestParseStrict)
+	assert.NoError(t, err)
+	for _, param := range credentials.Params {
+		value, _ := parsed.Get(param.Name)
+		assert.Equal(t, param.Value, value)
+	}
+}
+
+func TestResponseDigestQuotedComma(t *testing.T) {
+	header := "Digest username=guid, uri=\"/v3.0/a,b\", algorithm=SHA-256, cnonce=\"MTox\", " +
+		"snonce=\"MTox\", qop=auth, response=\"abc\""
+
+	responseDigest, err := NewResponseDigestWithMode(header, DigestParseStrict)
+	assert.NoError(t, err)
+	assert.Equal(t, "/v3.0/a,b", responseDigest.URI)
+
+	_, err = NewResponseDigestWithMode(header+", custom=1", DigestParseStrict)
+	assert.EqualError(t, err, "digest mismatch: format error: malformed digest header at position 114: unknown parameter \"custom\"")
+
+	var parseErr *DigestParseError
+	assert.True(t, errors.As(err, &parseErr))
+}
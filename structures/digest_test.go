@@ -1,10 +1,12 @@
 package structures
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -42,6 +44,87 @@ func TestRequestDigest(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
+func TestRequestDigestOptions(t *testing.T) {
+	examples := []struct {
+		algorithm      Algorithm
+		qop            QOP
+		responseLength int
+		headerPart     string
+	}{
+		{AlgorithmSHA256, QopAuth, 64, "algorithm=SHA-256, "},
+		{AlgorithmSHA384, QopAuthInt, 96, "algorithm=SHA-384, "},
+		{AlgorithmSHA512, QopAuth, 128, "algorithm=SHA-512, "},
+	}
+
+	for _, testCase := range examples {
+		t.Run(testCase.algorithm.String()+" "+testCase.qop.String(), func(t *testing.T) {
+			instance, err := NewRequestDigest("guid", "secret", "/v3.0/sms", []byte("{}"),
+				WithAlgorithm(testCase.algorithm), WithQOP(testCase.qop))
+			assert.NoError(t, err)
+
+			header, err := instance.CreateHeader()
+			assert.NoError(t, err)
+			assert.Contains(t, header, testCase.headerPart)
+			assert.Contains(t, header, "qop="+testCase.qop.String()+", ")
+			assert.Len(t, instance.Response, testCase.responseLength)
+		})
+	}
+
+	_, err := NewRequestDigest("guid", "secret", "/v3.0/sms", nil, WithAlgorithm(AlgorithmUnknown))
+	assert.EqualError(t, err, "unsupported algorithm unknown")
+
+	_, err = NewRequestDigest("guid", "secret", "/v3.0/sms", nil, WithQOP(QopUnknown))
+	assert.EqualError(t, err, "unsupported QOP unknown")
+}
+
+func TestRequestDigestClockAndRandom(t *testing.T) {
+	expected := "Digest username=bc501eda-e2a1-4e63-9a1e-7a7f6ff4813b, uri=\"/v3.0/sms\", algorithm=SHA-256, " +
+		"cnonce=\"MTU5MTYyNTA2MzqydV+lpoF4ZtfSAifxoUretZdAzGaZa97iRogrQ8K/yg==\", qop=auth-int, " +
+		"response=\"a3f16a4008fec1b1296ef73c7ed27b349d358ebf0ac6586697c1af3df3d1898a\""
+
+	cnonce, _ := base64.StdEncoding.DecodeString("MTU5MTYyNTA2MzqydV+lpoF4ZtfSAifxoUretZdAzGaZa97iRogrQ8K/yg==")
+	clock := func() time.Time { return time.Unix(1591625063, 0) }
+
+	for i := 0; i < 2; i++ {
+		instance, err := NewRequestDigest("bc501eda-e2a1-4e63-9a1e-7a7f6ff4813b", "agHJSthpTPfKEORLDynBuIl07i4sYVmw", "/v3.0/sms",
+			[]byte("{}"), WithClock(clock), WithRandom(bytes.NewReader(cnonce[11:])))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, cnonce, instance.Cnonce)
+		header, err := instance.CreateHeader()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, header)
+	}
+
+	responseDigest, err := NewResponseDigestForRequest("guid", "/v3.0/sms", cnonce, nil,
+		WithClock(clock), WithRandom(bytes.NewReader(cnonce[11:])))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1591625063, responseDigest.Timestamp)
+		assert.Equal(t, cnonce, responseDigest.Snonce)
+	}
+
+	_, err = NewRequestDigest("guid", "secret", "/v3.0/sms", nil, WithRandom(bytes.NewReader(make([]byte, 31))))
+	assert.EqualError(t, err, "cannot create nonce: unexpected EOF")
+
+	// nil sources fall back to secure defaults
+	instance, err := NewRequestDigest("guid", "secret", "/v3.0/sms", nil, WithClock(nil), WithRandom(nil))
+	if assert.NoError(t, err) {
+		assert.Len(t, instance.Cnonce, 43)
+	}
+}
+
+func TestRequestDigestQopAuthIgnoresBody(t *testing.T) {
+	first, _ := NewRequestDigest("guid", "secret", "/v3.0/form", []byte("first"), WithQOP(QopAuth))
+	second, _ := NewRequestDigest("guid", "secret", "/v3.0/form", []byte("second"), WithQOP(QopAuth))
+	second.Cnonce = first.Cnonce
+
+	firstHeader, _ := first.CreateHeader()
+	secondHeader, _ := second.CreateHeader()
+	assert.Equal(t, firstHeader, secondHeader)
+}
+
 func TestResponseDigestParseErrors(t *testing.T) {
 	nonce := base64.StdEncoding.EncodeToString([]byte("1:q"))
 	noTsNonce := base64.StdEncoding.EncodeToString([]byte("qqq"))
@@ -243,3 +326,90 @@ func TestResponseDigestVerifyCallback(t *testing.T) {
 	assert.NoError(t, parseErr)
 	assert.Equal(t, "8d77f986-de7f-4d47-97ef-9de7f8561684", parsedResult.ResultData.Gateway.GatewayTransactionID)
 }
+
+func TestResponseDigestVerifyAllowedAlgorithms(t *testing.T) {
+	body := "{\"acquirer-details\":{},\"error\":{},\"gw\":{\"gateway-transaction-id\":\"37b88436-b69c-45f3-ad26-b945153ad9a8\"," +
+		"\"redirect-url\":\"http://api.local/4f1f647d10e8296a2ed4d21e3639f1ee\",\"status-code\":30,\"status-text\":" +
+		"\"INSIDE FORM URL SENT\"},\"warnings\":[\"Soon counters will be exceeded for the merchant\",\"Soon counters will be exceeded " +
+		"for the account\"]}"
+
+	responseHeader := "Digest username=bc501eda-e2a1-4e63-9a1e-7a7f6ff4813b, uri=\"/v3.0/sms\", algorithm=SHA-256, " +
+		"cnonce=\"MTU5MTg2NjU3Mzo38zMeHvu4qcbhR8X158atP/BB4dDb5DbOMRT656yS7Q==\", " +
+		"snonce=\"MTU5MTg2NjU3MzpvnttqUse7hfrkUHtPS8tWE1jl0D0G/DgMmEFwbk5/jw==\", qop=auth-int, " +
+		"response=\"dda7026eebbeeee19fda191fd951d470b2064e3e1bc416365835abc775352552\""
+
+	responseDigest, err := NewResponseDigest(responseHeader)
+	assert.NoError(t, err)
+	responseDigest.Body = []byte(body)
+
+	responseDigest.AllowedAlgorithms = []Algorithm{AlgorithmSHA384, AlgorithmSHA512}
+	verifyErr := responseDigest.Verify("bc501eda-e2a1-4e63-9a1e-7a7f6ff4813b", "tPMOogw7YBumh6RpXxi2nvGW0C9lJq3L")
+	assert.EqualError(t, verifyErr, "digest mismatch: algorithm SHA-256 is not allowed")
+
+	responseDigest.AllowedAlgorithms = SupportedAlgorithms()
+	verifyErr = responseDigest.Verify("bc501eda-e2a1-4e63-9a1e-7a7f6ff4813b", "tPMOogw7YBumh6RpXxi2nvGW0C9lJq3L")
+	assert.NoError(t, verifyErr)
+}
+
+func TestResponseDigestCreateHeaderKnownAnswer(t *testing.T) {
+	body := "{\"acquirer-details\":{},\"error\":{},\"gw\":{\"gateway-transaction-id\":\"37b88436-b69c-45f3-ad26-b945153ad9a8\"," +
+		"\"redirect-url\":\"http://api.local/4f1f647d10e8296a2ed4d21e3639f1ee\",\"status-code\":30,\"status-text\":" +
+		"\"INSIDE FORM URL SENT\"},\"warnings\":[\"Soon counters will be exceeded for the merchant\",\"Soon counters will be exceeded " +
+		"for the account\"]}"
+
+	expected := "Digest username=bc501eda-e2a1-4e63-9a1e-7a7f6ff4813b, uri=\"/v3.0/sms\", algorithm=SHA-256, " +
+		"cnonce=\"MTU5MTg2NjU3Mzo38zMeHvu4qcbhR8X158atP/BB4dDb5DbOMRT656yS7Q==\", " +
+		"snonce=\"MTU5MTg2NjU3MzpvnttqUse7hfrkUHtPS8tWE1jl0D0G/DgMmEFwbk5/jw==\", qop=auth-int, " +
+		"response=\"dda7026eebbeeee19fda191fd951d470b2064e3e1bc416365835abc775352552\""
+
+	cnonce, _ := base64.StdEncoding.DecodeString("MTU5MTg2NjU3Mzo38zMeHvu4qcbhR8X158atP/BB4dDb5DbOMRT656yS7Q==")
+	responseDigest, err := NewResponseDigestForRequest("bc501eda-e2a1-4e63-9a1e-7a7f6ff4813b", "/v3.0/sms", cnonce, []byte(body))
+	assert.NoError(t, err)
+
+	responseDigest.Snonce, _ = base64.StdEncoding.DecodeString("MTU5MTg2NjU3MzpvnttqUse7hfrkUHtPS8tWE1jl0D0G/DgMmEFwbk5/jw==")
+	actual, err := responseDigest.CreateHeader("tPMOogw7YBumh6RpXxi2nvGW0C9lJq3L")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestResponseDigestSignVerifyRoundTrip(t *testing.T) {
+	body := []byte("{\"gw\":{\"status-code\":7}}")
+
+	for _, algorithm := range SupportedAlgorithms() {
+		for _, qop := range []QOP{QopAuth, QopAuthInt} {
+			t.Run(algorithm.String()+" "+qop.String(), func(t *testing.T) {
+				requestDigest, err := NewRequestDigest("guid", "secret", "/v3.0/sms", []byte("{}"), WithAlgorithm(algorithm), WithQOP(qop))
+				assert.NoError(t, err)
+
+				signer, err := NewResponseDigestForRequest("guid", requestDigest.URI, requestDigest.Cnonce, body, WithAlgorithm(algorithm), WithQOP(qop))
+				assert.NoError(t, err)
+				assert.InDelta(t, time.Now().Unix(), signer.Timestamp, 1)
+
+				header, err := signer.CreateHeader("secret")
+				assert.NoError(t, err)
+
+				for _, mode := range []DigestParseMode{DigestParseLenient, DigestParseStrict} {
+					parsed, err := NewResponseDigestWithMode(header, mode)
+					assert.NoError(t, err)
+					assert.Equal(t, signer.Timestamp, parsed.Timestamp)
+
+					parsed.OriginalURI = requestDigest.URI
+					parsed.OriginalCnonce = requestDigest.Cnonce
+					parsed.Body = body
+					assert.NoError(t, parsed.VerifyFresh("guid", "secret", NewReplayGuard(DefaultMaxClockSkew, nil)))
+					assert.EqualError(t, parsed.Verify("guid", "wrong secret"), "digest mismatch")
+
+					parsed.Body = []byte("{\"gw\":{\"status-code\":8}}")
+					if qop == QopAuthInt {
+						assert.EqualError(t, parsed.Verify("guid", "secret"), "digest mismatch")
+					} else {
+						assert.NoError(t, parsed.Verify("guid", "secret"))
+					}
+				}
+			})
+		}
+	}
+
+	_, err := NewResponseDigestForRequest("guid", "/v3.0/sms", nil, nil, WithAlgorithm(AlgorithmUnknown))
+	assert.EqualError(t, err, "unsupported algorithm unknown")
+}
@@ -0,0 +1,161 @@
+package structures
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorCategory is a coarse classification of request failures and Gateway errors
+type ErrorCategory int
+
+// Error categories
+const (
+	ErrorCategoryUnknown ErrorCategory = iota
+	// ErrorCategoryTransport covers network failures, aborted requests and unreadable responses
+	ErrorCategoryTransport
+	// ErrorCategoryAuth covers authorization problems, including digest creation and verification
+	ErrorCategoryAuth
+	// ErrorCategoryValidation covers malformed requests, rejected either by the client or the Gateway
+	ErrorCategoryValidation
+	// ErrorCategoryHardDecline covers declines that should not be retried with the same payment data
+	ErrorCategoryHardDecline
+	// ErrorCategorySoftDecline covers declines that may succeed after cardholder authentication
+	ErrorCategorySoftDecline
+	// ErrorCategoryAcquirerTimeout covers timeouts on the Gateway or acquirer side
+	ErrorCategoryAcquirerTimeout
+	// ErrorCategoryLimitsExceeded covers exceeded merchant, account or terminal counters
+	ErrorCategoryLimitsExceeded
+	// ErrorCategoryGateway covers internal Gateway failures
+	ErrorCategoryGateway
+)
+
+var errorCategory2string = map[ErrorCategory]string{
+	ErrorCategoryTransport:       "transport",
+	ErrorCategoryAuth:            "auth",
+	ErrorCategoryValidation:      "validation",
+	ErrorCategoryHardDecline:     "hard decline",
+	ErrorCategorySoftDecline:     "soft decline",
+	ErrorCategoryAcquirerTimeout: "acquirer timeout",
+	ErrorCategoryLimitsExceeded:  "limits exceeded",
+	ErrorCategoryGateway:         "gateway",
+}
+
+func (o ErrorCategory) String() string {
+	if result, ok := errorCategory2string[o]; ok {
+		return result
+	}
+
+	return "unknown"
+}
+
+// Sentinel errors matching GatewayError of corresponding category with errors.Is
+var (
+	ErrTransport       = errors.New("transport error")
+	ErrAuth            = errors.New("auth error")
+	ErrValidation      = errors.New("validation error")
+	ErrHardDecline     = errors.New("hard decline")
+	ErrSoftDecline     = errors.New("soft decline")
+	ErrAcquirerTimeout = errors.New("acquirer timeout")
+	ErrLimitsExceeded  = errors.New("limits exceeded")
+	ErrGateway         = errors.New("gateway error")
+)
+
+var errorCategory2sentinel = map[ErrorCategory]error{
+	ErrorCategoryTransport:       ErrTransport,
+	ErrorCategoryAuth:            ErrAuth,
+	ErrorCategoryValidation:      ErrValidation,
+	ErrorCategoryHardDecline:     ErrHardDecline,
+	ErrorCategorySoftDecline:     ErrSoftDecline,
+	ErrorCategoryAcquirerTimeout: ErrAcquirerTimeout,
+	ErrorCategoryLimitsExceeded:  ErrLimitsExceeded,
+	ErrorCategoryGateway:         ErrGateway,
+}
+
+// Category returns error category for the Gateway error code
+func (o ErrorCode) Category() ErrorCategory {
+	switch {
+	case o == 0:
+		return ErrorCategoryUnknown
+	case o == EecAcquirerSoftDecline:
+		return ErrorCategorySoftDecline
+	case o >= EecTimeoutTransaction && o <= EecTimeoutInternal:
+		return ErrorCategoryAcquirerTimeout
+	case o >= EecMerchantCountersExceeded && o <= EecTerminalCountersExceeded,
+		o == EecAllTerminalCountersExceeded, o == EecAllTerminalGroupCountersExceeded:
+		return ErrorCategoryLimitsExceeded
+	case o >= EecDisabledAccount && o <= EecDisabledLegalPerson,
+		o == EecCardExpired, o >= Eec3DErrorMdStatus && o <= EecCardLiabilityShift,
+		o == EecSuspectedFraud, o == EecDeclinedByAcquirer,
+		o == EecTerminalNotSupportingMOTO, o == EecTerminalNotSupportingRecurringTransactions:
+		return ErrorCategoryHardDecline
+	case o >= EecInputValidationFailed && o <= Eec3DDataCorrupted,
+		o >= EecWrongGwUniqID && o <= EecUcofError,
+		o >= EecInvalidFormID && o <= EecFormUnavailable,
+		o >= EecCardVerificationNoCardData && o <= EecCardVerificationAlreadyVerified,
+		o >= EecRbsInvalidOrderNumber && o <= EecRbsInvalidDescription:
+		return ErrorCategoryValidation
+	default:
+		return ErrorCategoryGateway
+	}
+}
+
+// GatewayError is a structured error for failed requests and Gateway errors.
+// Use errors.Is with category sentinels (like ErrSoftDecline) or errors.As to branch on it.
+type GatewayError struct {
+	// HTTP response status code, zero if no response was received
+	HTTPStatus int
+	// Gateway error code, zero for client-side failures
+	Code ErrorCode
+	// Human-readable error description
+	Message string
+	// Operation that failed
+	OperationType OperationType
+	// Error classification
+	Category ErrorCategory
+	// Underlying cause, if any
+	Err error
+}
+
+// NewGatewayError creates GatewayError from a Gateway error structure received with a response.
+// Returns nil if gwError contains no error code.
+func NewGatewayError(opType OperationType, httpStatus int, gwError Error) error {
+	if gwError.Code == 0 {
+		return nil
+	}
+
+	return &GatewayError{
+		HTTPStatus:    httpStatus,
+		Code:          gwError.Code,
+		Message:       gwError.Message,
+		OperationType: opType,
+		Category:      gwError.Code.Category(),
+	}
+}
+
+func (e *GatewayError) Error() string {
+	message := e.Message
+	if message == "" && e.Err != nil {
+		message = e.Err.Error()
+	}
+
+	if e.Code != 0 {
+		message = fmt.Sprintf("%d %s", e.Code, message)
+	}
+
+	if e.OperationType != "" {
+		return fmt.Sprintf("%s: %s: %s", e.OperationType, e.Category, message)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Category, message)
+}
+
+// Unwrap returns the underlying cause
+func (e *GatewayError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the sentinel error of this error's category
+func (e *GatewayError) Is(target error) bool {
+	sentinel, ok := errorCategory2sentinel[e.Category]
+	return ok && target == sentinel
+}
@@ -0,0 +1,66 @@
+package structures
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCodeCategory(t *testing.T) {
+	examples := map[ErrorCode]ErrorCategory{
+		0:                                  ErrorCategoryUnknown,
+		EecGeneralError:                    ErrorCategoryGateway,
+		EecDisabledTerminal:                ErrorCategoryHardDecline,
+		EecTimeoutAcquirer:                 ErrorCategoryAcquirerTimeout,
+		EecTimeoutTransaction:              ErrorCategoryAcquirerTimeout,
+		EecHsmDecode:                       ErrorCategoryGateway,
+		EecAccountCountersExceeded:         ErrorCategoryLimitsExceeded,
+		EecAllTerminalCountersExceeded:     ErrorCategoryLimitsExceeded,
+		EecInputValidationFailed:           ErrorCategoryValidation,
+		EecCardBadCVV:                      ErrorCategoryValidation,
+		EecCardExpired:                     ErrorCategoryHardDecline,
+		Eec3DErrorAuth:                     ErrorCategoryHardDecline,
+		EecGwUniqIDConflict:                ErrorCategoryValidation,
+		EecSuspectedFraud:                  ErrorCategoryHardDecline,
+		EecTerminalNotFound:                ErrorCategoryGateway,
+		EecDeclinedByAcquirer:              ErrorCategoryHardDecline,
+		EecAcquirerError:                   ErrorCategoryGateway,
+		EecAcquirerSoftDecline:             ErrorCategorySoftDecline,
+		EecFormUnavailable:                 ErrorCategoryValidation,
+		EecCardVerificationAlreadyVerified: ErrorCategoryValidation,
+		EecRbsInvalidDescription:           ErrorCategoryValidation,
+	}
+
+	for code, expected := range examples {
+		t.Run(fmt.Sprintf("%d", code), func(t *testing.T) {
+			assert.Equal(t, expected, code.Category())
+		})
+	}
+}
+
+func TestNewGatewayError(t *testing.T) {
+	assert.Nil(t, NewGatewayError(SMS, http.StatusOK, Error{}))
+
+	err := NewGatewayError(SMS, http.StatusOK, Error{Code: EecAcquirerSoftDecline, Message: "Soft decline"})
+	assert.EqualError(t, err, "sms: soft decline: 1303 Soft decline")
+	assert.True(t, errors.Is(err, ErrSoftDecline))
+	assert.False(t, errors.Is(err, ErrHardDecline))
+
+	var gwErr *GatewayError
+	assert.True(t, errors.As(err, &gwErr))
+	assert.Equal(t, EecAcquirerSoftDecline, gwErr.Code)
+	assert.Equal(t, http.StatusOK, gwErr.HTTPStatus)
+	assert.Equal(t, ErrorCategorySoftDecline, gwErr.Category)
+}
+
+func TestGatewayErrorWrapping(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := fmt.Errorf("wrapped: %w", &GatewayError{Category: ErrorCategoryTransport, Err: cause})
+
+	assert.True(t, errors.Is(err, ErrTransport))
+	assert.True(t, errors.Is(err, cause))
+	assert.EqualError(t, err, "wrapped: transport: connection refused")
+}
@@ -0,0 +1,102 @@
+package structures
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// InboundRequestDigest is a functional structure for request Authorization header value validation on the receiving side,
+// like a proxy accepting requests signed by RequestDigest
+type InboundRequestDigest struct {
+	digest
+
+	// Timestamp is taken from cnonce
+	Timestamp int
+	// ActualURI is the path the request was received on, it is compared to signed URI if set
+	ActualURI string
+	// AllowedAlgorithms limits accepted algorithms on verification, any supported algorithm is accepted if empty
+	AllowedAlgorithms []Algorithm
+	// RequiredQOP rejects requests signed with another QOP if set, e.g. QopAuthInt to require body integrity
+	RequiredQOP QOP
+}
+
+// inboundRequestDigestParams lists parameters required in request digest, in the order they are checked
+var inboundRequestDigestParams = []string{"username", "uri", "response", "algorithm", "qop", "cnonce"}
+
+// NewInboundRequestDigest parse request's Authorization header content using given parse mode and fill InboundRequestDigest structure
+func NewInboundRequestDigest(authorizationHeader string, mode DigestParseMode) (result *InboundRequestDigest, err error) {
+	if len(strings.TrimSpace(authorizationHeader)) == 0 {
+		err = errors.New("authorization header is missing")
+		return
+	}
+
+	var credentials *DigestCredentials
+	if credentials, err = ParseDigestHeader(authorizationHeader, mode); err != nil {
+		err = fmt.Errorf("digest mismatch: format error: %w", err)
+		return
+	}
+
+	result = &InboundRequestDigest{}
+	for _, key := range inboundRequestDigestParams {
+		value, _ := credentials.Get(key)
+		if key == "cnonce" {
+			if result.Cnonce, result.Timestamp, err = parseNonce(key, value); err != nil {
+				return
+			}
+			continue
+		}
+
+		if err = result.digest.set(key, value); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Verify verifies that parsed request digest was made using given GUID/secret pair.
+// In addition, if set, actual URI, allowed algorithms and required QOP are checked.
+func (o *InboundRequestDigest) Verify(objectGUID, secret string) (err error) {
+	if strings.ToLower(objectGUID) != strings.ToLower(o.Username) {
+		return errors.New("digest mismatch: username mismatch")
+	}
+
+	if len(o.ActualURI) > 0 && o.ActualURI != o.URI {
+		return errors.New("digest mismatch: uri mismatch")
+	}
+
+	if o.RequiredQOP != QopUnknown && o.RequiredQOP != o.QOP {
+		return fmt.Errorf("digest mismatch: qop %s is not allowed", o.QOP)
+	}
+
+	if !algorithmAllowed(o.Algorithm, o.AllowedAlgorithms) {
+		return fmt.Errorf("digest mismatch: algorithm %s is not allowed", o.Algorithm)
+	}
+
+	var expectedDigest string
+	if expectedDigest, err = o.sign(secret, nil); err != nil {
+		return
+	}
+
+	if !hmac.Equal([]byte(o.Response), []byte(expectedDigest)) {
+		return errors.New("digest mismatch")
+	}
+
+	return nil
+}
+
+// VerifyFresh verifies the digest like Verify does and then checks its cnonce with given guard,
+// rejecting stale and replayed requests. Guard check is skipped if guard is nil.
+func (o *InboundRequestDigest) VerifyFresh(objectGUID, secret string, guard *ReplayGuard) error {
+	if err := o.Verify(objectGUID, secret); err != nil {
+		return err
+	}
+
+	if guard == nil {
+		return nil
+	}
+
+	return guard.Check(o.Timestamp, o.Cnonce)
+}
@@ -0,0 +1,100 @@
+package structures
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInboundRequestDigestVerify(t *testing.T) {
+	body := []byte("{\"data\":{}}")
+
+	for _, algorithm := range SupportedAlgorithms() {
+		for _, qop := range []QOP{QopAuth, QopAuthInt} {
+			t.Run(algorithm.String()+" "+qop.String(), func(t *testing.T) {
+				requestDigest, err := NewRequestDigest("Guid", "secret", "/v3.0/sms", body, WithAlgorithm(algorithm), WithQOP(qop))
+				assert.NoError(t, err)
+				header, err := requestDigest.CreateHeader()
+				assert.NoError(t, err)
+
+				for _, mode := range []DigestParseMode{DigestParseLenient, DigestParseStrict} {
+					inbound, err := NewInboundRequestDigest(header, mode)
+					assert.NoError(t, err)
+					assert.Equal(t, requestDigest.Cnonce, inbound.Cnonce)
+
+					inbound.ActualURI = "/v3.0/sms"
+					inbound.Body = body
+					assert.NoError(t, inbound.VerifyFresh("guid", "secret", NewReplayGuard(DefaultMaxClockSkew, nil)))
+				}
+			})
+		}
+	}
+}
+
+func TestInboundRequestDigestVerifyErrors(t *testing.T) {
+	body := []byte("{\"data\":{}}")
+	requestDigest, _ := NewRequestDigest("guid", "secret", "/v3.0/sms", body, WithQOP(QopAuth))
+	header, _ := requestDigest.CreateHeader()
+
+	examples := []struct {
+		name    string
+		prepare func(inbound *InboundRequestDigest) (guid, secret string)
+		error   string
+	}{
+		{"username", func(inbound *InboundRequestDigest) (string, string) {
+			return "other", "secret"
+		}, "digest mismatch: username mismatch"},
+		{"uri", func(inbound *InboundRequestDigest) (string, string) {
+			inbound.ActualURI = "/v3.0/dms"
+			return "guid", "secret"
+		}, "digest mismatch: uri mismatch"},
+		{"qop", func(inbound *InboundRequestDigest) (string, string) {
+			inbound.RequiredQOP = QopAuthInt
+			return "guid", "secret"
+		}, "digest mismatch: qop auth is not allowed"},
+		{"algorithm", func(inbound *InboundRequestDigest) (string, string) {
+			inbound.AllowedAlgorithms = []Algorithm{AlgorithmSHA512}
+			return "guid", "secret"
+		}, "digest mismatch: algorithm SHA-256 is not allowed"},
+		{"secret", func(inbound *InboundRequestDigest) (string, string) {
+			return "guid", "wrong"
+		}, "digest mismatch"},
+	}
+
+	for _, testCase := range examples {
+		t.Run(testCase.name, func(t *testing.T) {
+			inbound, err := NewInboundRequestDigest(header, DigestParseStrict)
+			assert.NoError(t, err)
+			inbound.Body = body
+
+			guid, secret := testCase.prepare(inbound)
+			assert.EqualError(t, inbound.Verify(guid, secret), testCase.error)
+		})
+	}
+
+	inbound, _ := NewInboundRequestDigest(header, DigestParseStrict)
+	guard := NewReplayGuard(DefaultMaxClockSkew, nil)
+	assert.NoError(t, inbound.VerifyFresh("guid", "secret", guard))
+	assert.True(t, errors.Is(inbound.VerifyFresh("guid", "secret", guard), ErrReplayedNonce))
+}
+
+func TestInboundRequestDigestParseErrors(t *testing.T) {
+	examples := []struct{ digest, expectedError string }{
+		{"", "authorization header is missing"},
+		{"Digest username=a, uri=b, algorithm=SHA-256, qop=auth, response=x", "digest mismatch: empty value for cnonce"},
+		{
+			fmt.Sprintf("Digest username=a, uri=b, algorithm=SHA-256, qop=auth, response=x, cnonce=%q", "cXFx"),
+			"digest mismatch: corrupted value for cnonce (missing timestamp)",
+		},
+		{"Digest username=a, uri=\"b", "digest mismatch: format error: malformed digest header at position 23: unterminated quoted string"},
+	}
+
+	for _, testCase := range examples {
+		t.Run(testCase.expectedError, func(t *testing.T) {
+			_, err := NewInboundRequestDigest(testCase.digest, DigestParseLenient)
+			assert.EqualError(t, err, testCase.expectedError)
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package structures
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Replay protection defaults
+const (
+	DefaultMaxClockSkew       = 5 * time.Minute
+	DefaultNonceStoreCapacity = 10000
+)
+
+// Replay protection errors
+var (
+	ErrStaleNonce    = errors.New("digest mismatch: nonce timestamp is outside of acceptable clock skew window")
+	ErrReplayedNonce = errors.New("digest mismatch: nonce was already used")
+)
+
+type (
+	// NonceStore remembers seen nonces to detect replayed digests
+	NonceStore interface {
+		// Remember records the nonce until expiresAt and reports whether it was seen before and is not expired at now
+		Remember(nonce []byte, now, expiresAt time.Time) (seen bool, err error)
+		// Forget removes the nonce, so it's accepted again. It's used when processing of a verified digest failed.
+		Forget(nonce []byte) error
+	}
+
+	// MemoryNonceStore is an in-memory NonceStore which keeps a limited number of the most recent nonces.
+	// When the capacity is reached, the least recently seen nonce is evicted.
+	MemoryNonceStore struct {
+		mu       sync.Mutex
+		capacity int
+		order    *list.List
+		items    map[string]*list.Element
+	}
+
+	nonceEntry struct {
+		nonce     string
+		expiresAt time.Time
+	}
+
+	// ReplayGuard rejects digests with nonces that are too old (or too far in the future)
+	// or were already seen within the acceptable clock skew window
+	ReplayGuard struct {
+		// MaxClockSkew is the maximum acceptable difference between nonce timestamp and current time
+		MaxClockSkew time.Duration
+		// Store keeps seen nonces, replay detection is disabled if nil
+		Store NonceStore
+		// Now returns current time, time.Now is used if nil
+		Now func() time.Time
+	}
+)
+
+// NewMemoryNonceStore creates in-memory nonce store with given capacity (DefaultNonceStoreCapacity if not positive)
+func NewMemoryNonceStore(capacity int) *MemoryNonceStore {
+	if capacity <= 0 {
+		capacity = DefaultNonceStoreCapacity
+	}
+
+	return &MemoryNonceStore{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Remember records the nonce until expiresAt and reports whether it was seen before and is not expired at now
+func (s *MemoryNonceStore) Remember(nonce []byte, now, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := string(nonce)
+	if element, ok := s.items[key]; ok {
+		entry := element.Value.(*nonceEntry)
+		seen := now.Before(entry.expiresAt)
+		if expiresAt.After(entry.expiresAt) {
+			entry.expiresAt = expiresAt
+		}
+		s.order.MoveToFront(element)
+
+		return seen, nil
+	}
+
+	s.items[key] = s.order.PushFront(&nonceEntry{nonce: key, expiresAt: expiresAt})
+	for s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.items, oldest.Value.(*nonceEntry).nonce)
+	}
+
+	return false, nil
+}
+
+// Forget removes the nonce
+func (s *MemoryNonceStore) Forget(nonce []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.items[string(nonce)]; ok {
+		s.order.Remove(element)
+		delete(s.items, string(nonce))
+	}
+
+	return nil
+}
+
+// NewReplayGuard creates ReplayGuard with given clock skew window.
+// If store is nil, in-memory store with default capacity is used.
+func NewReplayGuard(maxClockSkew time.Duration, store NonceStore) *ReplayGuard {
+	if store == nil {
+		store = NewMemoryNonceStore(DefaultNonceStoreCapacity)
+	}
+
+	return &ReplayGuard{MaxClockSkew: maxClockSkew, Store: store}
+}
+
+// Check verifies that nonce's timestamp is within the clock skew window and the nonce wasn't seen before
+func (g *ReplayGuard) Check(timestamp int, nonce []byte) error {
+	now := time.Now
+	if g.Now != nil {
+		now = g.Now
+	}
+
+	checkedAt := now()
+	issuedAt := time.Unix(int64(timestamp), 0)
+	skew := checkedAt.Sub(issuedAt)
+	if skew > g.MaxClockSkew || -skew > g.MaxClockSkew {
+		return ErrStaleNonce
+	}
+
+	if g.Store == nil {
+		return nil
+	}
+
+	seen, err := g.Store.Remember(nonce, checkedAt, issuedAt.Add(g.MaxClockSkew))
+	if err != nil {
+		return err
+	}
+
+	if seen {
+		return ErrReplayedNonce
+	}
+
+	return nil
+}
+
+// Forget removes the nonce from the store, so a redelivery of the same digest passes Check again.
+// It's a no-op if the guard has no store.
+func (g *ReplayGuard) Forget(nonce []byte) error {
+	if g.Store == nil {
+		return nil
+	}
+
+	return g.Store.Forget(nonce)
+}
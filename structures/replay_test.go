@@ -0,0 +1,92 @@
+package structures
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryNonceStore(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := NewMemoryNonceStore(2)
+
+	seen, err := store.Remember([]byte("a"), now, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, seen)
+
+	seen, _ = store.Remember([]byte("a"), now, now.Add(time.Minute))
+	assert.True(t, seen)
+
+	// expired entries are not reported as seen
+	now = now.Add(2 * time.Minute)
+	seen, _ = store.Remember([]byte("a"), now, now.Add(time.Minute))
+	assert.False(t, seen)
+
+	// the least recently seen nonce is evicted
+	_, _ = store.Remember([]byte("b"), now, now.Add(time.Minute))
+	_, _ = store.Remember([]byte("a"), now, now.Add(time.Minute))
+	_, _ = store.Remember([]byte("c"), now, now.Add(time.Minute))
+	assert.Len(t, store.items, 2)
+
+	seen, _ = store.Remember([]byte("a"), now, now.Add(time.Minute))
+	assert.True(t, seen)
+	seen, _ = store.Remember([]byte("b"), now, now.Add(time.Minute))
+	assert.False(t, seen)
+}
+
+func TestReplayGuardCheck(t *testing.T) {
+	now := time.Unix(1591866573, 0)
+	guard := NewReplayGuard(time.Minute, nil)
+	guard.Now = func() time.Time { return now }
+
+	assert.NoError(t, guard.Check(1591866573, []byte("n1")))
+	assert.NoError(t, guard.Check(1591866573-59, []byte("n2")))
+	assert.NoError(t, guard.Check(1591866573+59, []byte("n3")))
+	assert.Equal(t, ErrReplayedNonce, guard.Check(1591866573, []byte("n1")))
+	assert.Equal(t, ErrStaleNonce, guard.Check(1591866573-61, []byte("n4")))
+	assert.Equal(t, ErrStaleNonce, guard.Check(1591866573+61, []byte("n5")))
+
+	// a forgotten nonce is accepted again
+	assert.NoError(t, guard.Forget([]byte("n1")))
+	assert.NoError(t, guard.Check(1591866573, []byte("n1")))
+	assert.Equal(t, ErrReplayedNonce, guard.Check(1591866573, []byte("n1")))
+
+	withoutStore := &ReplayGuard{MaxClockSkew: time.Minute, Now: guard.Now}
+	assert.NoError(t, withoutStore.Check(1591866573, []byte("n1")))
+	assert.NoError(t, withoutStore.Check(1591866573, []byte("n1")))
+	assert.NoError(t, withoutStore.Forget([]byte("n1")))
+}
+
+func TestResponseDigestVerifyFresh(t *testing.T) {
+	body := "{\"acquirer-details\":{},\"error\":{},\"gw\":{\"gateway-transaction-id\":\"37b88436-b69c-45f3-ad26-b945153ad9a8\"," +
+		"\"redirect-url\":\"http://api.local/4f1f647d10e8296a2ed4d21e3639f1ee\",\"status-code\":30,\"status-text\":" +
+		"\"INSIDE FORM URL SENT\"},\"warnings\":[\"Soon counters will be exceeded for the merchant\",\"Soon counters will be exceeded " +
+		"for the account\"]}"
+
+	responseHeader := "Digest username=bc501eda-e2a1-4e63-9a1e-7a7f6ff4813b, uri=\"/v3.0/sms\", algorithm=SHA-256, " +
+		"cnonce=\"MTU5MTg2NjU3Mzo38zMeHvu4qcbhR8X158atP/BB4dDb5DbOMRT656yS7Q==\", " +
+		"snonce=\"MTU5MTg2NjU3MzpvnttqUse7hfrkUHtPS8tWE1jl0D0G/DgMmEFwbk5/jw==\", qop=auth-int, " +
+		"response=\"dda7026eebbeeee19fda191fd951d470b2064e3e1bc416365835abc775352552\""
+
+	guard := NewReplayGuard(DefaultMaxClockSkew, nil)
+	guard.Now = func() time.Time { return time.Unix(1591866573, 0).Add(time.Minute) }
+
+	verify := func(secret string) error {
+		responseDigest, err := NewResponseDigest(responseHeader)
+		assert.NoError(t, err)
+
+		responseDigest.OriginalCnonce, _ = base64.StdEncoding.DecodeString("MTU5MTg2NjU3Mzo38zMeHvu4qcbhR8X158atP/BB4dDb5DbOMRT656yS7Q==")
+		responseDigest.Body = []byte(body)
+		return responseDigest.VerifyFresh("bc501eda-e2a1-4e63-9a1e-7a7f6ff4813b", secret, guard)
+	}
+
+	// signature is checked before the nonce is remembered
+	assert.EqualError(t, verify("wrong secret"), "digest mismatch")
+	assert.NoError(t, verify("tPMOogw7YBumh6RpXxi2nvGW0C9lJq3L"))
+	assert.Equal(t, ErrReplayedNonce, verify("tPMOogw7YBumh6RpXxi2nvGW0C9lJq3L"))
+
+	guard.Now = time.Now
+	assert.Equal(t, ErrStaleNonce, verify("tPMOogw7YBumh6RpXxi2nvGW0C9lJq3L"))
+}
@@ -31,6 +31,13 @@ func (o *URL) UnmarshalJSON(raw []byte) error {
 	return nil
 }
 
+// MarshalJSON is a custom marshal function for URL type.
+// Is used to convert URL to string, the way it's unmarshaled.
+func (o URL) MarshalJSON() ([]byte, error) {
+	parsed := url.URL(o)
+	return json.Marshal(parsed.String())
+}
+
 // MarshalJSON is a custom marshal function for Time type.
 // Is used to convert Time to Unix timestamp.
 func (o Time) MarshalJSON() ([]byte, error) {
@@ -39,6 +39,27 @@ func TestURLUnmarshalJSON(t *testing.T) {
 	}
 }
 
+func TestURLMarshalJSON(t *testing.T) {
+	parsed, _ := url.Parse("https://example.com/3ds?id=1&step=2")
+
+	testData := struct {
+		A *URL `json:"a,omitempty"`
+		B *URL `json:"b,omitempty"`
+	}{
+		A: (*URL)(parsed),
+	}
+
+	raw, err := json.Marshal(testData)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":"https://example.com/3ds?id=1\u0026step=2"}`, string(raw))
+
+	var decoded struct {
+		A *URL `json:"a"`
+	}
+	assert.NoError(t, json.Unmarshal(raw, &decoded))
+	assert.Equal(t, testData.A, decoded.A)
+}
+
 func TestTimeMarshalJSON(t *testing.T) {
 	testTime, _ := time.Parse("2006-01-02 15:04:05", "2020-06-10 08:37:22")
 
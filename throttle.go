@@ -0,0 +1,202 @@
+package tprogateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+)
+
+// MetricQueueDuration records the time requests wait for rate limits and in-flight caps, reported to Observer
+const MetricQueueDuration = "gateway_queue_duration_seconds"
+
+// Limit throttles requests on the client side, to stay within the Gateway's throughput limits.
+// Every attempt of a request (including retries) is throttled.
+type Limit struct {
+	// Rate is the number of requests allowed per second on average, no rate limit if zero
+	Rate float64
+	// Burst is the number of requests allowed at once above the average rate, 1 if zero
+	Burst int
+	// MaxInFlight limits the number of concurrent requests, no limit if zero
+	MaxInFlight int
+}
+
+// WithLimit sets the limit for given operation types, every type gets its own rate and in-flight counters.
+// Without operation types the limit is shared by all operations without a limit of their own, e.g.
+//
+//	WithLimit(Limit{Rate: 50, MaxInFlight: 20}),
+//	WithLimit(Limit{Rate: 1, MaxInFlight: 1}, structures.Report)
+//
+// Requests wait for their turn until the context is done. Requests that can't start before the context's deadline
+// fail immediately with an error wrapping *RequestAbortedError. Waiting time is reported to Observer as MetricQueueDuration.
+func WithLimit(limit Limit, opTypes ...structures.OperationType) Option {
+	return func(gc *GatewayClient) error {
+		if err := limit.validate(); err != nil {
+			return err
+		}
+
+		if gc.throttles == nil {
+			gc.throttles = make(map[structures.OperationType]*throttle)
+		}
+
+		if len(opTypes) == 0 {
+			gc.throttles[""] = newThrottle(limit)
+			return nil
+		}
+
+		for _, opType := range opTypes {
+			if opType == "" {
+				return errors.New("limit: operation type can't be empty")
+			}
+
+			gc.throttles[opType] = newThrottle(limit)
+		}
+
+		return nil
+	}
+}
+
+func (l *Limit) validate() error {
+	switch {
+	case l.Rate < 0:
+		return fmt.Errorf("limit: rate can't be negative, got %g", l.Rate)
+	case l.Burst < 0:
+		return fmt.Errorf("limit: burst can't be negative, got %d", l.Burst)
+	case l.MaxInFlight < 0:
+		return fmt.Errorf("limit: max in-flight requests can't be negative, got %d", l.MaxInFlight)
+	case l.Rate == 0 && l.Burst > 0:
+		return errors.New("limit: burst requires rate")
+	case l.Rate == 0 && l.MaxInFlight == 0:
+		return errors.New("limit: rate or max in-flight requests must be set")
+	}
+
+	return nil
+}
+
+// throttleFor returns the throttle of the operation type, nil if requests aren't throttled
+func (gc *GatewayClient) throttleFor(opType structures.OperationType) *throttle {
+	if t, ok := gc.throttles[opType]; ok {
+		return t
+	}
+
+	return gc.throttles[""]
+}
+
+// acquire waits until a request of the operation type may be sent.
+// Returned function must be called once the request is completed.
+func (gc *GatewayClient) acquire(ctx context.Context, opType structures.OperationType) (func(), error) {
+	t := gc.throttleFor(opType)
+	if t == nil {
+		return func() {}, nil
+	}
+
+	started := time.Now()
+	release, err := t.acquire(ctx)
+	if gc.observer != nil {
+		gc.observer.ObserveHistogram(MetricQueueDuration, time.Since(started).Seconds(), Tags{TagOperation: string(opType)})
+	}
+	if err != nil {
+		return nil, newGatewayError(structures.ErrorCategoryTransport, opType, 0, &RequestAbortedError{Err: err})
+	}
+
+	return release, nil
+}
+
+// throttle combines a token bucket with a semaphore of in-flight requests
+type throttle struct {
+	bucket   *tokenBucket
+	inFlight chan struct{}
+}
+
+func newThrottle(limit Limit) *throttle {
+	result := new(throttle)
+	if limit.Rate > 0 {
+		burst := limit.Burst
+		if burst == 0 {
+			burst = 1
+		}
+		result.bucket = &tokenBucket{rate: limit.Rate, burst: float64(burst), tokens: float64(burst)}
+	}
+	if limit.MaxInFlight > 0 {
+		result.inFlight = make(chan struct{}, limit.MaxInFlight)
+	}
+
+	return result
+}
+
+// acquire takes an in-flight slot first and then a token, so tokens aren't spent while waiting for a slot
+func (t *throttle) acquire(ctx context.Context) (func(), error) {
+	release := func() {}
+	if t.inFlight != nil {
+		select {
+		case t.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		release = func() { <-t.inFlight }
+	}
+
+	if t.bucket != nil {
+		if err := t.bucket.wait(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	return release, nil
+}
+
+// tokenBucket is a token bucket rate limiter. Tokens are reserved in advance,
+// so waiting requests are served in order.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// wait takes a token, waiting for it if the bucket is empty.
+// The token is returned if the context is done before it's available.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	b.mu.Lock()
+	now := time.Now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	b.tokens--
+
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	err := ctx.Err()
+	if deadline, ok := ctx.Deadline(); ok && err == nil && now.Add(delay).After(deadline) {
+		err = context.DeadlineExceeded
+	}
+	if err == nil {
+		err = sleepContext(ctx, delay)
+	}
+
+	if err != nil {
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+	}
+
+	return err
+}
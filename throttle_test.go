@@ -0,0 +1,172 @@
+package tprogateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TransactPRO/gw3-go-client/structures"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithLimitValidation(t *testing.T) {
+	examples := []struct {
+		option        Option
+		expectedError string
+	}{
+		{WithLimit(Limit{Rate: -1}), "limit: rate can't be negative, got -1"},
+		{WithLimit(Limit{Rate: 1, Burst: -1}), "limit: burst can't be negative, got -1"},
+		{WithLimit(Limit{MaxInFlight: -1}), "limit: max in-flight requests can't be negative, got -1"},
+		{WithLimit(Limit{Burst: 5, MaxInFlight: 1}), "limit: burst requires rate"},
+		{WithLimit(Limit{}), "limit: rate or max in-flight requests must be set"},
+		{WithLimit(Limit{Rate: 1}, ""), "limit: operation type can't be empty"},
+	}
+
+	for _, testCase := range examples {
+		t.Run(testCase.expectedError, func(t *testing.T) {
+			gateCli, err := NewGatewayClient(testObjectGUID, testSecretKey, testCase.option)
+			assert.Nil(t, gateCli)
+			assert.EqualError(t, err, testCase.expectedError)
+		})
+	}
+}
+
+func TestWithLimitPerOperationType(t *testing.T) {
+	gateCli, err := NewGatewayClient(testObjectGUID, testSecretKey)
+	assert.NoError(t, err)
+	assert.Nil(t, gateCli.throttleFor(structures.SMS))
+
+	gateCli, err = NewGatewayClient(testObjectGUID, testSecretKey,
+		WithLimit(Limit{Rate: 50, MaxInFlight: 20}),
+		WithLimit(Limit{Rate: 1}, structures.Report, structures.ExploringLimits),
+	)
+	assert.NoError(t, err)
+
+	report, limits, sms, refund := gateCli.throttleFor(structures.Report), gateCli.throttleFor(structures.ExploringLimits),
+		gateCli.throttleFor(structures.SMS), gateCli.throttleFor(structures.Refund)
+	assert.NotSame(t, report, limits)
+	assert.Same(t, sms, refund)
+	assert.Nil(t, report.inFlight)
+	assert.Equal(t, 20, cap(sms.inFlight))
+}
+
+func TestWithLimitMaxInFlight(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, inFlightMax := 0, 0
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		mu.Lock()
+		inFlight++
+		if inFlight > inFlightMax {
+			inFlightMax = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return http.StatusOK, "{}"
+	})
+	defer server.Close()
+
+	gateCli := newTestClient(t, server, WithLimit(Limit{MaxInFlight: 2}, structures.SMS))
+	ops := make([]structures.OperationRequestInterface, 8)
+	for i := range ops {
+		ops[i] = gateCli.OperationBuilder().NewSms()
+	}
+
+	results, err := ExecuteBatch(context.Background(), gateCli, ops, BatchOptions{Concurrency: 8})
+	assert.NoError(t, err)
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+	}
+	assert.Equal(t, 2, inFlightMax)
+}
+
+func TestWithLimitRate(t *testing.T) {
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		return http.StatusOK, "{}"
+	})
+	defer server.Close()
+
+	observer := newTestObserver()
+	gateCli := newTestClient(t, server, WithLimit(Limit{Rate: 20, Burst: 2}), WithObserver(observer))
+
+	started := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+		assert.NoError(t, err)
+	}
+
+	// 2 requests are allowed at once, 3 more wait for 50ms each
+	assert.GreaterOrEqual(t, int64(time.Since(started)), int64(140*time.Millisecond))
+	if assert.Len(t, observer.histograms[MetricQueueDuration], 5) {
+		assert.Less(t, observer.histograms[MetricQueueDuration][0], 0.01)
+		assert.Greater(t, observer.histograms[MetricQueueDuration][4], 0.03)
+	}
+}
+
+func TestWithLimitRespectsDeadline(t *testing.T) {
+	requests := 0
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		requests++
+		return http.StatusOK, "{}"
+	})
+	defer server.Close()
+
+	gateCli := newTestClient(t, server, WithLimit(Limit{Rate: 2}))
+	_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.NoError(t, err)
+
+	// the next token is available in 500ms, later than the deadline: the request fails without waiting
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	_, err = gateCli.NewRequestWithContext(ctx, gateCli.OperationBuilder().NewSms())
+	assert.Less(t, int64(time.Since(started)), int64(50*time.Millisecond))
+	assert.True(t, errors.Is(err, structures.ErrTransport))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 1, requests)
+
+	// the token is returned, so the next request waits only for the first one's refill
+	started = time.Now()
+	_, err = gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+	assert.NoError(t, err)
+	assert.Less(t, int64(time.Since(started)), int64(600*time.Millisecond))
+	assert.Equal(t, 2, requests)
+}
+
+func TestWithLimitCanceledWhileWaitingForSlot(t *testing.T) {
+	release := make(chan struct{})
+	server := newTestGateway(t, func(r *http.Request, body []byte) (int, string) {
+		<-release
+		return http.StatusOK, "{}"
+	})
+	defer server.Close()
+
+	gateCli := newTestClient(t, server, WithLimit(Limit{MaxInFlight: 1}))
+
+	done := make(chan error)
+	go func() {
+		_, err := gateCli.NewRequest(gateCli.OperationBuilder().NewSms())
+		done <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	time.Sleep(10 * time.Millisecond)
+	_, err := gateCli.NewRequestWithContext(ctx, gateCli.OperationBuilder().NewSms())
+	var abortedErr *RequestAbortedError
+	if assert.True(t, errors.As(err, &abortedErr)) {
+		assert.Equal(t, context.DeadlineExceeded, abortedErr.Err)
+	}
+
+	close(release)
+	assert.NoError(t, <-done)
+}